@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// defaultImageHosts are the hosts allowed by --restrict-hosts when no
+// --image-host-allowlist is given.
+var defaultImageHosts = []string{
+	"i.redd.it",
+	"preview.redd.it",
+	"i.imgur.com",
+}
+
+// hostAllowlist is the set of hosts images may be downloaded from. A nil
+// allowlist allows every host.
+type hostAllowlist map[string]bool
+
+// newHostAllowlist builds an allowlist from host names, ignoring case and
+// surrounding whitespace.
+func newHostAllowlist(hosts []string) hostAllowlist {
+	allow := make(hostAllowlist)
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" {
+			allow[h] = true
+		}
+	}
+	return allow
+}
+
+// check returns ErrHostNotAllowed unless rawURL's host is on the list. Hosts
+// must match exactly; subdomains of a listed host are not allowed.
+func (a hostAllowlist) check(rawURL string) error {
+	if a == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrHostNotAllowed, err)
+	}
+	host := strings.ToLower(u.Hostname())
+	if !a[host] {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHostAllowlistCheck(t *testing.T) {
+	allow := newHostAllowlist([]string{" I.Redd.it ", "i.imgur.com", ""})
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://i.redd.it/a.jpg", true},
+		{"https://I.REDD.IT/a.jpg", true},
+		{"https://i.imgur.com:443/b.png", true},
+		{"https://evil.example/a.jpg", false},
+		{"https://sub.i.redd.it/a.jpg", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"://bad", false},
+	}
+	for _, tt := range tests {
+		err := allow.check(tt.url)
+		if tt.allowed && err != nil {
+			t.Errorf("check(%q) = %v, want allowed", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrHostNotAllowed) {
+			t.Errorf("check(%q) = %v, want ErrHostNotAllowed", tt.url, err)
+		}
+	}
+	if err := hostAllowlist(nil).check("https://anything.example/x.png"); err != nil {
+		t.Errorf("nil allowlist: %v", err)
+	}
+}
+
+func TestAllowlistSkipsOtherHosts(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	addPicsImages(t, m)
+
+	r := newMockRunner(t, m, "show", "--subreddit=pics", "--image-host-allowlist=i.redd.it")
+	defer r.close()
+	posts, err := r.fetchInitial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := r.newSession(nil)
+	r.process(sess, posts)
+
+	if got := imageRequests(m); len(got) != 0 {
+		t.Errorf("fetched %v from a host off the allowlist", got)
+	}
+	if got := sess.stats.failures["host-not-allowed"]; got != 3 {
+		t.Errorf("%d posts skipped as off the allowlist, want 3", got)
+	}
+}
+
+func TestAllowlistChecksRedirects(t *testing.T) {
+	m := newMockReddit(t)
+	m.addImage("cat.png", pngBytes(t, 8, 8, color.White))
+	// The same server under another name is another host.
+	elsewhere := strings.Replace(m.URL, "127.0.0.1", "localhost", 1) + "/img/cat.png"
+	url := m.handle("/img/moved.png", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, elsewhere, http.StatusFound)
+	})
+
+	allow := newHostAllowlist([]string{"127.0.0.1"})
+	dl := newDownloader(newImageClient(m.Client().Transport, true, allow), 0, 0)
+	dl.allow = allow
+	if _, err := dl.fetchImageData(context.Background(), m.URL+"/img/cat.png"); err != nil {
+		t.Fatalf("allowed host: %v", err)
+	}
+	if _, err := dl.fetchImageData(context.Background(), url); !errors.Is(err, ErrHostNotAllowed) {
+		t.Errorf("redirect off the allowlist: err = %v, want ErrHostNotAllowed", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// config holds the parsed command line.
+type config struct {
+	// command is the subcommand being run: show, download, list or
+	// formats.
+	command string
+
+	// Flags shared by every command.
+	subreddit          string
+	limit              int
+	maxPages           int
+	sample             int
+	seed               int64
+	format             string
+	baseURL            string
+	galleries          string
+	followRedirects    bool
+	insecure           bool
+	dialTimeout        time.Duration
+	tlsTimeout         time.Duration
+	userAgent          string
+	retries            int
+	retryBudget        int
+	retryStatus        string
+	concurrency        int
+	decodeConcurrency  int
+	decodeBufferBytes  int64
+	maxDecodes         int
+	perHostConcurrency int
+	maxDownloadBytes   int64
+	maxPixels          int64
+	maxImageBytes      int64
+	headCheck          bool
+	delay              time.Duration
+	restrictHosts      bool
+	imageHosts         string
+	cleanTitles        bool
+	titlePattern       string
+	grayscale          bool
+	palette            string
+	includeTypes       string
+	excludeTypes       string
+	fromFile           string
+	fixturesDir        string
+	exportPosts        string
+	saveJSONListing    string
+	deadline           time.Duration
+	verbose            bool
+	previewOnly        bool
+	bestQuality        bool
+	onlyNew            bool
+	dedupeAcrossRuns   bool
+	dedupeDistance     int
+
+	// Saving, for show and download.
+	download         bool
+	resume           bool
+	saveIndex        bool
+	filenameTemplate string
+	filenameSource   string
+	contactSheet     string
+	sheetColumns     int
+	sheetRows        int
+	sheetCrop        bool
+	background       string
+	flattenAlpha     bool
+	stripMetadata    bool
+	zipPath          string
+	openAfter        bool
+
+	// Headless output, for download only.
+	jsonOutput bool
+
+	// Polling, for show and download.
+	watch        bool
+	pollInterval time.Duration
+
+	// Display, for show only.
+	theme              string
+	fit                string
+	titleFontSize      float64
+	titleBold          bool
+	cardSpacing        float64
+	columns            int
+	responsive         bool
+	minCellWidth       int
+	includeText        bool
+	markdown           bool
+	placeholder        string
+	noResize           bool
+	scrollTopThreshold float64
+}
+
+const usageHeader = `Usage: image-scroller [command] [flags]
+
+Commands:
+  show      Show the images in a window (the default)
+  download  Save the images to the download directory without a window
+  list      Print the image URLs of the fetched posts
+  formats   Print the image formats that can be loaded and saved
+
+Flags:
+`
+
+// parseCommand parses the command line, without the program name. The first
+// argument selects the command unless it is a flag, in which case "show" is
+// assumed so that invocations from before subcommands keep working.
+func parseCommand(args []string) (*config, error) {
+	name := "show"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	cfg := &config{command: name}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	registerCommonFlags(fs, cfg)
+	switch name {
+	case "show":
+		fs.BoolVar(&cfg.download, "download", false, "Download images to the current directory when true")
+		registerSaveFlags(fs, cfg)
+		registerWatchFlags(fs, cfg)
+		registerShowFlags(fs, cfg)
+	case "download":
+		cfg.download = true
+		fs.BoolVar(&cfg.jsonOutput, "json-output", false, "Print a JSON summary of the run (counts and saved files) to stdout when done")
+		registerSaveFlags(fs, cfg)
+		registerWatchFlags(fs, cfg)
+	case "list":
+	case "formats":
+	default:
+		return nil, fmt.Errorf("unknown command %q (want show, download, list or formats)", name)
+	}
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usageHeader)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if cfg.resume && !cfg.download {
+		return nil, fmt.Errorf("--resume requires --download")
+	}
+	if cfg.zipPath != "" && !cfg.download {
+		return nil, fmt.Errorf("--zip requires --download")
+	}
+	if cfg.markdown && !cfg.includeText {
+		return nil, fmt.Errorf("--markdown requires --include-text")
+	}
+	if cfg.openAfter && !cfg.download {
+		return nil, fmt.Errorf("--open-after requires --download")
+	}
+	if cfg.zipPath != "" && (cfg.resume || cfg.saveIndex) {
+		return nil, fmt.Errorf("--zip cannot be used with --resume or --save-index")
+	}
+	if cfg.previewOnly && cfg.bestQuality {
+		return nil, fmt.Errorf("--preview-only and --best-quality cannot be used together")
+	}
+	if cfg.fixturesDir != "" && cfg.fromFile != "" {
+		return nil, fmt.Errorf("--fixtures-dir cannot be used with --from-file")
+	}
+	if cfg.filenameSource != "" && cfg.filenameTemplate != defaultFilenameTemplate {
+		return nil, fmt.Errorf("--filename-source cannot be used with --save-filename-template")
+	}
+	if cfg.watch && cfg.fromFile != "" {
+		return nil, fmt.Errorf("--watch cannot be used with --from-file")
+	}
+	return cfg, nil
+}
+
+func registerCommonFlags(fs *flag.FlagSet, cfg *config) {
+	fs.StringVar(&cfg.subreddit, "subreddit", "archlinux", "Name of the subreddit to fetch images from; comma-separated for several, or - to read names from stdin")
+	fs.IntVar(&cfg.limit, "limit", 25, "Number of posts to fetch per subreddit (0 for every post the listing offers, up to --max-pages)")
+	fs.IntVar(&cfg.maxPages, "max-pages", maxListingPages, "Most listing pages of 100 posts fetched per subreddit with --limit 0")
+	fs.IntVar(&cfg.sample, "sample", 0, "Keep only this many posts, chosen at random from those fetched (raise --limit to sample from more)")
+	fs.Int64Var(&cfg.seed, "seed", 0, "Random seed for --sample, to repeat a selection (0 picks one and logs it)")
+	fs.StringVar(&cfg.format, "format", "json", "Listing source: json (the JSON API) or rss (the Atom feed)")
+	fs.StringVar(&cfg.baseURL, "base-url", defaultRedditBaseURL, "Site listings are fetched from, e.g. https://old.reddit.com or a frontend serving the same JSON API")
+	fs.StringVar(&cfg.galleries, "galleries", "flatten", "How gallery posts are shown: flatten (every image) or collapse (the first image, with a count badge)")
+	fs.BoolVar(&cfg.followRedirects, "follow-redirects", true, "Follow HTTP redirects when downloading images")
+	fs.StringVar(&cfg.userAgent, "user-agent", defaultUserAgent, "User-Agent header sent with every request")
+	fs.BoolVar(&cfg.insecure, "insecure", false, "Skip TLS certificate verification (for debugging proxies only)")
+	fs.DurationVar(&cfg.dialTimeout, "dial-timeout", defaultDialTimeout, "Give up connecting to a host after this long (0 for no limit)")
+	fs.DurationVar(&cfg.tlsTimeout, "tls-timeout", defaultTLSTimeout, "Give up on a TLS handshake after this long (0 for no limit)")
+	fs.IntVar(&cfg.retries, "retries", 2, "Number of times to retry a truncated image download, or a listing page or image that fails with a --retry-status code")
+	fs.StringVar(&cfg.retryStatus, "retry-status", defaultRetryStatuses, "Comma-separated HTTP status codes worth retrying (empty to retry none)")
+	fs.IntVar(&cfg.retryBudget, "retry-budget", 0, "Maximum number of download retries over the whole run (0 for no limit beyond --retries)")
+	fs.IntVar(&cfg.concurrency, "concurrency", 4, "Number of images to download in parallel")
+	fs.IntVar(&cfg.decodeConcurrency, "decode-concurrency", runtime.NumCPU(), "Number of images to decode in parallel")
+	fs.IntVar(&cfg.maxDecodes, "max-decodes", defaultMaxDecodes(), "Maximum number of images decoded at once, independent of --decode-concurrency (0 for no limit)")
+	fs.Int64Var(&cfg.decodeBufferBytes, "decode-buffer-bytes", 0, "Hold back downloads while the images downloaded but not yet decoded and shown in the feed (or saved, without a window) would take more than this many bytes of memory once decoded (0 for no limit)")
+	fs.IntVar(&cfg.perHostConcurrency, "per-host-concurrency", 0, "Maximum number of images downloaded in parallel from any one host (0 for no limit beyond --concurrency)")
+	fs.DurationVar(&cfg.delay, "delay", 0, "Minimum time between the starts of successive image downloads, across all workers")
+	fs.Int64Var(&cfg.maxDownloadBytes, "max-download-bytes", 0, "Stop downloading images once this many bytes have been fetched (0 for no limit)")
+	fs.Int64Var(&cfg.maxImageBytes, "max-image-bytes", 0, "Skip images whose response announces more than this many bytes (0 for no limit)")
+	fs.BoolVar(&cfg.headCheck, "head-check", false, "Send a HEAD request before each download to skip non-images and images over --max-image-bytes without fetching them")
+	fs.Int64Var(&cfg.maxPixels, "max-pixels", 100_000_000, "Skip images whose width times height exceeds this, checked before decoding (0 for no limit)")
+	fs.BoolVar(&cfg.restrictHosts, "restrict-hosts", false, "Only download images from known image hosts (i.redd.it, preview.redd.it, i.imgur.com)")
+	fs.StringVar(&cfg.imageHosts, "image-host-allowlist", "", "Comma-separated hosts images may be downloaded from; implies --restrict-hosts")
+	fs.BoolVar(&cfg.cleanTitles, "clean-titles", false, "Strip bracketed tags and resolution suffixes from titles and filenames")
+	fs.StringVar(&cfg.titlePattern, "title-pattern", defaultTitleTagPattern, "Regular expression of title fragments removed by --clean-titles")
+	fs.BoolVar(&cfg.grayscale, "grayscale", false, "Convert images to grayscale before showing and saving them")
+	fs.StringVar(&cfg.palette, "palette", "", "Quantize images to a fixed palette: bw, gray16, websafe or plan9")
+	fs.StringVar(&cfg.includeTypes, "include-types", "", "Comma-separated media types to keep: gif, static or video (all when empty)")
+	fs.StringVar(&cfg.excludeTypes, "exclude-types", "", "Comma-separated media types to skip: gif, static or video")
+	fs.StringVar(&cfg.fromFile, "from-file", "", "Load posts from a file written by --export-posts instead of fetching them from Reddit")
+	fs.StringVar(&cfg.fixturesDir, "fixtures-dir", "", "Read each subreddit's listing from r_<subreddit>.json in this directory instead of Reddit, and serve image URLs on "+fixtureHost+" from its files, for offline demos")
+	fs.StringVar(&cfg.exportPosts, "export-posts", "", "Write the fetched posts as JSON to this path, for later use with --from-file")
+	fs.StringVar(&cfg.saveJSONListing, "save-json-listing", "", "Write the raw JSON of every listing page fetched into this directory, one file per page, for bug reports or --fixtures-dir")
+	fs.BoolVar(&cfg.previewOnly, "preview-only", false, "Only load Reddit's scaled down preview images, never the full-size originals; posts without previews are skipped")
+	fs.BoolVar(&cfg.bestQuality, "best-quality", false, "Load the largest rendition Reddit offers among a post's preview variants")
+	fs.BoolVar(&cfg.onlyNew, "only-new", false, "Skip posts surfaced by earlier --only-new runs, fetching further pages to reach new ones; the ids are kept in the download directory")
+	fs.BoolVar(&cfg.dedupeAcrossRuns, "dedupe-across-runs", false, "Skip images that look like one seen in this or an earlier run, using perceptual hashes kept in the download directory")
+	fs.IntVar(&cfg.dedupeDistance, "dedupe-distance", 4, "Largest number of differing hash bits (0-64) at which --dedupe-across-runs treats two images as the same")
+	fs.BoolVar(&cfg.verbose, "verbose", false, "Log the time each image spends in every phase and print a per-phase summary")
+	fs.DurationVar(&cfg.deadline, "deadline", 0, "Stop all fetching and downloading after this long and show what was loaded (0 for no limit)")
+}
+
+func registerSaveFlags(fs *flag.FlagSet, cfg *config) {
+	fs.BoolVar(&cfg.resume, "resume", false, "Skip posts already in the download manifest and fetch further pages to reach new ones (requires --download)")
+	fs.StringVar(&cfg.filenameTemplate, "save-filename-template", defaultFilenameTemplate, "Name for saved images, using {id}, {title}, {subreddit}, {author}, {score}, {index} (gallery position, appended to gallery images when left out) and {ext}")
+	fs.StringVar(&cfg.filenameSource, "filename-source", "", "Name saved images after the post's title, its unique id, or both (title, id or title-id); a shorthand for --save-filename-template")
+	fs.StringVar(&cfg.zipPath, "zip", "", "Write saved images, each with a JSON metadata sidecar, into this zip archive instead of the download directory")
+	fs.BoolVar(&cfg.openAfter, "open-after", false, "Open the download directory (or the folder holding --zip) in the file manager when the run ends")
+	fs.BoolVar(&cfg.saveIndex, "save-index", false, "Write an index.html gallery of the saved images to the download directory")
+	fs.StringVar(&cfg.contactSheet, "contact-sheet", "", "Write a PNG contact sheet of all fetched images to this path")
+	fs.IntVar(&cfg.sheetColumns, "sheet-columns", 5, "Number of columns in the contact sheet")
+	fs.IntVar(&cfg.sheetRows, "sheet-rows", 0, "Most rows in the contact sheet, leaving out the images beyond them (0 for as many rows as the images need)")
+	fs.BoolVar(&cfg.sheetCrop, "crop", false, "Centre-crop contact sheet thumbnails to fill their square cells instead of padding them")
+	fs.StringVar(&cfg.background, "background", "", "Hex colour (e.g. #202020) filling the padding around images in grid mode and the contact sheet, and transparent areas with --flatten-alpha")
+	fs.BoolVar(&cfg.stripMetadata, "strip-metadata", false, "Remove EXIF (including GPS), XMP, IPTC and comments from saved images, without re-encoding them")
+	fs.BoolVar(&cfg.flattenAlpha, "flatten-alpha", false, "Fill transparent areas with --background (white by default) when saving JPEGs, instead of black")
+}
+
+func registerWatchFlags(fs *flag.FlagSet, cfg *config) {
+	fs.BoolVar(&cfg.watch, "watch", false, "Keep polling the subreddits and handle new posts as they appear")
+	fs.DurationVar(&cfg.pollInterval, "poll-interval", 2*time.Minute, "How often --watch re-fetches the subreddits")
+}
+
+func registerShowFlags(fs *flag.FlagSet, cfg *config) {
+	fs.StringVar(&cfg.theme, "theme", "auto", "Colour theme: auto, light or dark")
+	fs.StringVar(&cfg.fit, "fit", "original", "How images fill their card: original, contain or stretch; scaling a 400px thumbnail up blurs it, see --no-resize")
+	fs.Float64Var(&cfg.titleFontSize, "title-font-size", float64(defaultTitleStyle.Size), fmt.Sprintf("Text size of post titles, from %g to %g", minTitleFontSize, maxTitleFontSize))
+	fs.BoolVar(&cfg.titleBold, "title-bold", defaultTitleStyle.Bold, "Show post titles in bold")
+	fs.Float64Var(&cfg.cardSpacing, "card-spacing", 0, "Extra space in pixels below each card in the feed")
+	fs.IntVar(&cfg.columns, "columns", 0, "Show images in a grid with this many columns (0 for a single list)")
+	fs.BoolVar(&cfg.responsive, "columns-responsive", false, "Adapt the number of grid columns to the window width")
+	fs.IntVar(&cfg.minCellWidth, "min-cell-width", 420, "Minimum cell width in pixels for --columns-responsive")
+	fs.Float64Var(&cfg.scrollTopThreshold, "scroll-top-threshold", float64(scrollTopThreshold), "Show a scroll-to-top button once the feed is scrolled this many pixels (0 to disable)")
+	fs.BoolVar(&cfg.noResize, "no-resize", false, "Show images at native resolution instead of 400px wide (uses much more memory)")
+	fs.BoolVar(&cfg.includeText, "include-text", false, "Also show text posts, as cards with their title and body")
+	fs.StringVar(&cfg.placeholder, "placeholder", "", "Show posts whose image fails to load with this image file, or \"builtin\" for a generated broken-image card, instead of leaving them out")
+	fs.BoolVar(&cfg.markdown, "markdown", false, "Render the body of text posts shown with --include-text as markdown (headings, links, emphasis, lists)")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCommandSubcommands(t *testing.T) {
+	tests := []struct {
+		args     []string
+		command  string
+		download bool
+		check    func(*config) bool
+	}{
+		{nil, "show", false, func(c *config) bool { return c.subreddit == "archlinux" && c.limit == 25 }},
+		{[]string{"--subreddit=pics", "--limit=5"}, "show", false, func(c *config) bool { return c.subreddit == "pics" && c.limit == 5 }},
+		{[]string{"show", "--theme=dark", "--download"}, "show", true, func(c *config) bool { return c.theme == "dark" }},
+		{[]string{"download", "--subreddit=aww", "--json-output", "--resume"}, "download", true, func(c *config) bool { return c.jsonOutput && c.resume }},
+		{[]string{"download", "--watch", "--poll-interval=30s"}, "download", true, func(c *config) bool { return c.watch && c.pollInterval.Seconds() == 30 }},
+		{[]string{"list", "--subreddit=a,b", "--concurrency=2"}, "list", false, func(c *config) bool { return c.subreddit == "a,b" && c.concurrency == 2 }},
+		{[]string{"formats"}, "formats", false, func(*config) bool { return true }},
+	}
+	for _, tt := range tests {
+		cfg, err := parseCommand(tt.args)
+		if err != nil {
+			t.Errorf("parseCommand(%q): %v", tt.args, err)
+			continue
+		}
+		if cfg.command != tt.command || cfg.download != tt.download || !tt.check(cfg) {
+			t.Errorf("parseCommand(%q) = %+v, want command %s with download %v", tt.args, cfg, tt.command, tt.download)
+		}
+	}
+}
+
+func TestParseCommandErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"browse"}, "unknown command"},
+		{[]string{"list", "pics"}, "unexpected arguments"},
+		{[]string{"show", "--resume"}, "--resume requires --download"},
+		{[]string{"show", "--zip=out.zip"}, "--zip requires --download"},
+		{[]string{"download", "--zip=out.zip", "--resume"}, "--zip cannot be used"},
+		// Flags belong to the subcommands they make sense for.
+		{[]string{"download", "--theme=dark"}, "not defined"},
+		{[]string{"list", "--download"}, "not defined"},
+		{[]string{"list", "--watch"}, "not defined"},
+		{[]string{"show", "--json-output"}, "not defined"},
+	}
+	for _, tt := range tests {
+		_, err := parseCommand(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseCommand(%q) = %v, want an error containing %q", tt.args, err, tt.want)
+		}
+	}
+}
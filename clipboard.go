@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// imageClipboard puts image data on the system clipboard. Fyne's clipboard
+// only holds text, so images go through the platform's own tools.
+type imageClipboard interface {
+	// CopyImage places data, a PNG file, on the clipboard.
+	CopyImage(data []byte) error
+}
+
+// systemClipboard is where "Copy image" puts images.
+var systemClipboard imageClipboard = commandClipboard{goos: runtime.GOOS}
+
+// commandClipboard copies images by running the clipboard tool of goos on
+// a temporary file: osascript on macOS, PowerShell on Windows and xclip, or
+// wl-copy under Wayland, elsewhere.
+type commandClipboard struct {
+	goos string
+}
+
+func (c commandClipboard) CopyImage(data []byte) error {
+	tmp, err := os.CreateTemp("", "image-scroller-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	name, args := clipboardImageCommand(c.goos, tmp.Name(), os.Getenv("WAYLAND_DISPLAY") != "")
+	cmd := exec.Command(name, args...)
+	// xclip and wl-copy fork a process that holds the selection and keeps
+	// the inherited stderr open, so waiting for it to close would last
+	// until another program takes the clipboard. Stdout is left unset, and
+	// stderr is given up on shortly after the tool itself exits.
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = clipboardWaitDelay
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		return fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// clipboardWaitDelay is how long CopyImage reads the clipboard tool's
+// stderr after the tool exits.
+const clipboardWaitDelay = 500 * time.Millisecond
+
+// clipboardImageCommand returns the program and arguments that put the PNG
+// file at path on the clipboard on goos.
+func clipboardImageCommand(goos, path string, wayland bool) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "osascript", []string{"-e", fmt.Sprintf(`set the clipboard to (read (POSIX file %q) as «class PNGf»)`, path)}
+	case "windows":
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms, System.Drawing; [System.Windows.Forms.Clipboard]::SetImage([System.Drawing.Image]::FromFile('%s'))`, strings.ReplaceAll(path, "'", "''"))
+		return "powershell", []string{"-NoProfile", "-STA", "-Command", script}
+	}
+	if wayland {
+		return "sh", []string{"-c", `wl-copy --type image/png < "$1"`, "sh", path}
+	}
+	return "xclip", []string{"-selection", "clipboard", "-t", "image/png", "-i", path}
+}
+
+// copyImage encodes img as PNG and puts it on clipboard.
+func copyImage(clipboard imageClipboard, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	return clipboard.CopyImage(buf.Bytes())
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/test"
+)
+
+// mockClipboard is an imageClipboard that hands what it is given to copied.
+type mockClipboard struct {
+	copied chan []byte
+	err    error
+}
+
+func (c *mockClipboard) CopyImage(data []byte) error {
+	c.copied <- data
+	return c.err
+}
+
+func TestCopyImagePassesPNGToClipboard(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.RGBA{0x12, 0x34, 0x56, 0xff})
+	clip := &mockClipboard{copied: make(chan []byte, 1)}
+	if err := copyImage(clip, img); err != nil {
+		t.Fatalf("copyImage: %v", err)
+	}
+	got, err := png.Decode(bytes.NewReader(<-clip.copied))
+	if err != nil {
+		t.Fatalf("clipboard did not get a PNG: %v", err)
+	}
+	assertSamePixels(t, got, img)
+
+	clip.err = errors.New("no clipboard tool")
+	if err := copyImage(clip, img); !errors.Is(err, clip.err) {
+		t.Errorf("copyImage err = %v, want the clipboard's error", err)
+	}
+}
+
+func TestCopyImageMenuItemUsesSystemClipboard(t *testing.T) {
+	old := systemClipboard
+	t.Cleanup(func() { systemClipboard = old })
+	clip := &mockClipboard{copied: make(chan []byte, 1)}
+	systemClipboard = clip
+
+	app := test.NewApp()
+	defer app.Quit()
+	w := app.NewWindow("feed")
+	full := solidImage(6, 4, color.Black)
+	thumb := solidImage(3, 2, color.Black)
+
+	pic := canvas.NewImageFromImage(thumb)
+	_, cover := newSpoilerImage(pic, thumb)
+	for _, spoiler := range []bool{true, false} {
+		var c *spoilerCover
+		if spoiler {
+			c = cover
+		}
+		card := selectableCard(w, &cardSelection{}, pic, c, "https://i.redd.it/a.png", "A", full).(*tappableCard)
+		i := slices.IndexFunc(card.menu.Items, func(item *fyne.MenuItem) bool { return item.Label == "Copy image" })
+		if i < 0 {
+			t.Fatal("card menu has no Copy image item")
+		}
+		card.menu.Items[i].Action()
+
+		select {
+		case data := <-clip.copied:
+			if spoiler {
+				t.Fatal("a covered spoiler was copied")
+			}
+			got, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The full image is copied, not the card's thumbnail.
+			if got.Bounds().Size() != image.Pt(6, 4) {
+				t.Errorf("copied a %v image, want the full 6x4", got.Bounds().Size())
+			}
+		case <-time.After(200 * time.Millisecond):
+			if !spoiler {
+				t.Error("Copy image put nothing on the clipboard")
+			}
+		}
+	}
+}
+
+func TestClipboardImageCommand(t *testing.T) {
+	tests := []struct {
+		goos    string
+		wayland bool
+		want    string
+	}{
+		{"darwin", false, "osascript"},
+		{"windows", false, "powershell"},
+		{"linux", false, "xclip"},
+		{"linux", true, "sh"},
+	}
+	for _, tt := range tests {
+		name, args := clipboardImageCommand(tt.goos, "/tmp/x.png", tt.wayland)
+		if name != tt.want {
+			t.Errorf("clipboardImageCommand(%q, wayland %v) runs %s, want %s", tt.goos, tt.wayland, name, tt.want)
+		}
+		if !slices.ContainsFunc(args, func(a string) bool { return strings.Contains(a, "/tmp/x.png") }) {
+			t.Errorf("clipboardImageCommand(%q) args %q do not name the file", tt.goos, args)
+		}
+	}
+}
+
+// fakeClipboardTool puts an executable script named xclip first on PATH.
+// The script writes its last argument, the file to copy, to the returned
+// log before running body.
+func fakeClipboardTool(t *testing.T, body string) (log string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	log = filepath.Join(dir, "args")
+	script := "#!/bin/sh\nfor last; do :; done\necho \"$last\" > " + log + "\n" + body + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "xclip"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("WAYLAND_DISPLAY", "")
+	return log
+}
+
+func TestCommandClipboardReturnsWhileToolHoldsSelection(t *testing.T) {
+	// Like xclip, leave a background process holding stdout and stderr.
+	log := fakeClipboardTool(t, "sleep 10 &\nexit 0")
+
+	done := make(chan error, 1)
+	go func() { done <- commandClipboard{goos: "linux"}.CopyImage([]byte("png")) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("CopyImage: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CopyImage waited for the background process")
+	}
+
+	path, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(strings.TrimSpace(string(path))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file %s left behind: %v", path, err)
+	}
+}
+
+func TestCommandClipboardReportsToolError(t *testing.T) {
+	fakeClipboardTool(t, "echo 'Error: Can'\\''t open display' >&2\nexit 1")
+	err := commandClipboard{goos: "linux"}.CopyImage([]byte("png"))
+	if err == nil || !strings.Contains(err.Error(), "xclip") || !strings.Contains(err.Error(), "open display") {
+		t.Errorf("CopyImage = %v, want xclip's error message", err)
+	}
+}
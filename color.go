@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// parseHexColor parses a CSS-style hex colour: #RGB, #RRGGBB or #RRGGBBAA,
+// with or without the leading '#'.
+func parseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q: want #RGB, #RRGGBB or #RRGGBBAA", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q: %w", s, err)
+	}
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// subredditPalette holds the badge colours used to tell subreddits apart in
+// a merged feed. They are all dark enough for white text.
+var subredditPalette = []color.RGBA{
+	{R: 0xd3, G: 0x2f, B: 0x2f, A: 0xff},
+	{R: 0x19, G: 0x76, B: 0xd2, A: 0xff},
+	{R: 0x38, G: 0x8e, B: 0x3c, A: 0xff},
+	{R: 0x7b, G: 0x1f, B: 0xa2, A: 0xff},
+	{R: 0xf5, G: 0x7c, B: 0x00, A: 0xff},
+	{R: 0x00, G: 0x83, B: 0x8f, A: 0xff},
+	{R: 0xc2, G: 0x18, B: 0x5b, A: 0xff},
+	{R: 0x5d, G: 0x40, B: 0x37, A: 0xff},
+	{R: 0x30, G: 0x3f, B: 0x9f, A: 0xff},
+	{R: 0x68, G: 0x9f, B: 0x38, A: 0xff},
+}
+
+// colorForSubreddit returns the badge colour for a subreddit. The same name
+// always maps to the same colour, ignoring case.
+func colorForSubreddit(name string) color.Color {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(name)))
+	return subredditPalette[h.Sum32()%uint32(len(subredditPalette))]
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.RGBA
+	}{
+		{"#ffffff", color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{"202020", color.RGBA{0x20, 0x20, 0x20, 0xff}},
+		{"#F0a", color.RGBA{0xff, 0x00, 0xaa, 0xff}},
+		{" #11223380 ", color.RGBA{0x11, 0x22, 0x33, 0x80}},
+	}
+	for _, tt := range tests {
+		if got, err := parseHexColor(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseHexColor(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "#12", "#12345", "#gggggg", "red"} {
+		if _, err := parseHexColor(bad); err == nil {
+			t.Errorf("parseHexColor(%q) accepted an invalid colour", bad)
+		}
+	}
+}
+
+func TestColorForSubreddit(t *testing.T) {
+	if colorForSubreddit("pics") != colorForSubreddit("pics") {
+		t.Error("the same name maps to different colours")
+	}
+	if colorForSubreddit("EarthPorn") != colorForSubreddit("earthporn") {
+		t.Error("the colour depends on case")
+	}
+
+	names := []string{"pics", "earthporn", "aww", "wallpapers", "itookapicture", "cityporn", "spaceporn", "foodporn"}
+	colors := make(map[color.Color]bool)
+	for _, name := range names {
+		colors[colorForSubreddit(name)] = true
+	}
+	if len(colors) < len(names)/2 {
+		t.Errorf("%d subreddits share only %d colours", len(names), len(colors))
+	}
+}
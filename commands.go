@@ -0,0 +1,689 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+)
+
+// listingFetcher fetches up to limit posts of one subreddit.
+type listingFetcher func(ctx context.Context, client *http.Client, subreddit string, limit int, skip func(Post) bool) ([]Post, error)
+
+// runner holds what every command sets up from the configuration: the
+// clients, the subreddits to fetch and, when saving, the manifest.
+type runner struct {
+	cfg *config
+	ctx context.Context
+
+	redditClient *http.Client
+	dl           *downloader
+	saved        *manifest
+	subreddits   []string
+	fetchListing listingFetcher
+	skip         func(Post) bool
+	background   color.Color
+	filenames    *filenameTemplate
+	filter       imageFilter
+	types        mediaTypeFilter
+	fit          canvas.ImageFill
+	title        titleStyle
+	hashes       *hashStore
+	seenIDs      *seenIDStore
+	placeholder  image.Image
+	saver        Saver
+	timings      *phaseTimings
+	// expanded counts the images handed out by expand, for the summary.
+	expanded atomic.Int64
+}
+
+// newRunner validates cfg and builds the shared state for a run bounded by
+// ctx.
+func newRunner(ctx context.Context, cfg *config) (*runner, error) {
+	r := &runner{cfg: cfg, ctx: ctx, background: color.White, fit: canvas.ImageFillOriginal, title: defaultTitleStyle}
+
+	if cfg.cleanTitles {
+		re, err := regexp.Compile(cfg.titlePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --title-pattern: %w", err)
+		}
+		titleTagRe = re
+	}
+
+	if cfg.background != "" {
+		bg, err := parseHexColor(cfg.background)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --background: %w", err)
+		}
+		r.background = bg
+	}
+	if cfg.contactSheet != "" {
+		if cfg.sheetColumns < 1 {
+			return nil, fmt.Errorf("invalid --sheet-columns: must be at least 1")
+		}
+		if cfg.sheetRows < 0 {
+			return nil, fmt.Errorf("invalid --sheet-rows: %d is negative", cfg.sheetRows)
+		}
+	}
+
+	base, err := parseBaseURL(cfg.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --base-url: %w", err)
+	}
+	redditBaseURL = base
+	listingDumpDir = cfg.saveJSONListing
+
+	statuses, err := parseRetryStatuses(cfg.retryStatus)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --retry-status: %w", err)
+	}
+	retryableStatuses = statuses
+	listingRetries = cfg.retries
+	if cfg.maxPages <= 0 {
+		return nil, fmt.Errorf("invalid --max-pages: must be at least 1")
+	}
+	maxListingPages = cfg.maxPages
+
+	template := cfg.filenameTemplate
+	if template == "" {
+		// list registers no save flags, so nothing set the default.
+		template = defaultFilenameTemplate
+	}
+	if cfg.filenameSource != "" {
+		template, err = filenameSourceTemplate(cfg.filenameSource)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --filename-source: %w", err)
+		}
+	}
+	filenames, err := parseFilenameTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	r.filenames = filenames
+
+	if r.types, err = newMediaTypeFilter(cfg.includeTypes, cfg.excludeTypes); err != nil {
+		return nil, err
+	}
+
+	if cfg.command == "show" {
+		if cfg.titleFontSize < minTitleFontSize || cfg.titleFontSize > maxTitleFontSize {
+			return nil, fmt.Errorf("invalid --title-font-size: %g is not between %g and %g", cfg.titleFontSize, minTitleFontSize, maxTitleFontSize)
+		}
+		r.title = titleStyle{Size: float32(cfg.titleFontSize), Bold: cfg.titleBold}
+		r.placeholder = loadPlaceholder(cfg.placeholder)
+		if cfg.cardSpacing < 0 {
+			return nil, fmt.Errorf("invalid --card-spacing: %g is negative", cfg.cardSpacing)
+		}
+	}
+
+	if cfg.fit != "" {
+		if r.fit, err = fitModeForName(cfg.fit); err != nil {
+			return nil, fmt.Errorf("invalid --fit: %w", err)
+		}
+	}
+
+	if cfg.verbose {
+		r.timings = newPhaseTimings()
+	}
+
+	if r.filter, err = newImageFilter(cfg.grayscale, cfg.palette); err != nil {
+		return nil, fmt.Errorf("invalid --palette: %w", err)
+	}
+
+	switch cfg.galleries {
+	case "flatten", "collapse":
+	default:
+		return nil, fmt.Errorf("invalid --galleries %q (want flatten or collapse)", cfg.galleries)
+	}
+
+	switch cfg.format {
+	case "json":
+		r.fetchListing = fetchRedditData
+	case "rss":
+		r.fetchListing = fetchRedditRSS
+	default:
+		return nil, fmt.Errorf("invalid --format %q (want json or rss)", cfg.format)
+	}
+	if cfg.fixturesDir != "" {
+		r.fetchListing = fixtureListing(cfg.fixturesDir)
+	}
+
+	if cfg.subreddit == "-" {
+		names, err := readSubreddits(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("error reading subreddits from stdin: %w", err)
+		}
+		r.subreddits = names
+	} else {
+		r.subreddits = normalizeSubreddits(strings.Split(cfg.subreddit, ","))
+	}
+	if len(r.subreddits) == 0 {
+		return nil, errors.New("no subreddits given")
+	}
+
+	var transport http.RoundTripper = newUserAgentTransport(newTransport(cfg.insecure, cfg.dialTimeout, cfg.tlsTimeout), cfg.userAgent)
+	if cfg.fixturesDir != "" {
+		transport = newFixtureTransport(transport, cfg.fixturesDir)
+	}
+	r.redditClient = newRedditClient(transport)
+	var allow hostAllowlist
+	if cfg.restrictHosts || cfg.imageHosts != "" {
+		hosts := defaultImageHosts
+		if cfg.imageHosts != "" {
+			hosts = strings.Split(cfg.imageHosts, ",")
+		}
+		allow = newHostAllowlist(hosts)
+	}
+	r.dl = newDownloader(newImageClient(transport, cfg.followRedirects, allow), cfg.maxDownloadBytes, cfg.retries)
+	r.dl.allow = allow
+	r.dl.hosts = newHostLimiter(cfg.perHostConcurrency)
+	r.dl.spacer = newRequestSpacer(cfg.delay)
+	r.dl.retryBudget = newRetryBudget(cfg.retryBudget)
+	r.dl.headCheck = cfg.headCheck
+	r.dl.maxImageBytes = cfg.maxImageBytes
+
+	if cfg.download && cfg.zipPath != "" {
+		if r.saver, err = newZipSaver(cfg.zipPath); err != nil {
+			return nil, err
+		}
+	} else if cfg.download {
+		r.saver = newFSSaver(downloadDir)
+		if err := checkOutputDir(downloadDir); err != nil {
+			return nil, err
+		}
+		r.saved = newManifest(filepath.Join(downloadDir, manifestFileName))
+		if err := r.saved.Load(); err != nil {
+			return nil, fmt.Errorf("error loading manifest: %w", err)
+		}
+		if removed, err := r.saved.Reconcile(downloadDir); err != nil {
+			log.Printf("Failed to reconcile manifest: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d manifest entries whose files are missing", removed)
+		}
+	}
+	if cfg.dedupeAcrossRuns {
+		if cfg.dedupeDistance < 0 || cfg.dedupeDistance > 64 {
+			return nil, fmt.Errorf("invalid --dedupe-distance: %d is not between 0 and 64", cfg.dedupeDistance)
+		}
+		r.hashes = newHashStore(filepath.Join(downloadDir, hashIndexFileName), cfg.dedupeDistance)
+		if err := r.hashes.Load(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.onlyNew {
+		r.seenIDs = newSeenIDStore(filepath.Join(downloadDir, seenIDsFileName))
+		if err := r.seenIDs.Load(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.resume || cfg.onlyNew {
+		r.skip = func(p Post) bool {
+			return (cfg.resume && postSaved(r.saved, p)) || r.seenIDs.Has(p)
+		}
+	}
+	return r, nil
+}
+
+// fetchAll fetches the subreddits in parallel, up to --concurrency at a
+// time. A subreddit that fails does not stop the others: the posts of all
+// those that loaded are returned, in subreddit order, along with the
+// failures joined into one error. That also lets a run cut short by
+// --deadline use what it got.
+func (r *runner) fetchAll() ([]Post, error) {
+	perSub := make([][]Post, len(r.subreddits))
+	errs := make([]error, len(r.subreddits))
+	slots := make(chan struct{}, max(r.cfg.concurrency, 1))
+	var wg sync.WaitGroup
+	for i, name := range r.subreddits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			log.Println("Fetching data from subreddit:", name)
+			start := time.Now()
+			subPosts, err := r.fetchListing(r.ctx, r.redditClient, name, r.cfg.limit, r.skip)
+			r.timings.Since("r/"+name, "fetch", start)
+			for j := range subPosts {
+				if subPosts[j].Subreddit == "" {
+					subPosts[j].Subreddit = name
+				}
+			}
+			perSub[i] = subPosts
+			if err != nil {
+				errs[i] = fmt.Errorf("r/%s: %w", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var posts []Post
+	for _, subPosts := range perSub {
+		posts = append(posts, subPosts...)
+	}
+	return posts, errors.Join(errs...)
+}
+
+// fetchInitial is fetchAll for the start of a run, where failing to fetch
+// any posts at all is fatal. With --from-file the posts are
+// loaded from the file instead, with --sample a random subset is kept, and
+// with --export-posts they are written out before being returned. With
+// --only-new the posts returned are marked as seen.
+func (r *runner) fetchInitial() ([]Post, error) {
+	posts, err := r.fetchInitialPosts()
+	if err != nil {
+		return nil, err
+	}
+	if r.cfg.sample > 0 {
+		seed := r.cfg.seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		posts = sample(posts, r.cfg.sample, rand.New(rand.NewSource(seed)))
+		log.Printf("Sampled %d posts (seed %d)", len(posts), seed)
+	}
+	r.seenIDs.Mark(posts)
+	if r.cfg.exportPosts != "" {
+		if err := exportPosts(r.cfg.exportPosts, posts); err != nil {
+			return nil, fmt.Errorf("error exporting posts: %w", err)
+		}
+		log.Printf("Exported %d posts to %s", len(posts), r.cfg.exportPosts)
+	}
+	return posts, nil
+}
+
+func (r *runner) fetchInitialPosts() ([]Post, error) {
+	if r.cfg.fromFile != "" {
+		posts, err := loadPostsFile(r.cfg.fromFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading posts: %w", err)
+		}
+		var kept []Post
+		for _, p := range posts {
+			if r.skip == nil || !r.skip(p) {
+				kept = append(kept, p)
+			}
+		}
+		log.Printf("Loaded %d posts from %s", len(kept), r.cfg.fromFile)
+		return kept, nil
+	}
+
+	posts, err := r.fetchAll()
+	if errors.Is(err, context.DeadlineExceeded) && len(posts) > 0 {
+		log.Printf("Deadline exceeded while fetching; continuing with the %d posts fetched so far", len(posts))
+		return posts, nil
+	}
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Interrupted while fetching; stopping with the %d posts fetched so far", len(posts))
+		return posts, nil
+	}
+	if err != nil && len(posts) > 0 {
+		log.Printf("Some subreddits failed to load; continuing with the %d posts fetched: %v", len(posts), err)
+		return posts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching data: %w", err)
+	}
+	return posts, nil
+}
+
+// expand turns fetched posts into the list of images to load: galleries are
+// expanded, or with --galleries collapse reduced to their first image, with
+// --best-quality each image is swapped for its largest preview rendition,
+// with --preview-only for its preview closest to the card width, dropping
+// those without one, and repeated image URLs are dropped.
+func (r *runner) expand(posts []Post) []Post {
+	posts = expandGalleries(posts, r.cfg.galleries == "collapse")
+	if r.cfg.bestQuality {
+		for i, p := range posts {
+			if u := p.bestQualityURL(); u != "" {
+				posts[i].URL = u
+			}
+		}
+	}
+	if r.cfg.previewOnly {
+		var previews []Post
+		for _, p := range posts {
+			if u := p.previewURL(cardWidth); u != "" {
+				p.URL = u
+				previews = append(previews, p)
+			}
+		}
+		posts = previews
+	}
+	posts = dedupeByURL(posts)
+	r.expanded.Add(int64(len(posts)))
+	return posts
+}
+
+// close finishes the run's output: it writes the --only-new store, flushes
+// the manifest and closes savers such as the --zip archive that need it.
+func (r *runner) close() error {
+	if err := r.seenIDs.Flush(); err != nil {
+		return err
+	}
+	if r.saved != nil {
+		if err := r.saved.Flush(); err != nil {
+			return fmt.Errorf("error writing manifest: %w", err)
+		}
+	}
+	if c, ok := r.saver.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// outputDir returns the directory saved images end up in: the download
+// directory, or the one holding the --zip archive.
+func (r *runner) outputDir() string {
+	if r.cfg.zipPath != "" {
+		return filepath.Dir(r.cfg.zipPath)
+	}
+	return downloadDir
+}
+
+// flatten returns the colour JPEGs are flattened onto, or nil without
+// --flatten-alpha.
+func (r *runner) flatten() color.Color {
+	if !r.cfg.flattenAlpha {
+		return nil
+	}
+	return r.background
+}
+
+// newSession returns a session for processing posts. w is the window cards
+// are shown in, or nil for headless commands, which then build no cards.
+func (r *runner) newSession(w fyne.Window) *session {
+	return &session{
+		opts: sessionOptions{
+			download:        r.cfg.download,
+			resume:          r.cfg.resume,
+			cleanTitles:     r.cfg.cleanTitles,
+			subredditBadges: len(r.subreddits) > 1,
+			filenames:       r.filenames,
+			includeText:     r.cfg.includeText,
+			markdown:        r.cfg.markdown,
+			noResize:        r.cfg.noResize,
+			filter:          r.filter,
+			maxPixels:       r.cfg.maxPixels,
+			flatten:         r.flatten(),
+			types:           r.types,
+			fit:             r.fit,
+			title:           r.title,
+			placeholder:     r.placeholder,
+			stripMetadata:   r.cfg.stripMetadata,
+		},
+		dl:        r.dl,
+		saved:     r.saved,
+		saver:     r.saver,
+		timings:   r.timings,
+		hashes:    r.hashes,
+		buffer:    newDecodeBuffer(r.cfg.decodeBufferBytes),
+		decodes:   newDecodeLimiter(r.cfg.maxDecodes),
+		resolvers: newResolverRegistry(newRedgifsResolver(r.redditClient), gfycatResolver{}),
+		stats:     newRunStats(),
+		win:       w,
+		selection: &cardSelection{},
+	}
+}
+
+// process runs posts through sess and reports on the run: the budget and
+// deadline messages, byte and format tallies, and the contact sheet and
+// HTML index when requested. It returns the results in post order.
+func (r *runner) process(sess *session, posts []Post) []*postResult {
+	results, budgetSkipped := sess.processPosts(r.ctx, posts, r.cfg.concurrency, r.cfg.decodeConcurrency)
+	if budgetSkipped > 0 {
+		log.Printf("Download budget of %d bytes reached; skipped the remaining %d posts", r.cfg.maxDownloadBytes, budgetSkipped)
+	}
+	if errors.Is(r.ctx.Err(), context.DeadlineExceeded) {
+		log.Printf("Deadline of %s exceeded; showing partial results", r.cfg.deadline)
+	}
+	if errors.Is(r.ctx.Err(), context.Canceled) {
+		log.Printf("Interrupted; finished saving the images already downloaded")
+	}
+
+	log.Printf("Downloaded %d bytes of images", r.dl.bytes.Total())
+	sess.stats.WriteReport(os.Stderr)
+	r.timings.WriteReport(os.Stderr)
+
+	if r.cfg.contactSheet != "" {
+		var sheetImages []image.Image
+		var sheetCaptions []string
+		for _, result := range results {
+			if result != nil && result.thumb != nil {
+				thumb := result.thumb
+				if r.cfg.sheetCrop {
+					// Cells are square.
+					thumb = centerCrop(thumb, 1)
+				}
+				sheetImages = append(sheetImages, thumb)
+				sheetCaptions = append(sheetCaptions, result.title)
+			}
+		}
+		if len(sheetImages) > 0 {
+			if limit := r.cfg.sheetColumns * r.cfg.sheetRows; r.cfg.sheetRows > 0 && len(sheetImages) > limit {
+				log.Printf("Contact sheet holds %d of the %d images", limit, len(sheetImages))
+			}
+			sheet := buildContactSheet(sheetImages, sheetCaptions, r.cfg.sheetColumns, r.cfg.sheetRows, r.background)
+			if err := writeContactSheet(r.cfg.contactSheet, sheet); err != nil {
+				log.Printf("Failed to write contact sheet: %v", err)
+			} else {
+				log.Printf("Saved contact sheet: %s", r.cfg.contactSheet)
+			}
+		}
+	}
+
+	r.writeIndex(sess)
+	return results
+}
+
+// writeIndex rewrites the --save-index gallery, if requested. It lists
+// every image in the manifest, so those saved by earlier runs too, or only
+// those sess saved when there is no manifest, as with --zip.
+func (r *runner) writeIndex(sess *session) {
+	if !r.cfg.saveIndex || !r.cfg.download {
+		return
+	}
+	var posts []Post
+	if r.saved != nil {
+		for _, e := range r.saved.Entries() {
+			posts = append(posts, Post{Title: e.Title, File: e.File, Permalink: e.Permalink})
+		}
+	} else {
+		sess.mu.Lock()
+		posts = slices.Clone(sess.savedPosts)
+		sess.mu.Unlock()
+	}
+	if err := writeHTMLIndex(downloadDir, posts); err != nil {
+		log.Printf("Failed to write HTML index: %v", err)
+	} else {
+		log.Printf("Saved HTML index: %s", filepath.Join(downloadDir, indexFileName))
+	}
+}
+
+// watch polls for posts not in seen and passes each processed one, oldest
+// first, to handle. Progress is reported on sess.events like processPosts
+// does, each poll finishing with a Done event. It blocks until the run's
+// context is done. The HTML index is rewritten after every poll.
+func (r *runner) watch(sess *session, seen *seenPosts, handle func(*postResult)) {
+	watchPosts(r.ctx, r.cfg.pollInterval, seen, r.fetchAll, func(fresh []Post) {
+		r.seenIDs.Mark(fresh)
+		fresh = r.expand(fresh)
+		defer sess.emit(Event{Kind: Done})
+		defer r.writeIndex(sess)
+		for i := len(fresh) - 1; i >= 0; i-- {
+			sess.emit(Event{Kind: PostFetched, Post: fresh[i]})
+			result, err := sess.processPost(r.ctx, fresh[i])
+			if err != nil {
+				sess.emit(Event{Kind: ImageFailed, Post: fresh[i], Err: err})
+			} else {
+				sess.emit(Event{Kind: ImageDownloaded, Post: fresh[i]})
+			}
+			if errors.Is(err, errDownloadBudget) {
+				return
+			}
+			if result != nil {
+				handle(result)
+			}
+		}
+	})
+}
+
+// runShow implements the show command: the scrolling image window.
+func runShow(r *runner) error {
+	cfg := r.cfg
+	appTheme, err := themeForName(cfg.theme)
+	if err != nil {
+		return fmt.Errorf("invalid --theme: %w", err)
+	}
+
+	scrollTopThreshold = float32(cfg.scrollTopThreshold)
+
+	a := app.New()
+	if appTheme != nil {
+		// Set before any widgets are built so theme.ForegroundColor() used for
+		// the titles resolves against the forced variant.
+		a.Settings().SetTheme(appTheme)
+	}
+	w := a.NewWindow("Reddit Image Feed")
+
+	// Closing the window cancels whatever is still downloading, like
+	// Ctrl+C, so the run can finish before the runner is closed.
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+	r.ctx = ctx
+	go func() {
+		// Close the window on Ctrl+C in the terminal, but not when the
+		// deadline passes; the partial feed stays up then.
+		<-r.ctx.Done()
+		if errors.Is(r.ctx.Err(), context.Canceled) {
+			a.Quit()
+		}
+	}()
+
+	posts, err := r.fetchInitial()
+	if err != nil {
+		return err
+	}
+	seen := newSeenPosts()
+	seen.diff(posts)
+	posts = r.expand(posts)
+
+	feedColumns := cfg.columns
+	if cfg.responsive {
+		// Start with one column; the real count is set on the first layout.
+		feedColumns = 1
+	}
+	content := newFeedContainer(feedColumns)
+	gridMode := cfg.columns > 0 || cfg.responsive
+
+	sess := r.newSession(w)
+	sess.gate = newDownloadGate()
+	status := newStatusBar()
+	events := make(chan Event, 64)
+	sess.events = events
+	go status.run(events)
+	installCopyShortcut(w, sess.selection)
+
+	// Every card goes through decorateCard on its way into the feed, so
+	// the initial cards and those added by --watch look the same.
+	decorateCard := func(card fyne.CanvasObject) fyne.CanvasObject {
+		if gridMode && cfg.background != "" {
+			card = withBackground(card, r.background)
+		}
+		return withSpacing(card, float32(cfg.cardSpacing))
+	}
+
+	// Cards appear as their images finish loading, in post order, while
+	// the window is already up.
+	ordered := &orderedFeed{feed: content}
+	sess.onResult = func(index int, result *postResult) {
+		ordered.insert(index, decorateCard(result.card))
+	}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		r.process(sess, posts)
+		if cfg.watch {
+			// New posts go on top; r.watch hands them over oldest first so
+			// the newest ends up first.
+			r.watch(sess, seen, func(result *postResult) {
+				prependCard(content, decorateCard(result.card))
+			})
+		}
+	}()
+
+	scroll := container.NewScroll(content)
+	var feed fyne.CanvasObject = scroll
+	if cfg.responsive {
+		feed = newResponsiveFeed(scroll, content, cfg.minCellWidth)
+	}
+	w.SetContent(container.NewBorder(newDownloadToolbar(sess.gate), status.Object(), nil, nil, withScrollTopButton(scroll, feed)))
+	w.Resize(fyne.NewSize(800, 600))
+	w.ShowAndRun()
+	cancel()
+	<-finished
+	return nil
+}
+
+// runDownload implements the download command: save images without a
+// window, then keep saving new posts with --watch.
+func runDownload(r *runner) error {
+	posts, err := r.fetchInitial()
+	if err != nil {
+		return err
+	}
+	seen := newSeenPosts()
+	seen.diff(posts)
+
+	sess := r.newSession(nil)
+	r.process(sess, r.expand(posts))
+
+	if r.cfg.watch {
+		r.watch(sess, seen, func(result *postResult) {
+			log.Printf("New post: %s", result.title)
+		})
+	}
+
+	if r.cfg.jsonOutput {
+		if err := r.summarize(sess).writeJSON(os.Stdout); err != nil {
+			return fmt.Errorf("error writing JSON output: %w", err)
+		}
+	}
+	return nil
+}
+
+// runList implements the list command: print the image URL of every post,
+// one per line, with gallery posts expanded.
+func runList(r *runner) error {
+	posts, err := r.fetchInitial()
+	if err != nil {
+		return err
+	}
+	for _, post := range r.expand(posts) {
+		u, err := normalizeURL(post.URL)
+		if err == nil && isValidImageURL(u) {
+			fmt.Println(u)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// addPicsImages serves the images the r_pics fixtures link to.
+func addPicsImages(t *testing.T, m *mockReddit) {
+	for _, name := range []string{"cat.png", "dog.png", "bird.png"} {
+		m.addImage(name, pngBytes(t, 8, 8, color.White))
+	}
+}
+
+// imageRequests returns the image paths m was asked for, in order.
+func imageRequests(m *mockReddit) []string {
+	var images []string
+	for _, uri := range m.requestLog() {
+		if name, ok := strings.CutPrefix(uri, "/img/"); ok {
+			images = append(images, name)
+		}
+	}
+	return images
+}
+
+func TestResumeDownloadsOnlyNewPosts(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	addPicsImages(t, m)
+	chdirTemp(t)
+
+	// The interrupted first run got as far as the first post.
+	first := newMockRunner(t, m, "download", "--subreddit=pics", "--limit=1")
+	if err := runDownload(first); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if err := first.close(); err != nil {
+		t.Fatalf("closing first run: %v", err)
+	}
+	if got := imageRequests(m); !slices.Equal(got, []string{"cat.png"}) {
+		t.Fatalf("first run fetched %v, want [cat.png]", got)
+	}
+
+	before := len(imageRequests(m))
+	second := newMockRunner(t, m, "download", "--subreddit=pics", "--limit=2", "--resume")
+	if err := runDownload(second); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	if err := second.close(); err != nil {
+		t.Fatalf("closing resumed run: %v", err)
+	}
+	got := imageRequests(m)[before:]
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"bird.png", "dog.png"}) {
+		t.Errorf("resumed run fetched %v, want [bird.png dog.png]", got)
+	}
+
+	saved := newManifest(filepath.Join(downloadDir, manifestFileName))
+	if err := saved.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cat.png", "dog.png", "bird.png"} {
+		if !saved.Has(m.URL + "/img/" + name) {
+			t.Errorf("manifest lacks %s", name)
+		}
+	}
+}
+
+func TestDeadlineStopsRunWithPartialResults(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	addPicsImages(t, m)
+	// The second image never arrives.
+	m.handle("/img/dog.png", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	r := newMockRunnerContext(t, ctx, m, "show", "--subreddit=pics", "--concurrency=1")
+	defer r.close()
+	posts, err := r.fetchInitial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	results := r.process(r.newSession(nil), posts)
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run took %s past a 200ms deadline", elapsed)
+	}
+	if !errors.Is(r.ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("run context: %v, want deadline exceeded", r.ctx.Err())
+	}
+	if len(results) != 3 || results[0] == nil {
+		t.Fatalf("results = %v, want the first post loaded", results)
+	}
+	if results[1] != nil || results[2] != nil {
+		t.Error("posts after the deadline have results")
+	}
+	if got := imageRequests(m); slices.Contains(got, "bird.png") {
+		t.Errorf("fetched %v; the post after the deadline was started", got)
+	}
+}
+
+func TestCancelledDownloadShutsDownCleanly(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	addPicsImages(t, m)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Ctrl+C arrives while the second image is downloading.
+	m.handle("/img/dog.png", func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		<-r.Context().Done()
+	})
+	dir := chdirTemp(t)
+
+	r := newMockRunnerContext(t, ctx, m, "download", "--subreddit=pics", "--concurrency=1")
+	if err := runDownload(r); err != nil {
+		t.Fatalf("runDownload after cancel: %v", err)
+	}
+	if err := r.close(); err != nil {
+		t.Fatalf("close after cancel: %v", err)
+	}
+
+	if got := imageRequests(m); slices.Contains(got, "bird.png") {
+		t.Errorf("fetched %v; a download started after the cancel", got)
+	}
+	saved := newManifest(filepath.Join(dir, downloadDir, manifestFileName))
+	if err := saved.Load(); err != nil {
+		t.Fatalf("loading the manifest: %v", err)
+	}
+	if !saved.Has(m.URL + "/img/cat.png") {
+		t.Error("the image saved before the cancel is missing from the manifest")
+	}
+	if saved.Has(m.URL + "/img/dog.png") {
+		t.Error("the aborted download is in the manifest")
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, downloadDir, "*.png"))
+	if len(files) != 1 || filepath.Base(files[0]) != "First_cat_[OC].png" {
+		t.Errorf("saved files = %v, want just the cat", files)
+	}
+	assertNoTempFiles(t, filepath.Join(dir, downloadDir))
+}
+
+func TestFetchAllIsolatesFailingSubreddit(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json", "r_gallery.json")
+	r := newMockRunner(t, m, "show", "--subreddit=pics,missing,gallery", "--limit=0", "--concurrency=3")
+	defer r.close()
+
+	posts, err := r.fetchAll()
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "r/missing") {
+		t.Errorf("err = %v, want r/missing not found", err)
+	}
+	// The good subreddits load in full and keep subreddit order.
+	if got, want := postIDs(posts), []string{"p1", "p2", "p3", "g1", "s1"}; !slices.Equal(got, want) {
+		t.Errorf("posts = %v, want %v", got, want)
+	}
+
+	// The run carries on with what loaded.
+	posts, err = r.fetchInitial()
+	if err != nil || len(posts) != 5 {
+		t.Errorf("fetchInitial = %d posts, %v; want 5 posts and no error", len(posts), err)
+	}
+}
+
+func TestFetchAllFailsWhenEverySubredditFails(t *testing.T) {
+	m := newMockReddit(t, "r_private.403.json")
+	r := newMockRunner(t, m, "show", "--subreddit=private,missing")
+	defer r.close()
+
+	_, err := r.fetchInitial()
+	if !errors.Is(err, ErrSubredditPrivate) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want both subreddits' errors joined", err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// sheetCellSize is the width and height of each thumbnail cell.
+	sheetCellSize = 200
+	// sheetCaptionHeight is the extra height below a cell used for its caption.
+	sheetCaptionHeight = 18
+	// sheetPadding is the gap around and between cells.
+	sheetPadding = 4
+)
+
+// buildContactSheet tiles images into a grid with cols columns, drawing
+// captions[i], if any, under images[i]. Each image is scaled to fit a
+// square cell and centred within it; the sheet, including the letterbox or
+// pillarbox padding around each image, is filled with bg. Captions that do
+// not fit the cell are truncated. With rows above zero the grid has at most
+// that many rows and images beyond them are left out; otherwise it has as
+// many rows as the images need.
+func buildContactSheet(images []image.Image, captions []string, cols, rows int, bg color.Color) image.Image {
+	if cols < 1 {
+		cols = 1
+	}
+	if rows > 0 && len(images) > cols*rows {
+		images = images[:cols*rows]
+	}
+	if len(images) < cols {
+		cols = max(len(images), 1)
+	}
+	rows = (len(images) + cols - 1) / cols
+
+	cellHeight := sheetCellSize
+	if captions != nil {
+		cellHeight += sheetCaptionHeight
+	}
+	width := cols*sheetCellSize + (cols+1)*sheetPadding
+	height := rows*cellHeight + (rows+1)*sheetPadding
+
+	sheet := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	for i, img := range images {
+		x := sheetPadding + (i%cols)*(sheetCellSize+sheetPadding)
+		y := sheetPadding + (i/cols)*(cellHeight+sheetPadding)
+		cell := image.Rect(x, y, x+sheetCellSize, y+sheetCellSize)
+		draw.CatmullRom.Scale(sheet, fitRect(img.Bounds(), cell), img, img.Bounds(), draw.Over, nil)
+
+		if i < len(captions) {
+			drawCaption(sheet, captions[i], x, y+sheetCellSize, captionColor(bg))
+		}
+	}
+	return sheet
+}
+
+// fitRect returns the largest rectangle with src's aspect ratio that fits
+// inside dst, centred within it.
+func fitRect(src, dst image.Rectangle) image.Rectangle {
+	sw, sh := src.Dx(), src.Dy()
+	dw, dh := dst.Dx(), dst.Dy()
+	if sw == 0 || sh == 0 {
+		return image.Rectangle{Min: dst.Min, Max: dst.Min}
+	}
+
+	w, h := dw, sh*dw/sw
+	if h > dh {
+		w, h = sw*dh/sh, dh
+	}
+	x := dst.Min.X + (dw-w)/2
+	y := dst.Min.Y + (dh-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// centerCrop returns the largest centred region of img with the aspect
+// ratio (width over height) targetAspect, for filling cells without
+// letterboxing. The result shares pixels with img where img supports it.
+func centerCrop(img image.Image, targetAspect float64) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 || targetAspect <= 0 {
+		return img
+	}
+
+	cw, ch := w, h
+	if float64(w)/float64(h) > targetAspect {
+		cw = int(float64(h)*targetAspect + 0.5)
+	} else {
+		ch = int(float64(w)/targetAspect + 0.5)
+	}
+	x := b.Min.X + (w-cw)/2
+	y := b.Min.Y + (h-ch)/2
+	r := image.Rect(x, y, x+cw, y+ch)
+
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, cw, ch))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+// captionColor returns black or white, whichever reads better on bg.
+func captionColor(bg color.Color) color.Color {
+	r, g, b, _ := bg.RGBA()
+	// Rec. 601 luma on 16-bit channels.
+	if 299*r+587*g+114*b > 500*0xffff {
+		return color.Black
+	}
+	return color.White
+}
+
+// drawCaption writes text in a single line of basicfont starting at (x, y),
+// truncated to the width of a cell.
+func drawCaption(dst draw.Image, text string, x, y int, fg color.Color) {
+	face := basicfont.Face7x13
+	maxChars := sheetCellSize / face.Advance
+	if runes := []rune(text); len(runes) > maxChars {
+		text = string(runes[:maxChars-1]) + "…"
+	}
+
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(fg),
+		Face: face,
+		Dot:  fixed.P(x, y+face.Ascent+2),
+	}
+	d.DrawString(text)
+}
+
+// writeContactSheet encodes sheet as a PNG at path.
+func writeContactSheet(path string, sheet image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create contact sheet: %w", err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, sheet); err != nil {
+		return fmt.Errorf("failed to encode contact sheet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solidImage(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestBuildContactSheetDimensions(t *testing.T) {
+	tests := []struct {
+		images, cols, rows int
+		captions           bool
+		wantCols, wantRows int
+	}{
+		{images: 7, cols: 3, wantCols: 3, wantRows: 3},
+		{images: 6, cols: 3, wantCols: 3, wantRows: 2},
+		{images: 2, cols: 5, wantCols: 2, wantRows: 1},
+		{images: 1, cols: 0, wantCols: 1, wantRows: 1},
+		{images: 10, cols: 4, rows: 2, wantCols: 4, wantRows: 2},
+		{images: 5, cols: 2, rows: 4, wantCols: 2, wantRows: 3},
+		{images: 4, cols: 2, captions: true, wantCols: 2, wantRows: 2},
+	}
+	for _, tt := range tests {
+		images := make([]image.Image, tt.images)
+		var captions []string
+		for i := range images {
+			images[i] = solidImage(300, 150, color.Black)
+			if tt.captions {
+				captions = append(captions, "caption")
+			}
+		}
+		sheet := buildContactSheet(images, captions, tt.cols, tt.rows, color.White)
+
+		cellHeight := sheetCellSize
+		if tt.captions {
+			cellHeight += sheetCaptionHeight
+		}
+		wantW := tt.wantCols*sheetCellSize + (tt.wantCols+1)*sheetPadding
+		wantH := tt.wantRows*cellHeight + (tt.wantRows+1)*sheetPadding
+		if b := sheet.Bounds(); b.Dx() != wantW || b.Dy() != wantH {
+			t.Errorf("%d images, %d cols, %d rows: sheet is %dx%d, want %dx%d", tt.images, tt.cols, tt.rows, b.Dx(), b.Dy(), wantW, wantH)
+		}
+	}
+}
+
+func TestBuildContactSheetFillsBackground(t *testing.T) {
+	bg := color.RGBA{0x20, 0x20, 0x20, 0xff}
+	sheet := buildContactSheet([]image.Image{solidImage(300, 150, color.White)}, nil, 1, 0, bg)
+
+	// A wide image is letterboxed: the top of its cell stays background.
+	x := sheetPadding + sheetCellSize/2
+	if got := color.RGBAModel.Convert(sheet.At(x, sheetPadding+10)); got != bg {
+		t.Errorf("letterbox pixel = %v, want %v", got, bg)
+	}
+	if got := color.RGBAModel.Convert(sheet.At(x, sheetPadding+sheetCellSize/2)); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("image pixel = %v, want white", got)
+	}
+
+	// A tall one is pillarboxed: the left of its cell stays background.
+	sheet = buildContactSheet([]image.Image{solidImage(150, 300, color.White)}, nil, 1, 0, bg)
+	y := sheetPadding + sheetCellSize/2
+	if got := color.RGBAModel.Convert(sheet.At(sheetPadding+10, y)); got != bg {
+		t.Errorf("pillarbox pixel = %v, want %v", got, bg)
+	}
+	if got := color.RGBAModel.Convert(sheet.At(x, y)); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("image pixel = %v, want white", got)
+	}
+}
+
+func TestCenterCrop(t *testing.T) {
+	tests := []struct {
+		w, h         int
+		aspect       float64
+		wantW, wantH int
+	}{
+		{w: 200, h: 100, aspect: 1, wantW: 100, wantH: 100},
+		{w: 100, h: 200, aspect: 1, wantW: 100, wantH: 100},
+		{w: 160, h: 90, aspect: 4.0 / 3, wantW: 120, wantH: 90},
+		{w: 90, h: 160, aspect: 16.0 / 9, wantW: 90, wantH: 51},
+		{w: 120, h: 90, aspect: 4.0 / 3, wantW: 120, wantH: 90},
+	}
+	for _, tt := range tests {
+		src := image.NewRGBA(image.Rect(0, 0, tt.w, tt.h))
+		got := centerCrop(src, tt.aspect).Bounds()
+		if got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+			t.Errorf("centerCrop(%dx%d, %.3f) = %dx%d, want %dx%d",
+				tt.w, tt.h, tt.aspect, got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+			continue
+		}
+		left, right := got.Min.X, tt.w-got.Max.X
+		top, bottom := got.Min.Y, tt.h-got.Max.Y
+		if d := left - right; d < -1 || d > 1 {
+			t.Errorf("centerCrop(%dx%d, %.3f) margins left %d right %d, want centered",
+				tt.w, tt.h, tt.aspect, left, right)
+		}
+		if d := top - bottom; d < -1 || d > 1 {
+			t.Errorf("centerCrop(%dx%d, %.3f) margins top %d bottom %d, want centered",
+				tt.w, tt.h, tt.aspect, top, bottom)
+		}
+	}
+}
+
+func TestCenterCropKeepsCenterPixels(t *testing.T) {
+	// A red square in the middle of a wide blue image should fill the crop.
+	src := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	for y := range 10 {
+		for x := range 30 {
+			c := color.RGBA{B: 255, A: 255}
+			if x >= 10 && x < 20 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	got := centerCrop(src, 1)
+	b := got.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if r, _, bl, _ := got.At(x, y).RGBA(); r>>8 != 255 || bl != 0 {
+				t.Fatalf("pixel (%d,%d) of the crop is not from the center", x, y)
+			}
+		}
+	}
+}
+
+func TestCenterCropWithoutSubImage(t *testing.T) {
+	src := plainImage{solidImage(40, 20, color.White)}
+	got := centerCrop(src, 1).Bounds()
+	if got.Dx() != 20 || got.Dy() != 20 {
+		t.Errorf("centerCrop of an image without SubImage = %dx%d, want 20x20", got.Dx(), got.Dy())
+	}
+}
+
+// plainImage hides the SubImage method of the image it wraps.
+type plainImage struct{ image.Image }
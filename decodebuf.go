@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"sync"
+)
+
+// decodeBuffer bounds the memory held by images between download and
+// their card reaching the feed: waiting to be decoded, being decoded and
+// resized, and decoded but not yet added to the window. Download workers
+// acquire an image's decoded size before handing it on and block while the
+// buffer is full; the size is released once the card has been added, so a
+// fast link cannot pile up more images than decoding and the UI keep up
+// with. A nil decodeBuffer imposes no bound.
+type decodeBuffer struct {
+	limit int64
+
+	mu   sync.Mutex
+	used int64
+	// freed is closed and replaced whenever bytes are released, waking
+	// the workers waiting for room.
+	freed chan struct{}
+}
+
+// newDecodeBuffer returns a buffer of limit bytes, or nil for no bound when
+// limit is zero or less.
+func newDecodeBuffer(limit int64) *decodeBuffer {
+	if limit <= 0 {
+		return nil
+	}
+	return &decodeBuffer{limit: limit, freed: make(chan struct{})}
+}
+
+// acquire blocks until n more bytes fit in the buffer, then takes them. An
+// image larger than the whole buffer is let in once the buffer is empty,
+// so it cannot wait forever. It fails only if ctx is done first.
+func (b *decodeBuffer) acquire(ctx context.Context, n int64) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		if b.used == 0 || b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		freed := b.freed
+		b.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release returns n bytes taken by acquire.
+func (b *decodeBuffer) release(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	close(b.freed)
+	b.freed = make(chan struct{})
+}
+
+// decodedSize estimates the memory data takes once decoded, at four bytes
+// per pixel. Data whose header cannot be read counts as its own length.
+func decodedSize(data []byte) int64 {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return int64(len(data))
+	}
+	return int64(cfg.Width) * int64(cfg.Height) * 4
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestDecodeBufferBlocksUntilDrained(t *testing.T) {
+	b := newDecodeBuffer(100)
+	ctx := context.Background()
+	if err := b.acquire(ctx, 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.acquire(ctx, 40); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := b.acquire(ctx, 50); err != nil {
+			t.Errorf("acquire: %v", err)
+		}
+	}()
+	if waitReturned(done, 50*time.Millisecond) {
+		t.Fatal("acquire did not block on a full buffer")
+	}
+	// Freeing 40 bytes still leaves no room for 50.
+	b.release(40)
+	if waitReturned(done, 50*time.Millisecond) {
+		t.Fatal("acquire went ahead without room")
+	}
+	b.release(60)
+	if !waitReturned(done, time.Second) {
+		t.Fatal("acquire still blocked after the buffer drained")
+	}
+	if b.used != 50 {
+		t.Errorf("buffer holds %d bytes, want 50", b.used)
+	}
+}
+
+func TestDecodeBufferLetsOversizedImageIntoEmptyBuffer(t *testing.T) {
+	b := newDecodeBuffer(100)
+	if err := b.acquire(context.Background(), 500); err != nil {
+		t.Fatalf("an image larger than the buffer was refused: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := b.acquire(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("acquire after cancel = %v, want context.Canceled", err)
+		}
+	}()
+	if waitReturned(done, 50*time.Millisecond) {
+		t.Fatal("acquire did not block behind the oversized image")
+	}
+	cancel()
+	if !waitReturned(done, time.Second) {
+		t.Fatal("acquire did not return on cancel")
+	}
+}
+
+func TestNilDecodeBufferNeverBlocks(t *testing.T) {
+	b := newDecodeBuffer(0)
+	if b != nil {
+		t.Fatal("newDecodeBuffer(0) is not nil")
+	}
+	for range 3 {
+		if err := b.acquire(context.Background(), 1<<40); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.release(1 << 40)
+}
+
+func TestDecodedSize(t *testing.T) {
+	if got := decodedSize(pngBytes(t, 30, 20, color.White)); got != 30*20*4 {
+		t.Errorf("decodedSize of a 30x20 PNG = %d, want %d", got, 30*20*4)
+	}
+	if got := decodedSize([]byte("not an image")); got != 12 {
+		t.Errorf("decodedSize of unreadable data = %d, want its length 12", got)
+	}
+}
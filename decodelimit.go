@@ -0,0 +1,40 @@
+package main
+
+import "runtime"
+
+// defaultMaxDecodes is the default of --max-decodes: two on machines with
+// four cores or fewer, where decoding several large images at once stalls
+// the UI, and one per core otherwise.
+func defaultMaxDecodes() int {
+	if n := runtime.NumCPU(); n > 4 {
+		return n
+	}
+	return 2
+}
+
+// decodeLimiter bounds the number of images decoded at once, independent of
+// how many decode workers there are; the workers also resize and save,
+// which need not wait. A nil decodeLimiter imposes no bound.
+type decodeLimiter struct {
+	slots chan struct{}
+}
+
+// newDecodeLimiter returns a limiter allowing n decodes at once, or nil for
+// no limit when n is zero or less.
+func newDecodeLimiter(n int) *decodeLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &decodeLimiter{slots: make(chan struct{}, n)}
+}
+
+// decode is decodeRawImage, waiting for a free slot first. It does not
+// watch for cancellation: a decode is short, and a body already downloaded
+// is still decoded after the run is cancelled.
+func (l *decodeLimiter) decode(raw *rawImage, maxPixels int64) (*downloadedImage, error) {
+	if l != nil {
+		l.slots <- struct{}{}
+		defer func() { <-l.slots }()
+	}
+	return decodeRawImage(raw, maxPixels)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDecodeLimiterBoundsConcurrentDecodes(t *testing.T) {
+	var data bytes.Buffer
+	if err := gif.Encode(&data, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.White}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	old := decodeAllGIF
+	t.Cleanup(func() { decodeAllGIF = old })
+	var current, peak atomic.Int32
+	decodeAllGIF = func(r io.Reader) (*gif.GIF, error) {
+		n := current.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		// Hold the decode long enough for the others to pile up.
+		time.Sleep(20 * time.Millisecond)
+		current.Add(-1)
+		return old(r)
+	}
+
+	for _, limit := range []int{1, 2, 3} {
+		peak.Store(0)
+		l := newDecodeLimiter(limit)
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := l.decode(&rawImage{Data: data.Bytes()}, 0); err != nil {
+					t.Errorf("decode: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if got := int(peak.Load()); got != limit {
+			t.Errorf("limit %d: %d decodes ran at once", limit, got)
+		}
+	}
+}
+
+func TestNilDecodeLimiter(t *testing.T) {
+	if newDecodeLimiter(0) != nil {
+		t.Fatal("newDecodeLimiter(0) is not nil")
+	}
+	var l *decodeLimiter
+	if _, err := l.decode(&rawImage{Data: pngBytes(t, 2, 2, color.White)}, 0); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// errDownloadBudget is returned once the --max-download-bytes budget has
+// been used up and no further images should be fetched.
+var errDownloadBudget = errors.New("download budget exhausted")
+
+// byteCounter tracks the bytes read across all image downloads and an
+// optional cap on that total. A limit of zero means unlimited.
+type byteCounter struct {
+	total atomic.Int64
+	limit int64
+}
+
+// Total returns the number of bytes read so far.
+func (c *byteCounter) Total() int64 {
+	return c.total.Load()
+}
+
+// Exhausted reports whether the byte budget has been reached.
+func (c *byteCounter) Exhausted() bool {
+	return c.limit > 0 && c.total.Load() >= c.limit
+}
+
+// countingReader adds every byte read from r to a byteCounter.
+type countingReader struct {
+	r       io.Reader
+	counter *byteCounter
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.counter.total.Add(int64(n))
+	return n, err
+}
+
+// retryBudget caps the number of download retries across a whole run, so
+// a few hosts that keep failing cannot multiply --retries into unbounded
+// time. A nil retryBudget allows every retry.
+type retryBudget struct {
+	remaining atomic.Int64
+}
+
+// newRetryBudget returns a budget of n retries, or nil for no cap when n is
+// zero or less.
+func newRetryBudget(n int) *retryBudget {
+	if n <= 0 {
+		return nil
+	}
+	b := &retryBudget{}
+	b.remaining.Store(int64(n))
+	return b
+}
+
+// take uses up one retry, reporting false if none are left.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	return b.remaining.Add(-1) >= 0
+}
+
+// downloader fetches images with a shared client and accounts for the bytes
+// it reads.
+type downloader struct {
+	client  *http.Client
+	bytes   *byteCounter
+	retries int
+	// allow restricts the hosts images are fetched from; nil allows all.
+	allow hostAllowlist
+	// hosts limits concurrent downloads per host; nil imposes no limit.
+	hosts *hostLimiter
+	// spacer spaces out the starts of downloads; nil does not.
+	spacer *requestSpacer
+	// retryBudget caps retries across all downloads; nil does not.
+	retryBudget *retryBudget
+	// headCheck sends a HEAD request before each download, to skip
+	// non-images and images over maxImageBytes without fetching them.
+	headCheck bool
+	// maxImageBytes rejects single images larger than this; zero allows
+	// any size.
+	maxImageBytes int64
+}
+
+// newDownloader returns a downloader fetching through client. maxBytes caps
+// the total bytes downloaded; zero means unlimited. retries is the number of
+// extra attempts made after a retryable failure.
+func newDownloader(client *http.Client, maxBytes int64, retries int) *downloader {
+	return &downloader{
+		client:  client,
+		bytes:   &byteCounter{limit: maxBytes},
+		retries: retries,
+	}
+}
+
+// urlExt returns the file extension of the URL's path, ignoring any query
+// string or fragment a redirect may have added.
+func urlExt(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return filepath.Ext(raw)
+	}
+	return path.Ext(u.Path)
+}
+
+// downloadedImage is a decoded image along with details of how it was
+// fetched.
+type downloadedImage struct {
+	Image image.Image
+	// Format is the name of the decoder that read the image, e.g. "jpeg".
+	Format string
+	// FinalURL is the URL the image was served from after any redirects.
+	FinalURL string
+	// Size is the number of bytes in the response body.
+	Size int64
+	// Raw is the response body the image was decoded from.
+	Raw []byte
+	// ICCProfile reports whether the file embeds an ICC colour profile,
+	// which decoding ignores.
+	ICCProfile bool
+	// Modified reports whether Image no longer matches Raw, e.g. after a
+	// filter, so saving it needs a re-encode.
+	Modified bool
+	// GIF holds every frame of an animated GIF source along with its
+	// delays and loop count; Image is its first frame. It is nil for other
+	// formats. Display and saving both work from this one decode: the card
+	// shows Image and encodeImage writes the frames back out.
+	GIF *gif.GIF
+}
+
+// isRetryableDownload reports whether a failed download is worth another
+// attempt: a truncated body, or a status in the --retry-status set.
+func isRetryableDownload(err error) bool {
+	return errors.Is(err, ErrTruncated) || isRetryableStatusError(err)
+}
+
+// rawImage is a downloaded, not yet decoded image body.
+type rawImage struct {
+	Data []byte
+	// FinalURL is the URL the body was served from after any redirects.
+	FinalURL string
+}
+
+// fetchImageData downloads the body of the image at url without decoding
+// it, retrying retryable failures up to d.retries times. The result records
+// the final URL after any redirects (e.g. imgur.com -> i.imgur.com), which
+// should be preferred over the post URL when naming or identifying the image.
+func (d *downloader) fetchImageData(ctx context.Context, url string) (*rawImage, error) {
+	for attempt := 0; ; attempt++ {
+		raw, err := d.fetchOnce(ctx, url)
+		if err == nil || !isRetryableDownload(err) || attempt >= d.retries || ctx.Err() != nil {
+			return raw, err
+		}
+		if !d.retryBudget.take() {
+			log.Printf("Retry budget used up; not retrying %s", url)
+			return raw, err
+		}
+		log.Printf("Retrying %s (attempt %d of %d): %v", url, attempt+1, d.retries, err)
+		if isRetryableStatusError(err) {
+			// Give an overloaded or rate limiting host a moment first.
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("failed to download image: %w", ctx.Err())
+			case <-time.After(time.Duration(attempt+1) * time.Second):
+			}
+		}
+	}
+}
+
+// fetchOnce makes a single attempt at downloading url. The whole body is
+// read up front so that a connection closed early is reported as
+// ErrTruncated rather than as a confusing decode error later.
+//
+// The byte budget is checked before each request, so the download that
+// crosses the cap still completes and the next one is refused.
+func (d *downloader) fetchOnce(ctx context.Context, url string) (*rawImage, error) {
+	if d.bytes.Exhausted() {
+		return nil, errDownloadBudget
+	}
+	if err := d.allow.check(url); err != nil {
+		return nil, err
+	}
+	if err := d.spacer.wait(ctx); err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	release, err := d.hosts.acquire(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer release()
+
+	if d.headCheck {
+		if err := d.checkHead(ctx, url); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Drain a little of the body so the connection can be reused.
+		io.CopyN(io.Discard, resp.Body, 64<<10)
+		return nil, fmt.Errorf("failed to download image: %w", statusError(resp.StatusCode, resp.Status))
+	}
+
+	finalURL := resp.Request.URL.String()
+	if finalURL != url {
+		log.Printf("Redirected: %s -> %s", url, finalURL)
+	}
+	if err := d.checkSize(resp.ContentLength); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = &countingReader{r: resp.Body, counter: d.bytes}
+	if d.maxImageBytes > 0 {
+		// A body sent without a Content-Length, e.g. chunked, passed
+		// checkSize; read one byte past the cap to tell it went over.
+		body = io.LimitReader(body, d.maxImageBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: read %d of %d bytes", ErrTruncated, len(data), resp.ContentLength)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if resp.ContentLength >= 0 && int64(len(data)) < resp.ContentLength {
+		return nil, fmt.Errorf("%w: read %d of %d bytes", ErrTruncated, len(data), resp.ContentLength)
+	}
+	if d.maxImageBytes > 0 && int64(len(data)) > d.maxImageBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, d.maxImageBytes)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w from %s", ErrEmptyResponse, finalURL)
+	}
+	return &rawImage{Data: data, FinalURL: finalURL}, nil
+}
+
+// checkHead asks for the headers of url with a HEAD request and fails if
+// they show it is not an image or is larger than d.maxImageBytes. Hosts
+// that do not support HEAD, or answer it with anything but 200, are left
+// for the GET to find out about.
+func (d *downloader) checkHead(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download image: %w", err)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download image: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "image/") {
+		return fmt.Errorf("%w: Content-Type %s", errNotImage, ct)
+	}
+	return d.checkSize(resp.ContentLength)
+}
+
+// checkSize fails with ErrTooLarge if contentLength, as announced by a
+// response, exceeds d.maxImageBytes. An unknown length (-1) passes, and
+// fetchOnce stops reading the body once it goes over instead.
+func (d *downloader) checkSize(contentLength int64) error {
+	if d.maxImageBytes > 0 && contentLength > d.maxImageBytes {
+		return fmt.Errorf("%w: %d bytes exceeds %d", ErrTooLarge, contentLength, d.maxImageBytes)
+	}
+	return nil
+}
+
+// decodeRawImage decodes a downloaded body, rejecting it with
+// ErrTooLarge first if its dimensions exceed maxPixels. A maxPixels
+// of zero or less means no limit.
+func decodeRawImage(raw *rawImage, maxPixels int64) (*downloadedImage, error) {
+	if err := checkImagePixels(raw.Data, maxPixels); err != nil {
+		return nil, err
+	}
+	result, err := decodeImage(raw.Data)
+	if err != nil {
+		return nil, err
+	}
+	result.FinalURL = raw.FinalURL
+	result.Size = int64(len(raw.Data))
+	result.Raw = raw.Data
+	result.ICCProfile = hasICCProfile(raw.Data)
+	return result, nil
+}
+
+// checkImagePixels reads just the header of data and fails if the image is
+// larger than maxPixels. Headers that cannot be read are left for the full
+// decode to report.
+func checkImagePixels(data []byte, maxPixels int64) error {
+	if maxPixels <= 0 {
+		return nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	if pixels := int64(cfg.Width) * int64(cfg.Height); pixels > maxPixels {
+		return fmt.Errorf("%w: %dx%d exceeds %d", ErrTooLarge, cfg.Width, cfg.Height, maxPixels)
+	}
+	return nil
+}
+
+// decodeAllGIF decodes every frame of a GIF. It is a variable so tests can
+// count and hold decodes.
+var decodeAllGIF = gif.DecodeAll
+
+// decodeImage decodes data, keeping all frames when it is a GIF. SVGs are
+// rasterized at the card width.
+func decodeImage(data []byte) (*downloadedImage, error) {
+	if isSVG(data) {
+		img, err := rasterizeSVG(data, cardWidth)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+		return &downloadedImage{Image: img, Format: "svg"}, nil
+	}
+	if bytes.HasPrefix(data, []byte("GIF8")) {
+		anim, err := decodeAllGIF(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+		return &downloadedImage{Image: anim.Image[0], Format: "gif", GIF: anim}, nil
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return &downloadedImage{Image: img, Format: format}, nil
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchImageDataRecordsFinalURL(t *testing.T) {
+	m := newMockReddit(t)
+	data := pngBytes(t, 2, 2, color.White)
+	final := m.addImage("cat.png", data)
+	moved := m.handle("/imgur/cat", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/img/cat.png", http.StatusFound)
+	})
+
+	dl := newDownloader(newImageClient(m.Client().Transport, true, nil), 0, 0)
+	raw, err := dl.fetchImageData(context.Background(), moved)
+	if err != nil {
+		t.Fatalf("fetchImageData: %v", err)
+	}
+	if raw.FinalURL != final {
+		t.Errorf("FinalURL = %s, want %s", raw.FinalURL, final)
+	}
+	if !bytes.Equal(raw.Data, data) {
+		t.Error("body is not that of the redirect target")
+	}
+
+	decoded, err := decodeRawImage(raw, 0)
+	if err != nil {
+		t.Fatalf("decodeRawImage: %v", err)
+	}
+	if decoded.FinalURL != final {
+		t.Errorf("decoded FinalURL = %s, want %s", decoded.FinalURL, final)
+	}
+}
+
+func TestFetchImageDataWithoutFollowingRedirects(t *testing.T) {
+	m := newMockReddit(t)
+	m.addImage("cat.png", pngBytes(t, 2, 2, color.White))
+	moved := m.handle("/imgur/cat", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/img/cat.png", http.StatusFound)
+	})
+
+	dl := newDownloader(newImageClient(m.Client().Transport, false, nil), 0, 0)
+	_, err := dl.fetchImageData(context.Background(), moved)
+	var se *httpStatusError
+	if !errors.As(err, &se) || se.Code != http.StatusFound {
+		t.Errorf("err = %v, want the 302 itself", err)
+	}
+}
+
+func TestDownloadBudgetStopsFurtherDownloads(t *testing.T) {
+	m := newMockReddit(t)
+	data := pngBytes(t, 8, 8, color.White)
+	first := m.addImage("a.png", data)
+	second := m.addImage("b.png", data)
+
+	// The download that crosses the budget completes; the next is refused.
+	dl := newDownloader(m.Client(), int64(len(data))/2, 0)
+	if _, err := dl.fetchImageData(context.Background(), first); err != nil {
+		t.Fatalf("first download: %v", err)
+	}
+	if got := dl.bytes.Total(); got != int64(len(data)) {
+		t.Errorf("Total = %d, want %d", got, len(data))
+	}
+	if _, err := dl.fetchImageData(context.Background(), second); !errors.Is(err, errDownloadBudget) {
+		t.Errorf("second download: err = %v, want errDownloadBudget", err)
+	}
+	if n := len(m.requestLog()); n != 1 {
+		t.Errorf("made %d requests, want 1", n)
+	}
+}
+
+func TestUnlimitedDownloadBudget(t *testing.T) {
+	c := &byteCounter{}
+	c.total.Add(1 << 40)
+	if c.Exhausted() {
+		t.Error("a zero limit is exhausted")
+	}
+}
+
+// truncatingHandler announces data in full but sends only its first half
+// before dropping the connection, the first times requests.
+func truncatingHandler(data []byte, times int) http.HandlerFunc {
+	var served atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if served.Add(1) > int32(times) {
+			w.Write(data)
+			return
+		}
+		w.Write(data[:len(data)/2])
+		// Returning short of Content-Length makes the server close the
+		// connection.
+	}
+}
+
+func TestFetchImageDataDetectsTruncation(t *testing.T) {
+	m := newMockReddit(t)
+	url := m.handle("/img/cut.png", truncatingHandler(pngBytes(t, 16, 16, color.White), 1))
+
+	dl := newDownloader(m.Client(), 0, 0)
+	_, err := dl.fetchImageData(context.Background(), url)
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("err = %v, want ErrTruncated", err)
+	}
+	if failureReason(err) != "truncated" {
+		t.Errorf("failureReason = %q, want truncated", failureReason(err))
+	}
+}
+
+func TestFetchImageDataRetriesTruncation(t *testing.T) {
+	m := newMockReddit(t)
+	data := pngBytes(t, 16, 16, color.White)
+	url := m.handle("/img/cut.png", truncatingHandler(data, 1))
+
+	dl := newDownloader(m.Client(), 0, 1)
+	raw, err := dl.fetchImageData(context.Background(), url)
+	if err != nil {
+		t.Fatalf("fetchImageData: %v", err)
+	}
+	if !bytes.Equal(raw.Data, data) {
+		t.Error("retried body differs from the image")
+	}
+}
+
+func TestGIFRoundTripKeepsFrames(t *testing.T) {
+	palette := color.Palette{color.Black, color.White, color.RGBA{0xff, 0, 0, 0xff}}
+	src := &gif.GIF{LoopCount: 3}
+	for i, delay := range []int{10, 20, 35} {
+		frame := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+		frame.SetColorIndex(i, i, uint8(i))
+		src.Image = append(src.Image, frame)
+		src.Delay = append(src.Delay, delay)
+	}
+	var in bytes.Buffer
+	if err := gif.EncodeAll(&in, src); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decodeImage(in.Bytes())
+	if err != nil {
+		t.Fatalf("decodeImage: %v", err)
+	}
+	if decoded.Format != "gif" || decoded.GIF == nil {
+		t.Fatalf("decoded as %q with GIF %v, want all frames of a gif", decoded.Format, decoded.GIF)
+	}
+	var out bytes.Buffer
+	if err := encodeImage(&out, decoded.Image, decoded.GIF, ".gif", nil); err != nil {
+		t.Fatalf("encodeImage: %v", err)
+	}
+
+	saved, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatalf("decoding saved GIF: %v", err)
+	}
+	if len(saved.Image) != 3 {
+		t.Errorf("saved %d frames, want 3", len(saved.Image))
+	}
+	if !slices.Equal(saved.Delay, src.Delay) {
+		t.Errorf("delays = %v, want %v", saved.Delay, src.Delay)
+	}
+	if saved.LoopCount != src.LoopCount {
+		t.Errorf("loop count = %d, want %d", saved.LoopCount, src.LoopCount)
+	}
+	if got := saved.Image[2].ColorIndexAt(2, 2); got != 2 {
+		t.Errorf("last frame pixel has colour index %d, want 2", got)
+	}
+}
+
+// pngHeader returns the start of a PNG claiming to be w by h pixels: a
+// valid signature and IHDR chunk, with no pixel data after it.
+func pngHeader(w, h uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), w)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, h)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestDecodeRejectsHugeDimensionsBeforeDecoding(t *testing.T) {
+	bomb := &rawImage{Data: pngHeader(50000, 50000), FinalURL: "https://i.redd.it/bomb.png"}
+	if _, err := decodeRawImage(bomb, 100_000_000); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("50000x50000 header: err = %v, want ErrTooLarge", err)
+	}
+	// Without a limit the header passes and the missing pixels fail the
+	// decode instead.
+	if _, err := decodeRawImage(bomb, 0); !errors.Is(err, ErrDecode) {
+		t.Errorf("without --max-pixels: err = %v, want ErrDecode", err)
+	}
+
+	small := &rawImage{Data: pngBytes(t, 100, 100, color.White)}
+	if _, err := decodeRawImage(small, 10_000); err != nil {
+		t.Errorf("image at the limit: %v", err)
+	}
+	if _, err := decodeRawImage(small, 9_999); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("image one pixel over the limit: err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestRetryBudgetIsSharedAcrossDownloads(t *testing.T) {
+	m := newMockReddit(t)
+	data := pngBytes(t, 16, 16, color.White)
+	var requests atomic.Int32
+	cut := truncatingHandler(data, 1000)
+	counting := func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		cut(w, r)
+	}
+	first := m.handle("/img/first.png", counting)
+	second := m.handle("/img/second.png", counting)
+
+	dl := newDownloader(m.Client(), 0, 5)
+	dl.retryBudget = newRetryBudget(3)
+	if _, err := dl.fetchImageData(context.Background(), first); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("first download: err = %v, want ErrTruncated", err)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("first download made %d requests, want 4 (one plus the budget of 3 retries)", got)
+	}
+	if _, err := dl.fetchImageData(context.Background(), second); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("second download: err = %v, want ErrTruncated", err)
+	}
+	if got := requests.Load(); got != 5 {
+		t.Errorf("after the budget was spent the second download made %d requests, want 1", got-4)
+	}
+}
+
+func TestNilRetryBudgetAllowsEveryRetry(t *testing.T) {
+	var b *retryBudget
+	for range 100 {
+		if !b.take() {
+			t.Fatal("nil budget refused a retry")
+		}
+	}
+	if newRetryBudget(0) != nil {
+		t.Error("newRetryBudget(0) is not nil")
+	}
+}
+
+func TestFetchImageDataReportsEmptyResponse(t *testing.T) {
+	m := newMockReddit(t)
+	withLength := m.handle("/img/empty.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "0")
+	})
+	chunked := m.handle("/img/chunked.png", func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+	})
+
+	dl := newDownloader(m.Client(), 0, 0)
+	for _, url := range []string{withLength, chunked} {
+		_, err := dl.fetchImageData(context.Background(), url)
+		if !errors.Is(err, ErrEmptyResponse) {
+			t.Errorf("%s: err = %v, want ErrEmptyResponse", url, err)
+		}
+		if got := failureReason(err); got != "empty" {
+			t.Errorf("%s: failureReason = %q, want empty", url, got)
+		}
+	}
+
+	s := newMockSession(m)
+	s.processPosts(context.Background(), []Post{{ID: "e", Title: "Empty", URL: withLength}}, 1, 1)
+	if got := s.stats.failures["empty"]; got != 1 {
+		t.Errorf("empty responses counted = %d, want 1", got)
+	}
+}
+
+func TestGIFDecodedOnceForDisplayAndSave(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	src := &gif.GIF{}
+	for range 3 {
+		src.Image = append(src.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), palette))
+		src.Delay = append(src.Delay, 10)
+	}
+	var data bytes.Buffer
+	if err := gif.EncodeAll(&data, src); err != nil {
+		t.Fatal(err)
+	}
+
+	old := decodeAllGIF
+	t.Cleanup(func() { decodeAllGIF = old })
+	var decodes atomic.Int32
+	decodeAllGIF = func(r io.Reader) (*gif.GIF, error) {
+		decodes.Add(1)
+		return old(r)
+	}
+
+	m := newMockReddit(t)
+	posts := []Post{{ID: "g", Title: "Loop", URL: m.addImage("loop.gif", data.Bytes())}}
+	saver := newRecordingSaver()
+	s := newMockWindowSession(t, m)
+	s.opts.download = true
+	s.opts.filenames, _ = parseFilenameTemplate(defaultFilenameTemplate)
+	// Filtering makes the save encode the frames rather than copy the body.
+	s.opts.filter, _ = newImageFilter(true, "")
+	s.saver = saver
+	results, _ := s.processPosts(context.Background(), posts, 1, 1)
+	if results[0] == nil || results[0].card == nil {
+		t.Fatal("the GIF was not shown")
+	}
+
+	if got := decodes.Load(); got != 1 {
+		t.Errorf("the GIF was decoded %d times, want 1", got)
+	}
+	saved, err := gif.DecodeAll(bytes.NewReader(saver.saved["Loop.gif"]))
+	if err != nil {
+		t.Fatalf("saved GIF: %v", err)
+	}
+	if len(saved.Image) != 3 {
+		t.Errorf("saved %d frames, want 3", len(saved.Image))
+	}
+}
+
+func TestHeadCheckSkipsNonImagesWithoutGET(t *testing.T) {
+	m := newMockReddit(t)
+	var mu sync.Mutex
+	var methods []string
+	record := func(r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+	}
+	page := m.handle("/img/page.png", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>an image host page</html>"))
+	})
+	data := pngBytes(t, 8, 8, color.White)
+	noHead := m.handle("/img/nohead.png", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write(data)
+	})
+	big := m.handle("/img/big.png", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", "1000000")
+		if r.Method == http.MethodGet {
+			w.Write(make([]byte, 1000000))
+		}
+	})
+
+	dl := newDownloader(m.Client(), 0, 0)
+	dl.headCheck = true
+	dl.maxImageBytes = 100_000
+	if _, err := dl.fetchImageData(context.Background(), page); !errors.Is(err, errNotImage) {
+		t.Errorf("text/html page: err = %v, want errNotImage", err)
+	}
+	if raw, err := dl.fetchImageData(context.Background(), noHead); err != nil || !bytes.Equal(raw.Data, data) {
+		t.Errorf("host answering HEAD with 405: err = %v, want the image from a GET", err)
+	}
+	if _, err := dl.fetchImageData(context.Background(), big); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized image: err = %v, want ErrTooLarge", err)
+	}
+
+	want := []string{"HEAD /img/page.png", "HEAD /img/nohead.png", "GET /img/nohead.png", "HEAD /img/big.png"}
+	if !slices.Equal(methods, want) {
+		t.Errorf("requests = %q, want %q", methods, want)
+	}
+}
+
+func TestMaxImageBytesCapsBodyWithoutLength(t *testing.T) {
+	m := newMockReddit(t)
+	url := m.handle("/img/endless.png", func(w http.ResponseWriter, r *http.Request) {
+		// Flushing first sends the body chunked, with no Content-Length.
+		w.(http.Flusher).Flush()
+		chunk := make([]byte, 4096)
+		for range 256 {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	})
+
+	dl := newDownloader(m.Client(), 0, 0)
+	dl.maxImageBytes = 10_000
+	_, err := dl.fetchImageData(context.Background(), url)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+	if got := dl.bytes.Total(); got > dl.maxImageBytes+4096 {
+		t.Errorf("read %d bytes of a body capped at %d", got, dl.maxImageBytes)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned, wrapped, from fetching listings and images, for callers
+// to tell failures apart with errors.Is.
+var (
+	// ErrNotFound is returned for a subreddit or image that does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited is returned when Reddit or an image host answers 429
+	// Too Many Requests.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrSubredditPrivate, ErrSubredditBanned and ErrSubredditQuarantined
+	// are returned when Reddit refuses to serve a subreddit listing.
+	ErrSubredditPrivate     = errors.New("subreddit is private")
+	ErrSubredditBanned      = errors.New("subreddit is banned")
+	ErrSubredditQuarantined = errors.New("subreddit is quarantined")
+	// ErrInterstitial is returned when Reddit answers a listing request
+	// with an HTML page, such as the age gate shown to anonymous clients
+	// for NSFW or quarantined subreddits, instead of JSON.
+	ErrInterstitial = errors.New("Reddit returned an interstitial page instead of a listing")
+	// ErrUnsupportedHost is returned for post URLs on a host whose media
+	// cannot be fetched, such as the defunct gfycat.
+	ErrUnsupportedHost = errors.New("unsupported host")
+	// ErrHostNotAllowed is returned for image URLs whose host is not on
+	// the allowlist.
+	ErrHostNotAllowed = errors.New("host not in image allowlist")
+
+	// ErrTruncated is returned when an image response ends before the
+	// length announced in its Content-Length header. It is retryable,
+	// unlike a decode failure of a complete body.
+	ErrTruncated = errors.New("image download truncated")
+	// ErrEmptyResponse is returned when an image host answers 200 OK with
+	// an empty body, which would otherwise surface as an unclear EOF from
+	// the decoder.
+	ErrEmptyResponse = errors.New("empty image response")
+	// ErrDecode is returned when a fully downloaded body cannot be decoded
+	// as an image.
+	ErrDecode = errors.New("failed to decode image")
+	// ErrTooLarge is returned when an image header announces more pixels
+	// than --max-pixels allows, or a response more bytes than
+	// --max-image-bytes. The checks run before the image is decoded, so a
+	// small file that would expand to a huge bitmap is rejected cheaply.
+	ErrTooLarge = errors.New("image too large")
+)
+
+// httpStatusError is an unexpected HTTP status, kept so callers can get at
+// the code with errors.As, e.g. to decide whether to retry.
+type httpStatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *httpStatusError) Error() string {
+	return "unexpected status " + e.Status
+}
+
+// statusError returns the error for an unexpected HTTP status, wrapping
+// ErrNotFound or ErrRateLimited when the status is one of those. status is
+// the text of the status line, e.g. "404 Not Found".
+func statusError(code int, status string) error {
+	se := &httpStatusError{Code: code, Status: status}
+	switch code {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %w", ErrNotFound, se)
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %w", ErrRateLimited, se)
+	}
+	return se
+}
+
+// redditErrorBody is the JSON Reddit returns instead of a listing when a
+// subreddit cannot be viewed, e.g. {"reason": "private", "message":
+// "Forbidden", "error": 403}.
+type redditErrorBody struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Code    int    `json:"error"`
+}
+
+// redditStatusError turns a non-200 listing response into an error, using
+// the reason in the body when Reddit supplies one.
+func redditStatusError(subreddit string, status int, body []byte) error {
+	var e redditErrorBody
+	if json.Unmarshal(body, &e) == nil {
+		switch e.Reason {
+		case "private":
+			return fmt.Errorf("r/%s: %w", subreddit, ErrSubredditPrivate)
+		case "banned":
+			return fmt.Errorf("r/%s: %w", subreddit, ErrSubredditBanned)
+		case "quarantined":
+			return fmt.Errorf("r/%s: %w (viewing it requires a logged-in, opted-in account)", subreddit, ErrSubredditQuarantined)
+		}
+		if e.Message != "" {
+			return fmt.Errorf("r/%s: %w: %s", subreddit, statusError(status, fmt.Sprint(status)), e.Message)
+		}
+	}
+	return fmt.Errorf("r/%s: %w", subreddit, statusError(status, fmt.Sprint(status)))
+}
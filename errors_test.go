@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedditStatusError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"private", http.StatusForbidden, `{"reason": "private", "message": "Forbidden", "error": 403}`, ErrSubredditPrivate},
+		{"banned", http.StatusNotFound, `{"reason": "banned", "message": "Not Found", "error": 404}`, ErrSubredditBanned},
+		{"quarantined", http.StatusForbidden, `{"reason": "quarantined", "quarantine_message": "This community is quarantined.", "message": "Forbidden", "error": 403}`, ErrSubredditQuarantined},
+		{"missing", http.StatusNotFound, `{"message": "Not Found", "error": 404}`, ErrNotFound},
+		{"not JSON", http.StatusTooManyRequests, `<html>slow down</html>`, ErrRateLimited},
+	}
+	for _, tt := range tests {
+		err := redditStatusError("x", tt.status, []byte(tt.body))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+		if !strings.HasPrefix(err.Error(), "r/x: ") {
+			t.Errorf("%s: %q does not name the subreddit", tt.name, err)
+		}
+	}
+
+	err := redditStatusError("x", http.StatusForbidden, []byte(`{"reason": "gold_only", "message": "Forbidden", "error": 403}`))
+	var se *httpStatusError
+	if !errors.As(err, &se) || se.Code != http.StatusForbidden {
+		t.Errorf("unknown reason: err = %v, want status 403", err)
+	}
+	if !strings.Contains(err.Error(), "Forbidden") {
+		t.Errorf("unknown reason: %q lacks Reddit's message", err)
+	}
+}
+
+func TestErrorsIsForEachScenario(t *testing.T) {
+	old := listingRetries
+	listingRetries = 0
+	t.Cleanup(func() { listingRetries = old })
+
+	m := newMockReddit(t, "r_private.403.json")
+	image := pngBytes(t, 16, 16, color.White)
+	m.addImage("big.png", pngBytes(t, 64, 64, color.White))
+	m.addImage("garbage.png", []byte("\x89PNG\r\n\x1a\n this is not a PNG"))
+	m.handle("/img/cut.png", truncatingHandler(image, 1))
+	slowDown := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	m.handle("/img/limited.png", slowDown)
+	m.handle("/r/limited/.json", slowDown)
+
+	dl := newDownloader(m.Client(), 0, 0)
+	dl.maxImageBytes = int64(len(image))
+	listing := func(sub string) func() error {
+		return func() error {
+			_, err := fetchRedditData(context.Background(), m.Client(), sub, 10, nil)
+			return err
+		}
+	}
+	download := func(name string) func() error {
+		return func() error {
+			raw, err := dl.fetchImageData(context.Background(), m.URL+"/img/"+name)
+			if err != nil {
+				return err
+			}
+			_, err = decodeRawImage(raw, 0)
+			return err
+		}
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"missing subreddit", listing("nonexistent"), ErrNotFound},
+		{"private subreddit", listing("private"), ErrSubredditPrivate},
+		{"rate-limited listing", listing("limited"), ErrRateLimited},
+		{"missing image", download("gone.png"), ErrNotFound},
+		{"rate-limited image", download("limited.png"), ErrRateLimited},
+		{"truncated image", download("cut.png"), ErrTruncated},
+		{"oversized image", download("big.png"), ErrTooLarge},
+		{"corrupt image", download("garbage.png"), ErrDecode},
+	}
+	for _, tt := range tests {
+		err := tt.run()
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want errors.Is %v", tt.name, err, tt.want)
+		}
+		for _, other := range []error{ErrNotFound, ErrRateLimited, ErrSubredditPrivate, ErrDecode, ErrTruncated, ErrTooLarge} {
+			if other != tt.want && errors.Is(err, other) {
+				t.Errorf("%s: err = %v also matches %v", tt.name, err, other)
+			}
+		}
+	}
+}
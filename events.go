@@ -0,0 +1,48 @@
+package main
+
+// EventKind identifies what an Event reports.
+type EventKind int
+
+const (
+	// PostFetched is sent when a post from the listing enters the pipeline.
+	PostFetched EventKind = iota
+	// ImageDownloaded is sent once a post's image has been downloaded,
+	// decoded and, when saving, saved.
+	ImageDownloaded
+	// ImageFailed is sent when a post is skipped or its image could not be
+	// downloaded or decoded; Event.Err says why.
+	ImageFailed
+	// Done is sent when a processPosts call or a --watch poll has
+	// finished with every post.
+	Done
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case PostFetched:
+		return "PostFetched"
+	case ImageDownloaded:
+		return "ImageDownloaded"
+	case ImageFailed:
+		return "ImageFailed"
+	case Done:
+		return "Done"
+	}
+	return "unknown"
+}
+
+// Event is a progress report from processPosts or runner.watch, for
+// callers that drive their own UI or logging. Post is unset for Done.
+type Event struct {
+	Kind EventKind
+	Post Post
+	Err  error
+}
+
+// emit sends e on the session's events channel, if it has one. Sends block,
+// so whoever sets the channel must keep draining it until Done.
+func (s *session) emit(e Event) {
+	if s.events != nil {
+		s.events <- e
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// defaultFilenameTemplate reproduces the original naming scheme: the title
+// with spaces replaced by underscores, plus the image extension.
+const defaultFilenameTemplate = "{title}{ext}"
+
+// filenameSources lists the --filename-source presets in the order they
+// are documented, each with the template it stands for.
+var filenameSources = []struct{ name, template string }{
+	{"title", defaultFilenameTemplate},
+	{"id", "{id}{ext}"},
+	{"title-id", "{title}_{id}{ext}"},
+}
+
+// filenameSourceTemplate returns the filename template of the
+// --filename-source preset name. Post ids are unique, so the id presets
+// never collide the way titles do.
+func filenameSourceTemplate(name string) (string, error) {
+	names := make([]string, len(filenameSources))
+	for i, source := range filenameSources {
+		if source.name == name {
+			return source.template, nil
+		}
+		names[i] = source.name
+	}
+	return "", fmt.Errorf("unknown source %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// maxFilenameLength caps the length in bytes of the name before the
+// extension; most filesystems allow 255 bytes in total.
+const maxFilenameLength = 200
+
+// filenameFields lists the placeholders a filename template may use.
+var filenameFields = []string{"id", "title", "subreddit", "author", "score", "index", "ext"}
+
+// filenameTemplate is a parsed --save-filename-template such as
+// "{score}_{id}_{title}{ext}".
+type filenameTemplate struct {
+	// parts alternates literal text and field names; fields are stored
+	// with their braces so they can be told apart.
+	parts []string
+	// hasIndex records whether {index} appears. Without it the images of
+	// an expanded gallery would all get the same name, so expand appends
+	// the index itself.
+	hasIndex bool
+}
+
+// parseFilenameTemplate parses and validates a template. Every placeholder
+// must be one of filenameFields, braces must balance, and {ext} must appear
+// so the saved file gets the right encoder.
+func parseFilenameTemplate(s string) (*filenameTemplate, error) {
+	t := &filenameTemplate{}
+	hasExt := false
+	for rest := s; rest != ""; {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.parts = append(t.parts, rest)
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("invalid filename template %q: unmatched '}'", s)
+		}
+		if open > 0 {
+			t.parts = append(t.parts, rest[:open])
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid filename template %q: unclosed '{'", s)
+		}
+		field := rest[open+1 : open+end]
+		if !isFilenameField(field) {
+			return nil, fmt.Errorf("invalid filename template %q: unknown field {%s} (want one of %s)", s, field, strings.Join(filenameFields, ", "))
+		}
+		switch field {
+		case "ext":
+			hasExt = true
+		case "index":
+			t.hasIndex = true
+		}
+		t.parts = append(t.parts, "{"+field+"}")
+		rest = rest[open+end+1:]
+	}
+	if !hasExt {
+		return nil, fmt.Errorf("invalid filename template %q: must contain {ext}", s)
+	}
+	return t, nil
+}
+
+func isFilenameField(name string) bool {
+	for _, f := range filenameFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// postFilenameFields returns the template values for a post. title is the
+// title as displayed, ext the extension including its dot.
+func postFilenameFields(post Post, title, ext string) map[string]string {
+	fields := map[string]string{
+		"id":        post.ID,
+		"title":     title,
+		"subreddit": post.Subreddit,
+		"author":    post.Author,
+		"score":     strconv.Itoa(post.Score),
+		"ext":       ext,
+	}
+	if post.GalleryIndex > 0 {
+		fields["index"] = strconv.Itoa(post.GalleryIndex)
+	}
+	return fields
+}
+
+// expand fills in the template and sanitizes the result. Fields with no
+// value expand to nothing, and the separators that leaves behind are
+// tidied up. A gallery image named by a template without {index} gets
+// "_<index>" before its extension, e.g. My_gallery_2.jpg.
+func (t *filenameTemplate) expand(fields map[string]string) string {
+	var name strings.Builder
+	for _, part := range t.parts {
+		switch {
+		case part == "{ext}":
+			// Appended after sanitizing so it is never truncated.
+		case strings.HasPrefix(part, "{"):
+			name.WriteString(fields[strings.Trim(part, "{}")])
+		default:
+			name.WriteString(part)
+		}
+	}
+	suffix := fields["ext"]
+	if index := fields["index"]; index != "" && !t.hasIndex {
+		// Part of the suffix so truncating a long title cannot cut it.
+		suffix = "_" + index + suffix
+	}
+	return sanitizeFilename(name.String(), suffix)
+}
+
+// sanitizeFilename makes base safe to use as a file name on common
+// filesystems and appends ext. Spaces become underscores.
+func sanitizeFilename(base, ext string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r == ' ':
+			return '_'
+		case strings.ContainsRune(`/\:*?"<>|`, r), unicode.IsControl(r):
+			return '_'
+		}
+		return r
+	}, base)
+
+	for strings.Contains(cleaned, "__") {
+		cleaned = strings.ReplaceAll(cleaned, "__", "_")
+	}
+	cleaned = strings.Trim(cleaned, "_-. ")
+
+	if len(cleaned) > maxFilenameLength {
+		cleaned = strings.ToValidUTF8(cleaned[:maxFilenameLength], "")
+	}
+	if cleaned == "" {
+		cleaned = "image"
+	}
+	return cleaned + ext
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilenameTemplateExpand(t *testing.T) {
+	post := Post{ID: "abc12", Subreddit: "pics", Author: "alice", Score: 42}
+	tests := []struct {
+		template string
+		post     Post
+		title    string
+		want     string
+	}{
+		{defaultFilenameTemplate, post, "Sunset over the lake", "Sunset_over_the_lake.jpg"},
+		{"{score}_{id}_{title}{ext}", post, "Sunset", "42_abc12_Sunset.jpg"},
+		{"{subreddit}/{author}-{id}{ext}", post, "", "pics_alice-abc12.jpg"},
+		// Missing fields expand to nothing and their separators are tidied.
+		{"{author}_{title}_{id}{ext}", Post{ID: "abc12"}, "", "abc12.jpg"},
+		{"{title}{ext}", Post{}, "", "image.jpg"},
+		{"{title}{ext}", post, `a/b\c: "d"?`, "a_b_c_d.jpg"},
+		// Gallery images are told apart by index, added if left out.
+		{"{id}_{index}{ext}", Post{ID: "g1", GalleryIndex: 2}, "", "g1_2.jpg"},
+		{"{title}{ext}", Post{ID: "g1", GalleryIndex: 2}, "My gallery", "My_gallery_2.jpg"},
+		{"{id}_{index}{ext}", Post{ID: "p1"}, "", "p1.jpg"},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseFilenameTemplate(tt.template)
+		if err != nil {
+			t.Errorf("parseFilenameTemplate(%q): %v", tt.template, err)
+			continue
+		}
+		if got := tmpl.expand(postFilenameFields(tt.post, tt.title, ".jpg")); got != tt.want {
+			t.Errorf("%q with %q: got %s, want %s", tt.template, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestFilenameTemplateKeepsExtensionWhenTruncating(t *testing.T) {
+	tmpl, err := parseFilenameTemplate(defaultFilenameTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tmpl.expand(postFilenameFields(Post{GalleryIndex: 3}, strings.Repeat("é", 300), ".png"))
+	if !strings.HasSuffix(got, "_3.png") {
+		t.Errorf("truncated name %q lost its index or extension", got)
+	}
+	if base := strings.TrimSuffix(got, "_3.png"); len(base) > maxFilenameLength || !strings.HasPrefix(base, "é") || strings.ContainsRune(base, '�') {
+		t.Errorf("truncated name %q is too long or cut mid-character", got)
+	}
+}
+
+func TestParseFilenameTemplateErrors(t *testing.T) {
+	tests := map[string]string{
+		"{title}":           "must contain {ext}",
+		"{titel}{ext}":      "unknown field {titel}",
+		"{title{ext}":       "unknown field",
+		"{title}}{ext}":     "unmatched '}'",
+		"{title}{ext":       "unclosed '{'",
+		"":                  "must contain {ext}",
+		"{score}_{upvotes}": "unknown field {upvotes}",
+	}
+	for template, want := range tests {
+		_, err := parseFilenameTemplate(template)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseFilenameTemplate(%q) = %v, want an error containing %q", template, err, want)
+		}
+	}
+}
+
+func TestFilenameSourceTemplate(t *testing.T) {
+	for name, want := range map[string]string{"title": "{title}{ext}", "id": "{id}{ext}", "title-id": "{title}_{id}{ext}"} {
+		if got, err := filenameSourceTemplate(name); err != nil || got != want {
+			t.Errorf("filenameSourceTemplate(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := filenameSourceTemplate("score"); err == nil {
+		t.Error("filenameSourceTemplate accepted an unknown source")
+	}
+}
+
+func TestListNeedsNoFilenameTemplate(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json")
+	r := newMockRunner(t, m, "list", "--subreddit=pics")
+	defer r.close()
+	if r.filenames == nil {
+		t.Error("list runner has no filename template")
+	}
+}
+
+func TestFilenameSourcePresetNames(t *testing.T) {
+	post := Post{ID: "abc12", Title: "Sunset over the lake"}
+	for source, want := range map[string]string{
+		"title":    "Sunset_over_the_lake.jpg",
+		"id":       "abc12.jpg",
+		"title-id": "Sunset_over_the_lake_abc12.jpg",
+	} {
+		m := newMockReddit(t)
+		r := newMockRunner(t, m, "show", "--subreddit=pics", "--filename-source="+source)
+		if got := r.filenames.expand(postFilenameFields(post, post.Title, ".jpg")); got != want {
+			t.Errorf("--filename-source=%s: got %s, want %s", source, got, want)
+		}
+		r.close()
+	}
+
+	_, err := parseCommand([]string{"download", "--subreddit=pics", "--filename-source=id", "--save-filename-template={score}{ext}"})
+	if err == nil {
+		t.Error("--filename-source accepted with --save-filename-template")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+)
+
+// imageFilter is the post-processing applied to every image before it is
+// shown or saved, set with --grayscale and --palette.
+type imageFilter struct {
+	grayscale bool
+	// palette, if set, is the palette images are quantized to.
+	palette color.Palette
+}
+
+// newImageFilter returns the filter for the --grayscale and --palette flag
+// values.
+func newImageFilter(grayscale bool, paletteName string) (imageFilter, error) {
+	f := imageFilter{grayscale: grayscale}
+	if paletteName != "" {
+		p, err := paletteForName(paletteName)
+		if err != nil {
+			return imageFilter{}, err
+		}
+		f.palette = p
+	}
+	return f, nil
+}
+
+// paletteForName returns one of the palettes --palette accepts.
+func paletteForName(name string) (color.Palette, error) {
+	switch name {
+	case "bw":
+		return color.Palette{color.Black, color.White}, nil
+	case "gray16":
+		p := make(color.Palette, 16)
+		for i := range p {
+			p[i] = color.Gray{Y: uint8(i * 17)}
+		}
+		return p, nil
+	case "websafe":
+		return palette.WebSafe, nil
+	case "plan9":
+		return palette.Plan9, nil
+	}
+	return nil, fmt.Errorf("unknown palette %q (want bw, gray16, websafe or plan9)", name)
+}
+
+// active reports whether the filter changes images at all.
+func (f imageFilter) active() bool {
+	return f.grayscale || f.palette != nil
+}
+
+// apply returns img with the filter applied.
+func (f imageFilter) apply(img image.Image) image.Image {
+	if f.grayscale {
+		img = toGrayscale(img)
+	}
+	if f.palette != nil {
+		img = quantize(img, f.palette)
+	}
+	return img
+}
+
+// applyGIF applies the filter to every frame of an animation in place. The
+// frames are already paletted, so only their palettes are remapped, which
+// keeps transparent entries transparent. Frames decoded without a local
+// colour table share the global one, so each distinct palette is copied
+// and remapped once, and the global table is swapped for its remapped copy.
+func (f imageFilter) applyGIF(g *gif.GIF) {
+	remapped := make(map[*color.Color]color.Palette)
+	remap := func(p color.Palette) color.Palette {
+		if len(p) == 0 {
+			return p
+		}
+		if out, ok := remapped[&p[0]]; ok {
+			return out
+		}
+		out := make(color.Palette, len(p))
+		for i, c := range p {
+			out[i] = f.convert(c)
+		}
+		remapped[&p[0]] = out
+		return out
+	}
+	for _, frame := range g.Image {
+		frame.Palette = remap(frame.Palette)
+	}
+	if global, ok := g.Config.ColorModel.(color.Palette); ok {
+		g.Config.ColorModel = remap(global)
+	}
+}
+
+// convert returns the filtered colour of one palette entry. Transparent
+// entries are left as they are.
+func (f imageFilter) convert(c color.Color) color.Color {
+	if _, _, _, a := c.RGBA(); a == 0 {
+		return c
+	}
+	if f.grayscale {
+		c = color.GrayModel.Convert(c)
+	}
+	if f.palette != nil {
+		c = f.palette.Convert(c)
+	}
+	return c
+}
+
+// toGrayscale converts img to grayscale using the ITU-R BT.601 luminance
+// weights of color.GrayModel.
+func toGrayscale(img image.Image) image.Image {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+	return gray
+}
+
+// quantize reduces img to the colours of p with Floyd-Steinberg dithering.
+func quantize(img image.Image, p color.Palette) image.Image {
+	b := img.Bounds()
+	dst := image.NewPaletted(b, p)
+	draw.FloydSteinberg.Draw(dst, b, img, b.Min)
+	return dst
+}
+
+// flattenAlpha composites img over bg, for encoders such as JPEG that
+// cannot store transparency and would otherwise turn it black. Opaque
+// images are returned unchanged.
+func flattenAlpha(img image.Image, bg color.Color) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
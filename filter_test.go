@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+)
+
+func TestToGrayscaleLuminance(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 20, 15, 21))
+	colors := []color.RGBA{
+		{0xff, 0x00, 0x00, 0xff},
+		{0x00, 0xff, 0x00, 0xff},
+		{0x00, 0x00, 0xff, 0xff},
+		{0xff, 0xff, 0xff, 0xff},
+		{0x80, 0x80, 0x80, 0xff},
+	}
+	// BT.601: Y = 0.299 R + 0.587 G + 0.114 B.
+	want := []uint8{76, 150, 29, 255, 128}
+	for i, c := range colors {
+		img.SetRGBA(10+i, 20, c)
+	}
+
+	gray, ok := toGrayscale(img).(*image.Gray)
+	if !ok {
+		t.Fatalf("toGrayscale returned %T, want *image.Gray", gray)
+	}
+	if gray.Bounds() != img.Bounds() {
+		t.Errorf("bounds = %v, want %v", gray.Bounds(), img.Bounds())
+	}
+	for i, w := range want {
+		if got := gray.GrayAt(10+i, 20).Y; got != w {
+			t.Errorf("luminance of %v = %d, want %d", colors[i], got, w)
+		}
+	}
+}
+
+func TestImageFilterPalette(t *testing.T) {
+	f, err := newImageFilter(false, "bw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := f.apply(solidImage(4, 4, color.RGBA{0xf0, 0xf0, 0xf0, 0xff}))
+	p, ok := out.(*image.Paletted)
+	if !ok || len(p.Palette) != 2 {
+		t.Fatalf("apply returned %T, want a two-colour paletted image", out)
+	}
+	if got := color.GrayModel.Convert(p.At(0, 0)); got != (color.Gray{0xff}) {
+		t.Errorf("near-white pixel quantized to %v, want white", got)
+	}
+	if _, err := newImageFilter(false, "sepia"); err == nil {
+		t.Error("newImageFilter accepted an unknown palette")
+	}
+}
+
+func TestImageFilterGIFKeepsTransparency(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 2, 1), color.Palette{color.Transparent, color.RGBA{0xff, 0, 0, 0xff}})
+	g := &gif.GIF{Image: []*image.Paletted{frame}, Delay: []int{10}}
+	imageFilter{grayscale: true}.applyGIF(g)
+
+	if frame.Palette[0] != color.Transparent {
+		t.Errorf("transparent entry became %v", frame.Palette[0])
+	}
+	if got := frame.Palette[1]; got != (color.Gray{76}) {
+		t.Errorf("red entry became %v, want Gray{76}", got)
+	}
+}
+
+func TestFlattenAlphaBeforeJPEG(t *testing.T) {
+	// Transparent on the left half, opaque red on the right.
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := 8; x < 16; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{0xff, 0, 0, 0xff})
+		}
+	}
+	jpegPixel := func(flatten color.Color, x, y int) color.RGBA {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := encodeImage(&buf, src, nil, ".jpg", flatten); err != nil {
+			t.Fatalf("encodeImage: %v", err)
+		}
+		img, err := jpeg.Decode(&buf)
+		if err != nil {
+			t.Fatalf("decoding the JPEG: %v", err)
+		}
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+	near := func(a, b color.RGBA) bool {
+		d := func(x, y uint8) int { return max(int(x), int(y)) - min(int(x), int(y)) }
+		return d(a.R, b.R) < 16 && d(a.G, b.G) < 16 && d(a.B, b.B) < 16
+	}
+
+	bg := color.RGBA{0x20, 0x80, 0xe0, 0xff}
+	if got := jpegPixel(bg, 2, 8); !near(got, bg) {
+		t.Errorf("transparent area = %v, want the background %v", got, bg)
+	}
+	if got := jpegPixel(bg, 13, 8); !near(got, color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("opaque area = %v, want red", got)
+	}
+	if got := jpegPixel(nil, 2, 8); !near(got, color.RGBA{0, 0, 0, 0xff}) {
+		t.Errorf("without flattening the transparent area = %v, want black", got)
+	}
+
+	opaque := solidImage(4, 4, color.White)
+	if flattenAlpha(opaque, bg) != opaque {
+		t.Error("an opaque image was copied")
+	}
+}
+
+func TestImageFilterGIFRemapsSharedPaletteOnce(t *testing.T) {
+	// Encoded without local colour tables or transparency, the decoded
+	// frames share the global palette.
+	src := color.Palette{color.RGBA{0xc0, 0x40, 0x20, 0xff}, color.RGBA{0x20, 0x80, 0xe0, 0xff}, color.RGBA{0x90, 0x90, 0x30, 0xff}}
+	var in gif.GIF
+	for range 4 {
+		frame := image.NewPaletted(image.Rect(0, 0, 3, 1), src)
+		frame.Pix = []uint8{0, 1, 2}
+		in.Image = append(in.Image, frame)
+		in.Delay = append(in.Delay, 10)
+	}
+	in.Config = image.Config{ColorModel: src, Width: 3, Height: 1}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := g.Image[0].Palette
+	before := append(color.Palette(nil), shared...)
+
+	f, err := newImageFilter(true, "plan9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.applyGIF(g)
+
+	for i, c := range shared {
+		if c != before[i] {
+			t.Fatalf("decoded palette entry %d rewritten in place to %v", i, c)
+		}
+	}
+	remapped := g.Image[0].Palette
+	for i, c := range before {
+		if want := f.convert(c); remapped[i] != want {
+			t.Errorf("entry %d = %v, want %v", i, remapped[i], want)
+		}
+	}
+	for n, frame := range g.Image {
+		if &frame.Palette[0] != &remapped[0] {
+			t.Errorf("frame %d has its own copy of the shared palette", n)
+		}
+	}
+	if global := g.Config.ColorModel.(color.Palette); &global[0] != &remapped[0] {
+		t.Error("global palette is not the remapped one")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// fixtureHost is the host of image URLs served from --fixtures-dir, e.g.
+// https://fixtures.invalid/cat.jpg. The .invalid top-level domain never
+// resolves, so such URLs cannot reach the network by accident.
+const fixtureHost = "fixtures.invalid"
+
+// fixtureListing returns a listingFetcher that reads the listing of each
+// subreddit from r_<subreddit>.json in dir, a saved response of the JSON
+// API, instead of fetching it. The file is parsed like a fetched page, but
+// is not paginated.
+func fixtureListing(dir string) listingFetcher {
+	return func(ctx context.Context, client *http.Client, subreddit string, limit int, skip func(Post) bool) ([]Post, error) {
+		path := filepath.Join(dir, "r_"+subreddit+".json")
+		log.Println("Reading fixture:", path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("r/%s: error reading fixture: %w", subreddit, err)
+		}
+		var page RedditResponse
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("r/%s: error parsing fixture %s: %w", subreddit, path, err)
+		}
+		posts := pagePosts(&page, skip)
+		if limit > 0 && len(posts) > limit {
+			posts = posts[:limit]
+		}
+		return posts, nil
+	}
+}
+
+// fixtureTransport serves requests for fixtureHost from the files in a
+// fixtures directory and passes everything else on to base.
+type fixtureTransport struct {
+	base  http.RoundTripper
+	files http.RoundTripper
+}
+
+func newFixtureTransport(base http.RoundTripper, dir string) *fixtureTransport {
+	return &fixtureTransport{base: base, files: http.NewFileTransport(http.Dir(dir))}
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == fixtureHost {
+		return t.files.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
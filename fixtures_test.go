@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"image/color"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// demoListing is a saved listing whose images are served from the
+// fixtures directory.
+const demoListing = `{"kind": "Listing", "data": {"after": "t3_d2", "children": [
+	{"kind": "t3", "data": {"id": "d1", "title": "Cat", "url": "https://fixtures.invalid/cat.png", "subreddit": "demo"}},
+	{"kind": "t3", "data": {"id": "d2", "title": "Dog", "url": "https://fixtures.invalid/dog.png", "subreddit": "demo"}}
+]}}`
+
+func writeDemoFixtures(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"r_demo.json": []byte(demoListing),
+		"cat.png":     pngBytes(t, 8, 8, color.White),
+		"dog.png":     pngBytes(t, 8, 8, color.Black),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFixturesDirServesListingAndImages(t *testing.T) {
+	m := newMockReddit(t)
+	dir := writeDemoFixtures(t)
+	r := newMockRunner(t, m, "show", "--subreddit=demo", "--fixtures-dir="+dir)
+	defer r.close()
+
+	posts, err := r.fetchInitial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := postIDs(posts); len(got) != 2 || got[0] != "d1" || got[1] != "d2" {
+		t.Fatalf("posts = %v, want [d1 d2] from the fixture", got)
+	}
+	sess := r.newSession(nil)
+	r.process(sess, posts)
+	if want := map[string]int{"png": 2}; !maps.Equal(sess.stats.formats, want) {
+		t.Errorf("decoded %v, want both fixture images", sess.stats.formats)
+	}
+	// The fixture's after cursor is not followed, and nothing is fetched.
+	if got := m.requestLog(); len(got) != 0 {
+		t.Errorf("requests made with --fixtures-dir: %q", got)
+	}
+}
+
+func TestFixtureListing(t *testing.T) {
+	fetch := fixtureListing(writeDemoFixtures(t))
+	posts, err := fetch(context.Background(), nil, "demo", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].ID != "d1" {
+		t.Errorf("limit 1 read %v, want [d1]", postIDs(posts))
+	}
+	if _, err := fetch(context.Background(), nil, "missing", 0, nil); err == nil || !strings.Contains(err.Error(), "r/missing") {
+		t.Errorf("missing fixture: err = %v, want it to name the subreddit", err)
+	}
+}
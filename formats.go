@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// decoderFormats are the image formats that can be decoded, by decoder
+// name. The standard library registers its decoders when imported, with no
+// way to list them, so the app keeps its own record: files adding optional
+// decoders (webp, bmp, ...) call registerDecoderFormat from init.
+var decoderFormats = []string{"gif", "jpeg", "png"}
+
+// registerDecoderFormat records that a decoder for name is compiled in.
+func registerDecoderFormat(name string) {
+	decoderFormats = append(decoderFormats, name)
+}
+
+// encoderFormats maps the formats encodeImage can write to the file
+// extensions that select them.
+var encoderFormats = map[string][]string{
+	"gif":  {".gif"},
+	"jpeg": {".jpg", ".jpeg"},
+	"png":  {".png"},
+}
+
+// writeFormats prints the decodable and encodable formats, for the formats
+// command.
+func writeFormats(w io.Writer) {
+	decoders := append([]string(nil), decoderFormats...)
+	sort.Strings(decoders)
+	fmt.Fprintln(w, "Decoders:")
+	for _, name := range decoders {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+
+	encoders := make([]string, 0, len(encoderFormats))
+	for name := range encoderFormats {
+		encoders = append(encoders, name)
+	}
+	sort.Strings(encoders)
+	fmt.Fprintln(w, "Encoders (saving):")
+	for _, name := range encoders {
+		fmt.Fprintf(w, "  %-6s %s\n", name, strings.Join(encoderFormats[name], " "))
+	}
+}
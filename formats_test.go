@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteFormats(t *testing.T) {
+	var buf bytes.Buffer
+	writeFormats(&buf)
+	out := buf.String()
+
+	decoders, encoders, ok := strings.Cut(out, "Encoders (saving):")
+	if !ok || !strings.HasPrefix(decoders, "Decoders:\n") {
+		t.Fatalf("output lacks the decoder and encoder sections:\n%s", out)
+	}
+	for _, name := range []string{"gif", "jpeg", "png", "svg"} {
+		if !strings.Contains(decoders, "  "+name+"\n") {
+			t.Errorf("decoders lack %s:\n%s", name, decoders)
+		}
+	}
+	for _, line := range []string{"gif    .gif", "jpeg   .jpg .jpeg", "png    .png"} {
+		if !strings.Contains(encoders, "  "+line+"\n") {
+			t.Errorf("encoders lack %q:\n%s", line, encoders)
+		}
+	}
+	// SVG is rasterized on load; there is no encoder for it.
+	if strings.Contains(encoders, "svg") {
+		t.Errorf("encoders list svg:\n%s", encoders)
+	}
+}
+
+func TestRegisterDecoderFormat(t *testing.T) {
+	old := decoderFormats
+	t.Cleanup(func() { decoderFormats = old })
+	decoderFormats = append([]string(nil), old...)
+
+	registerDecoderFormat("webp")
+	var buf bytes.Buffer
+	writeFormats(&buf)
+	if !strings.Contains(buf.String(), "  webp\n") {
+		t.Errorf("a registered decoder is not listed:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"html"
+	"strings"
+)
+
+// GalleryData lists the images of a gallery post in display order.
+type GalleryData struct {
+	Items []GalleryItem `json:"items"`
+}
+
+// GalleryItem is one image of a gallery; MediaID keys into the post's
+// MediaMetadata.
+type GalleryItem struct {
+	MediaID string `json:"media_id"`
+	Caption string `json:"caption"`
+}
+
+// MediaMetadata describes an uploaded gallery image.
+type MediaMetadata struct {
+	Status string `json:"status"`
+	// Mime is the image type, e.g. "image/jpg".
+	Mime   string `json:"m"`
+	Source struct {
+		URL string `json:"u"`
+		GIF string `json:"gif"`
+	} `json:"s"`
+	// Previews are scaled down copies, smallest first.
+	Previews []MediaPreview `json:"p"`
+}
+
+// MediaPreview is a scaled down copy of a gallery image.
+type MediaPreview struct {
+	URL    string `json:"u"`
+	Width  int    `json:"x"`
+	Height int    `json:"y"`
+}
+
+// galleryImage is a single image of a gallery post, its caption and its
+// scaled down previews, if any.
+type galleryImage struct {
+	URL     string
+	Caption string
+	Preview *Preview
+}
+
+// galleryImages returns the images of a gallery post in order, with each
+// caption falling back to the post title. It returns nil for other posts.
+func (p Post) galleryImages() []galleryImage {
+	if !p.IsGallery || p.GalleryData == nil {
+		return nil
+	}
+
+	var images []galleryImage
+	for _, item := range p.GalleryData.Items {
+		meta, ok := p.MediaMetadata[item.MediaID]
+		if !ok || (meta.Status != "" && meta.Status != "valid") {
+			continue
+		}
+		url := galleryImageURL(item.MediaID, meta)
+		if url == "" {
+			continue
+		}
+
+		caption := strings.TrimSpace(item.Caption)
+		if caption == "" {
+			caption = p.Title
+		}
+		images = append(images, galleryImage{URL: url, Caption: caption, Preview: meta.preview()})
+	}
+	return images
+}
+
+// preview returns the gallery image's previews in the shape of a post's
+// preview, or nil if it has none.
+func (m MediaMetadata) preview() *Preview {
+	if len(m.Previews) == 0 {
+		return nil
+	}
+	img := PreviewImage{Source: PreviewSource{URL: m.Source.URL}}
+	for _, r := range m.Previews {
+		img.Resolutions = append(img.Resolutions, PreviewSource{URL: r.URL, Width: r.Width, Height: r.Height})
+	}
+	return &Preview{Images: []PreviewImage{img}}
+}
+
+// galleryImageURL prefers the direct i.redd.it URL derived from the media id
+// and type, falling back to the (HTML-escaped) source URL in the metadata.
+func galleryImageURL(mediaID string, meta MediaMetadata) string {
+	switch meta.Mime {
+	case "image/jpg", "image/jpeg":
+		return "https://i.redd.it/" + mediaID + ".jpg"
+	case "image/png":
+		return "https://i.redd.it/" + mediaID + ".png"
+	case "image/gif":
+		return "https://i.redd.it/" + mediaID + ".gif"
+	}
+	if meta.Source.GIF != "" {
+		return html.UnescapeString(meta.Source.GIF)
+	}
+	return html.UnescapeString(meta.Source.URL)
+}
+
+// expandGalleries replaces every gallery post with one post per image, in
+// gallery order, each carrying the image URL, its caption and its previews.
+// With collapse only the first image is kept, with GallerySize set to the
+// number of images. Other posts are passed through unchanged.
+func expandGalleries(posts []Post, collapse bool) []Post {
+	var expanded []Post
+	for _, post := range posts {
+		images := post.galleryImages()
+		if images == nil {
+			expanded = append(expanded, post)
+			continue
+		}
+		if collapse && len(images) > 0 {
+			p := post
+			p.URL = images[0].URL
+			p.Caption = images[0].Caption
+			p.Preview = images[0].Preview
+			p.GalleryIndex = 1
+			p.GallerySize = len(images)
+			expanded = append(expanded, p)
+			continue
+		}
+		for i, img := range images {
+			p := post
+			p.URL = img.URL
+			p.Caption = img.Caption
+			p.Preview = img.Preview
+			p.GalleryIndex = i + 1
+			expanded = append(expanded, p)
+		}
+	}
+	return expanded
+}
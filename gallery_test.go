@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+)
+
+func TestGalleryImagesCaptions(t *testing.T) {
+	gallery := fixturePosts(t, "r_gallery.json")[0]
+
+	images := gallery.galleryImages()
+	want := []struct{ url, caption string }{
+		{"https://mock.invalid/img/m1.png", "Front"},
+		// An empty caption falls back to the post title.
+		{"https://mock.invalid/img/m2.png", "My gallery"},
+		// m3 failed processing on Reddit's side and is left out.
+		{"https://mock.invalid/img/m4.png", "Back"},
+	}
+	if len(images) != len(want) {
+		t.Fatalf("got %d images, want %d", len(images), len(want))
+	}
+	for i, w := range want {
+		if images[i].URL != w.url || images[i].Caption != w.caption {
+			t.Errorf("image %d = %s %q, want %s %q", i, images[i].URL, images[i].Caption, w.url, w.caption)
+		}
+	}
+}
+
+func TestGalleryImageURLPrefersDirectLink(t *testing.T) {
+	meta := MediaMetadata{Mime: "image/jpg"}
+	meta.Source.URL = "https://preview.redd.it/abc.jpg?width=1080&amp;s=x"
+	if got, want := galleryImageURL("abc", meta), "https://i.redd.it/abc.jpg"; got != want {
+		t.Errorf("galleryImageURL = %s, want %s", got, want)
+	}
+
+	meta.Mime = ""
+	if got, want := galleryImageURL("abc", meta), "https://preview.redd.it/abc.jpg?width=1080&s=x"; got != want {
+		t.Errorf("galleryImageURL without a type = %s, want the unescaped source %s", got, want)
+	}
+}
+
+func TestExpandGalleriesAssignsCaptionsAndIndexes(t *testing.T) {
+	posts := expandGalleries(fixturePosts(t, "r_gallery.json"), false)
+
+	// Three gallery images, then the spoiler post as it was.
+	if len(posts) != 4 {
+		t.Fatalf("got %d posts, want 4", len(posts))
+	}
+	for i, caption := range []string{"Front", "My gallery", "Back"} {
+		p := posts[i]
+		if p.Caption != caption || p.GalleryIndex != i+1 || p.ID != "g1" {
+			t.Errorf("image %d: caption %q, index %d, id %s; want %q, %d, g1", i, p.Caption, p.GalleryIndex, p.ID, caption, i+1)
+		}
+	}
+	if posts[3].ID != "s1" || posts[3].GalleryIndex != 0 {
+		t.Errorf("non-gallery post changed: %+v", posts[3])
+	}
+}
+
+func TestExpandedGalleryFilenamesDiffer(t *testing.T) {
+	tmpl, err := parseFilenameTemplate(defaultFilenameTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, p := range expandGalleries(fixturePosts(t, "r_gallery.json"), false)[:3] {
+		name := tmpl.expand(postFilenameFields(p, p.Title, ".png"))
+		if seen[name] {
+			t.Errorf("two gallery images are both saved as %s", name)
+		}
+		seen[name] = true
+	}
+	if !seen["My_gallery_2.png"] {
+		t.Errorf("names = %v, want My_gallery_2.png among them", seen)
+	}
+}
+
+// fourImageGallery returns a gallery post of four images served by m.
+func fourImageGallery(t *testing.T, m *mockReddit) Post {
+	post := Post{ID: "g4", Title: "Four", IsGallery: true, GalleryData: &GalleryData{}, MediaMetadata: map[string]MediaMetadata{}}
+	for i := range 4 {
+		id := fmt.Sprintf("m%d", i+1)
+		var meta MediaMetadata
+		meta.Status = "valid"
+		meta.Source.URL = m.addImage(id+".png", pngBytes(t, 8, 8, color.White))
+		post.MediaMetadata[id] = meta
+		post.GalleryData.Items = append(post.GalleryData.Items, GalleryItem{MediaID: id})
+	}
+	return post
+}
+
+func TestGalleryModesCardCounts(t *testing.T) {
+	tests := []struct {
+		mode      string
+		wantCards int
+		wantBadge bool
+	}{
+		{"flatten", 4, false},
+		{"collapse", 1, true},
+	}
+	for _, tt := range tests {
+		m := newMockReddit(t)
+		r := newMockRunner(t, m, "show", "--subreddit=pics", "--galleries="+tt.mode)
+		posts := r.expand([]Post{fourImageGallery(t, m)})
+		r.close()
+
+		s := newMockWindowSession(t, m)
+		results, _ := s.processPosts(context.Background(), posts, 1, 1)
+		var cards []fyne.CanvasObject
+		for _, res := range results {
+			if res != nil && res.card != nil {
+				cards = append(cards, res.card)
+			}
+		}
+		if len(cards) != tt.wantCards {
+			t.Errorf("--galleries=%s: %d cards, want %d", tt.mode, len(cards), tt.wantCards)
+			continue
+		}
+		var badge bool
+		for _, text := range findObjects[*canvas.Text](cards[0]) {
+			badge = badge || text.Text == "4 images"
+		}
+		if badge != tt.wantBadge {
+			t.Errorf("--galleries=%s: first card has a \"4 images\" badge %v, want %v", tt.mode, badge, tt.wantBadge)
+		}
+	}
+}
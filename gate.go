@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// downloadGate lets the UI pause downloads. While it is paused no new
+// download starts; those in flight finish normally. A nil gate is always
+// open.
+type downloadGate struct {
+	mu sync.Mutex
+	// open is closed while the gate is open and replaced by a fresh
+	// channel on Pause, so waiters block until Resume closes it.
+	open chan struct{}
+}
+
+func newDownloadGate() *downloadGate {
+	open := make(chan struct{})
+	close(open)
+	return &downloadGate{open: open}
+}
+
+// Pause stops new downloads from starting until Resume.
+func (g *downloadGate) Pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.open:
+		g.open = make(chan struct{})
+	default:
+		// Already paused.
+	}
+}
+
+// Resume lets downloads start again and releases everyone waiting.
+func (g *downloadGate) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.open:
+		// Not paused.
+	default:
+		close(g.open)
+	}
+}
+
+// Paused reports whether the gate is paused.
+func (g *downloadGate) Paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.open:
+		return false
+	default:
+		return true
+	}
+}
+
+// Wait blocks while the gate is paused. It fails only if ctx is done first.
+func (g *downloadGate) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	open := g.open
+	g.mu.Unlock()
+
+	select {
+	case <-open:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitReturned reports whether done is closed within d.
+func waitReturned(done <-chan struct{}, d time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+
+func TestDownloadGateBlocksWhilePaused(t *testing.T) {
+	g := newDownloadGate()
+	if err := g.Wait(context.Background()); err != nil || g.Paused() {
+		t.Fatalf("new gate: Wait = %v, paused %v; want open", err, g.Paused())
+	}
+
+	g.Pause()
+	g.Pause()
+	if !g.Paused() {
+		t.Fatal("gate not paused after Pause")
+	}
+	done := make([]chan struct{}, 3)
+	for i := range done {
+		done[i] = make(chan struct{})
+		go func() {
+			g.Wait(context.Background())
+			close(done[i])
+		}()
+	}
+	for i := range done {
+		if waitReturned(done[i], 50*time.Millisecond) {
+			t.Fatalf("worker %d proceeded while paused", i)
+		}
+	}
+
+	g.Resume()
+	for i := range done {
+		if !waitReturned(done[i], time.Second) {
+			t.Fatalf("worker %d still blocked after Resume", i)
+		}
+	}
+	g.Resume()
+	if g.Paused() {
+		t.Error("gate paused after a second Resume")
+	}
+}
+
+func TestDownloadGateWaitCancelled(t *testing.T) {
+	g := newDownloadGate()
+	g.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait on a paused gate = %v, want the context's error", err)
+	}
+	var open *downloadGate
+	if err := open.Wait(ctx); err != nil || open.Paused() {
+		t.Errorf("nil gate: Wait = %v, paused %v; want open", err, open.Paused())
+	}
+}
+
+func TestPausedGateHoldsBackDownloads(t *testing.T) {
+	m := newMockReddit(t)
+	posts := mockPosts(t, m, 3)
+	s := newMockSession(m)
+	s.gate = newDownloadGate()
+	s.gate.Pause()
+
+	done := make(chan struct{})
+	var results []*postResult
+	go func() {
+		results, _ = s.processPosts(context.Background(), posts, 2, 2)
+		close(done)
+	}()
+	if waitReturned(done, 100*time.Millisecond) {
+		t.Fatal("processPosts finished while paused")
+	}
+	if got := m.requestLog(); len(got) != 0 {
+		t.Fatalf("downloaded %q while paused", got)
+	}
+
+	s.gate.Resume()
+	if !waitReturned(done, 5*time.Second) {
+		t.Fatal("processPosts did not finish after Resume")
+	}
+	for i, result := range results {
+		if result == nil {
+			t.Errorf("post %d failed", i)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// gfycatResolver claims gfycat links so they are skipped with a clear
+// reason. Gfycat shut down in 2023 and its hosts no longer serve media, so
+// fetching a link only ends in a confusing download or decode error.
+type gfycatResolver struct{}
+
+func (gfycatResolver) Name() string { return "gfycat" }
+
+func (gfycatResolver) Match(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == "gfycat.com" || strings.HasSuffix(host, ".gfycat.com")
+}
+
+func (gfycatResolver) Resolve(ctx context.Context, rawURL string) (string, error) {
+	return "", fmt.Errorf("%w: gfycat no longer serves media", ErrUnsupportedHost)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGfycatResolverMatch(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"https://gfycat.com/SomeAnimal":        true,
+		"https://thumbs.gfycat.com/Some.gif":   true,
+		"https://GIANT.gfycat.com/Some.mp4":    true,
+		"https://notgfycat.com/SomeAnimal":     false,
+		"https://i.redd.it/gfycat.com.png":     false,
+		"https://www.redgifs.com/watch/animal": false,
+	} {
+		u, _ := url.Parse(raw)
+		if got := (gfycatResolver{}).Match(u); got != want {
+			t.Errorf("Match(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestGfycatLinksSkippedAsUnsupported(t *testing.T) {
+	reg := newResolverRegistry(gfycatResolver{})
+	_, err := reg.resolve(context.Background(), "https://gfycat.com/SomeAnimal")
+	if !errors.Is(err, ErrUnsupportedHost) {
+		t.Fatalf("err = %v, want ErrUnsupportedHost", err)
+	}
+	if !strings.HasPrefix(err.Error(), "gfycat: ") {
+		t.Errorf("err = %q, want it to name gfycat", err)
+	}
+
+	m := newMockReddit(t)
+	s := newMockSession(m)
+	s.resolvers = reg
+	results, _ := s.processPosts(context.Background(), []Post{{ID: "g", Title: "Gif", URL: "https://gfycat.com/SomeAnimal"}}, 1, 1)
+	if results[0] != nil {
+		t.Error("a gfycat post was shown")
+	}
+	if got := s.stats.failures["unsupported-host"]; got != 1 {
+		t.Errorf("unsupported-host skips = %d, want 1", got)
+	}
+	// Skipping a host on purpose is not a failure.
+	if got := s.stats.Failed(); got != 0 {
+		t.Errorf("Failed() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// hostLimiter caps the number of downloads in flight to any one host, so
+// that a feed dominated by one CDN does not get the whole worker pool
+// pointed at it. A nil hostLimiter imposes no limit.
+type hostLimiter struct {
+	limit int
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+// newHostLimiter returns a limiter allowing limit downloads per host, or
+// nil for no limit when limit is zero or less.
+func newHostLimiter(limit int) *hostLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &hostLimiter{limit: limit, sems: make(map[string]chan struct{})}
+}
+
+// acquire waits for a download slot for the host of rawURL and returns the
+// function releasing it. It fails only if ctx is done first.
+func (l *hostLimiter) acquire(ctx context.Context, rawURL string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+
+	l.mu.Lock()
+	sem, ok := l.sems[host]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.sems[host] = sem
+	}
+	l.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
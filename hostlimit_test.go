@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPerHostConcurrencyLimit(t *testing.T) {
+	const perHost = 2
+
+	m := newMockReddit(t)
+	data := pngBytes(t, 8, 8, color.White)
+	var mu sync.Mutex
+	inFlight, peak := make(map[string]int), make(map[string]int)
+	m.handle("/img/slow.png", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight[r.Host]++
+		peak[r.Host] = max(peak[r.Host], inFlight[r.Host])
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight[r.Host]--
+		mu.Unlock()
+		w.Write(data)
+	})
+	// The same server under two names is two hosts.
+	hosts := []string{m.URL, strings.Replace(m.URL, "127.0.0.1", "localhost", 1)}
+	var posts []Post
+	for i := range 16 {
+		posts = append(posts, Post{ID: fmt.Sprint(i), URL: fmt.Sprintf("%s/img/slow.png?n=%d", hosts[i%2], i)})
+	}
+
+	s := newMockSession(m)
+	s.dl.hosts = newHostLimiter(perHost)
+	results, _ := s.processPosts(context.Background(), posts, 8, 8)
+	for i, result := range results {
+		if result == nil {
+			t.Fatalf("post %d failed", i)
+		}
+	}
+	if len(peak) != 2 {
+		t.Fatalf("requests went to hosts %v, want 2 hosts", peak)
+	}
+	for host, n := range peak {
+		if n > perHost {
+			t.Errorf("%d requests to %s at once, want at most %d", n, host, perHost)
+		}
+	}
+}
+
+func TestHostLimiterWaitsForSlot(t *testing.T) {
+	l := newHostLimiter(1)
+	release, err := l.acquire(context.Background(), "https://I.Redd.it/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another host is not held up.
+	other, err := l.acquire(context.Background(), "https://i.imgur.com/b.png")
+	if err != nil {
+		t.Fatalf("acquire for another host: %v", err)
+	}
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, "https://i.redd.it/c.png"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire on a full host = %v, want to wait until the deadline", err)
+	}
+	release()
+	if release, err := l.acquire(context.Background(), "https://i.redd.it/c.png"); err != nil {
+		t.Errorf("acquire after release: %v", err)
+	} else {
+		release()
+	}
+
+	if newHostLimiter(0) != nil {
+		t.Error("a zero limit is not unlimited")
+	}
+}
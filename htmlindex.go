@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"path/filepath"
+)
+
+// indexFileName is the name of the HTML gallery written by --save-index.
+const indexFileName = "index.html"
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{"fileURL": fileURL, "siteURL": siteURL}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Saved images</title>
+<style>
+body { font-family: sans-serif; margin: 1em; background: #111; color: #eee; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(250px, 1fr)); gap: 1em; }
+figure { margin: 0; }
+img { width: 100%; height: auto; display: block; }
+figcaption { margin-top: .3em; font-size: .9em; }
+a { color: #8cf; }
+</style>
+</head>
+<body>
+<div class="grid">
+{{- range .}}
+<figure>
+<a href="{{fileURL .File}}"><img src="{{fileURL .File}}" alt="{{.Title}}" loading="lazy"></a>
+<figcaption>{{if .Permalink}}<a href="{{siteURL .Permalink}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</figcaption>
+</figure>
+{{- end}}
+</div>
+</body>
+</html>
+`))
+
+// fileURL returns the relative URL of the saved file name, escaped so that
+// characters such as '#', '?' and '%' are part of the name rather than
+// starting a fragment or query.
+func fileURL(name string) template.URL {
+	u := url.URL{Path: name}
+	return template.URL(u.String())
+}
+
+// writeHTMLIndex writes an index.html to dir showing every post in posts
+// that has a saved File, with its title linking back to Reddit.
+func writeHTMLIndex(dir string, posts []Post) error {
+	var saved []Post
+	for _, p := range posts {
+		if p.File != "" {
+			saved = append(saved, p)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := indexTemplate.Execute(&buf, saved); err != nil {
+		return fmt.Errorf("failed to render index: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, indexFileName), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
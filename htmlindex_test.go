@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTMLIndex(t *testing.T) {
+	dir := t.TempDir()
+	posts := []Post{
+		{Title: "Cats & dogs", File: "cats_and_dogs.jpg", Permalink: "/r/pics/comments/a/cats/"},
+		{Title: `<script>alert("hi")</script>`, File: "script.png"},
+		{Title: "Not saved", URL: "https://i.redd.it/skipped.png"},
+		{Title: "Half off", File: "50%_off#1?.png"},
+	}
+	if err := writeHTMLIndex(dir, posts); err != nil {
+		t.Fatalf("writeHTMLIndex: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+
+	for _, want := range []string{
+		`<img src="cats_and_dogs.jpg"`,
+		`<img src="script.png"`,
+		`<a href="50%25_off%231%3F.png"><img src="50%25_off%231%3F.png"`,
+		`href="https://www.reddit.com/r/pics/comments/a/cats/"`,
+		"Cats &amp; dogs",
+		"&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("index lacks %s", want)
+		}
+	}
+	for _, unwanted := range []string{"<script>", "Not saved"} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("index contains %s", unwanted)
+		}
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestHTMLIndexLinksUnderCustomBase(t *testing.T) {
+	old := redditBaseURL
+	t.Cleanup(func() { redditBaseURL = old })
+	base, err := parseBaseURL("https://old.reddit.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redditBaseURL = base
+
+	dir := t.TempDir()
+	posts := []Post{{Title: "Cat", File: "cat.jpg", Permalink: "/r/pics/comments/a/cat/"}}
+	if err := writeHTMLIndex(dir, posts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `href="https://old.reddit.com/r/pics/comments/a/cat/"`; !strings.Contains(string(data), want) {
+		t.Errorf("index lacks %s:\n%s", want, data)
+	}
+}
+
+// readIndex returns the index.html in the download directory.
+func readIndex(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(downloadDir, indexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSaveIndexListsImagesOfEarlierRuns(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	addPicsImages(t, m)
+	chdirTemp(t)
+
+	for _, args := range [][]string{{"--limit=1"}, {"--limit=3", "--resume"}} {
+		r := newMockRunner(t, m, append([]string{"download", "--subreddit=pics", "--save-index"}, args...)...)
+		if err := runDownload(r); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := newManifest(filepath.Join(downloadDir, manifestFileName))
+	if err := saved.Load(); err != nil {
+		t.Fatal(err)
+	}
+	entries := saved.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("manifest has %d entries, want 3", len(entries))
+	}
+	html := readIndex(t)
+	for _, e := range entries {
+		if !strings.Contains(html, `src="`+string(fileURL(e.File))+`"`) {
+			t.Errorf("index lacks %s, saved by the first run or the second", e.File)
+		}
+		if e.Permalink == "" || !strings.Contains(html, e.Permalink) {
+			t.Errorf("index lacks the permalink %q of %s", e.Permalink, e.File)
+		}
+	}
+}
+
+func TestSaveIndexRewrittenAfterWatchPoll(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json")
+	addPicsImages(t, m)
+	chdirTemp(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := newMockRunnerContext(t, ctx, m, "download", "--subreddit=pics", "--limit=2", "--save-index", "--poll-interval=1ms")
+	defer r.close()
+
+	// The first post was there before watching began, the second is new.
+	seen := newSeenPosts()
+	seen.diff([]Post{{ID: "p1"}})
+	r.watch(r.newSession(nil), seen, func(*postResult) { cancel() })
+
+	html := readIndex(t)
+	if n := strings.Count(html, "<figure>"); n != 1 {
+		t.Errorf("index after the poll lists %d images, want the new one", n)
+	}
+}
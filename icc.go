@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Go's decoders ignore embedded ICC colour profiles, so images tagged with a
+// wide-gamut profile (Display P3, Adobe RGB, ...) are shown as if they were
+// sRGB, with washed out or shifted colours. Full colour management is out of
+// scope; hasICCProfile only sniffs for a profile so the user can be told.
+
+var (
+	jpegICCIdentifier = []byte("ICC_PROFILE\x00")
+	pngSignature      = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// hasICCProfile reports whether data, a JPEG or PNG file, embeds an ICC
+// profile. Other formats, and files too damaged to walk, report false.
+func hasICCProfile(data []byte) bool {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return jpegHasICCProfile(data[2:])
+	case bytes.HasPrefix(data, pngSignature):
+		return pngHasICCProfile(data[len(pngSignature):])
+	}
+	return false
+}
+
+// jpegHasICCProfile walks the marker segments after SOI looking for an APP2
+// segment carrying an ICC profile. Metadata segments all come before the
+// image data, so the walk stops at the first start of scan.
+func jpegHasICCProfile(data []byte) bool {
+	for len(data) >= 4 {
+		if data[0] != 0xff {
+			return false
+		}
+		marker := data[1]
+		switch {
+		case marker == 0xff:
+			// Fill byte before a marker.
+			data = data[1:]
+			continue
+		case marker == 0xd9 || marker == 0xda:
+			// EOI or SOS: no more metadata.
+			return false
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// Standalone markers without a length.
+			data = data[2:]
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(data[2:4]))
+		if n < 2 || 2+n > len(data) {
+			return false
+		}
+		if marker == 0xe2 && bytes.HasPrefix(data[4:2+n], jpegICCIdentifier) {
+			return true
+		}
+		data = data[2+n:]
+	}
+	return false
+}
+
+// pngHasICCProfile walks the chunks after the signature looking for iCCP,
+// which must come before the first IDAT.
+func pngHasICCProfile(data []byte) bool {
+	for len(data) >= 8 {
+		n := int(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		switch typ {
+		case "iCCP":
+			return true
+		case "IDAT", "IEND":
+			return false
+		}
+		// Length, type, data and CRC.
+		if n < 0 || 12+n > len(data) {
+			return false
+		}
+		data = data[12+n:]
+	}
+	return false
+}
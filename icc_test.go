@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image/color"
+	"testing"
+)
+
+// fakeICCProfile stands in for a real profile; only its presence matters.
+var fakeICCProfile = append([]byte("\x00\x00\x02\x0cappl"), bytes.Repeat([]byte{0x42}, 64)...)
+
+// withJPEGSegment returns the JPEG data with a marker segment carrying
+// payload inserted after SOI, where encoders put metadata.
+func withJPEGSegment(data []byte, marker byte, payload []byte) []byte {
+	segment := binary.BigEndian.AppendUint16([]byte{0xff, marker}, uint16(2+len(payload)))
+	segment = append(segment, payload...)
+	return append(append(append([]byte(nil), data[:2]...), segment...), data[2:]...)
+}
+
+// withPNGChunk returns the PNG data with a chunk of type typ inserted after
+// IHDR.
+func withPNGChunk(data []byte, typ string, payload []byte) []byte {
+	body := append([]byte(typ), payload...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+	// The signature, then IHDR's length, type, 13 bytes of data and CRC.
+	at := len(pngSignature) + 8 + 13 + 4
+	return append(append(append([]byte(nil), data[:at]...), chunk...), data[at:]...)
+}
+
+// withJPEGICC returns the JPEG data with an APP2 ICC profile segment.
+func withJPEGICC(data []byte) []byte {
+	payload := append(append([]byte(nil), jpegICCIdentifier...), 1, 1) // chunk 1 of 1
+	return withJPEGSegment(data, 0xe2, append(payload, fakeICCProfile...))
+}
+
+// withPNGICC returns the PNG data with an iCCP chunk.
+func withPNGICC(data []byte) []byte {
+	return withPNGChunk(data, "iCCP", append([]byte("Display P3\x00\x00"), fakeICCProfile...))
+}
+
+func TestHasICCProfile(t *testing.T) {
+	png := pngBytes(t, 8, 8, color.White)
+	jpg := jpegBytes(t, 8, 8)
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"plain PNG", png, false},
+		{"PNG with iCCP", withPNGICC(png), true},
+		{"plain JPEG", jpg, false},
+		{"JPEG with APP2 ICC", withJPEGICC(jpg), true},
+		{"GIF", []byte("GIF89a"), false},
+		{"truncated JPEG", withJPEGICC(jpg)[:6], false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if got := hasICCProfile(tt.data); got != tt.want {
+			t.Errorf("%s: hasICCProfile = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeFlagsICCProfile(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"png": withPNGICC(pngBytes(t, 8, 8, color.White)),
+		"jpg": withJPEGICC(jpegBytes(t, 8, 8)),
+	} {
+		downloaded, err := decodeRawImage(&rawImage{Data: data, FinalURL: "https://i.redd.it/p3." + name}, 0)
+		if err != nil {
+			t.Fatalf("%s with a profile no longer decodes: %v", name, err)
+		}
+		if !downloaded.ICCProfile {
+			t.Errorf("%s: ICCProfile not set", name)
+		}
+	}
+}
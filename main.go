@@ -1,131 +1,515 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"log"
+	"math/rand"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"golang.org/x/image/draw"
-
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/app"
-	"fyne.io/fyne/v2/canvas"
-	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/theme"
 )
 
 type Post struct {
-	Title string `json:"title"`
-	URL   string `json:"url"`
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Permalink string `json:"permalink"`
+	Subreddit string `json:"subreddit"`
+	Author    string `json:"author"`
+	Score     int    `json:"score"`
+	// Spoiler marks posts the author flagged as spoilers, whose images
+	// are blurred until revealed.
+	Spoiler bool `json:"spoiler"`
+	// URLOverriddenByDest is the real destination of link posts whose url
+	// is the post's own permalink.
+	URLOverriddenByDest string `json:"url_overridden_by_dest"`
+
+	IsSelf   bool   `json:"is_self"`
+	Selftext string `json:"selftext"`
+
+	IsVideo bool     `json:"is_video"`
+	Media   *Media   `json:"media"`
+	Preview *Preview `json:"preview"`
+
+	IsGallery     bool                     `json:"is_gallery"`
+	GalleryData   *GalleryData             `json:"gallery_data"`
+	MediaMetadata map[string]MediaMetadata `json:"media_metadata"`
+
+	// Caption and GalleryIndex (1-based) identify an expanded gallery
+	// image, and GallerySize is the number of images in a gallery
+	// collapsed to its first. They are not part of the Reddit response.
+	Caption      string `json:"-"`
+	GalleryIndex int    `json:"-"`
+	GallerySize  int    `json:"-"`
+	// File is the name the image was saved under in the download
+	// directory, once saved.
+	File string `json:"-"`
+}
+
+// destinationURL returns the URL the post links to, preferring
+// url_overridden_by_dest over url when Reddit provides it.
+func (p Post) destinationURL() string {
+	if p.URLOverriddenByDest != "" {
+		return p.URLOverriddenByDest
+	}
+	return p.URL
 }
 
 type RedditResponse struct {
 	Data struct {
 		After    string `json:"after"`
+		Before   string `json:"before"`
 		Children []struct {
 			Data Post `json:"data"`
 		} `json:"children"`
 	} `json:"data"`
 }
 
-func fetchRedditData(subreddit string, limit int) ([]Post, error) {
+// pageDirection is which way a listing is paginated: forward follows the
+// after cursor to older posts, backward follows before to newer ones.
+type pageDirection int
+
+const (
+	forward pageDirection = iota
+	backward
+)
+
+// listingCursors are the after and before cursors of a listing page.
+type listingCursors struct {
+	After  string
+	Before string
+}
+
+// defaultRedditBaseURL is the default of --base-url.
+const defaultRedditBaseURL = "https://www.reddit.com"
+
+// redditBaseURL is where listings are fetched from: Reddit itself, or a
+// frontend or proxy serving the same API, possibly under a path prefix. It
+// is set from --base-url.
+var redditBaseURL = &neturl.URL{Scheme: "https", Host: "www.reddit.com"}
+
+// parseBaseURL validates a --base-url value: an absolute http or https URL
+// with no query or fragment. A trailing slash is dropped.
+func parseBaseURL(raw string) (*neturl.URL, error) {
+	u, err := neturl.Parse(strings.TrimSuffix(raw, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%q is not an http or https URL", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no host", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("%q must not have a query or fragment", raw)
+	}
+	return u, nil
+}
+
+// listingURL returns the URL of a subreddit feed such as ".json" or ".rss"
+// with query, under redditBaseURL. The subreddit is escaped as a single
+// path segment, so names read from stdin or the command line cannot add
+// path components or a query of their own.
+func listingURL(subreddit, feed string, query neturl.Values) string {
+	u := *redditBaseURL
+	u.Path = redditBaseURL.Path + "/r/" + subreddit + "/" + feed
+	u.RawPath = redditBaseURL.EscapedPath() + "/r/" + neturl.PathEscape(subreddit) + "/" + feed
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// siteURL returns the absolute URL of path, a site-relative path such as a
+// post's permalink, under redditBaseURL. Like listingURL, it keeps any path
+// prefix of the base, under which a proxy serves the whole site.
+func siteURL(path string) string {
+	return redditBaseURL.String() + path
+}
+
+// fetchRedditData fetches up to limit posts from subreddit, following the
+// listing's pagination forward from the newest post as needed. Posts for
+// which skip returns true are dropped and do not count towards the limit;
+// skip may be nil. On error the posts from pages fetched so far are
+// returned along with it.
+func fetchRedditData(ctx context.Context, client *http.Client, subreddit string, limit int, skip func(Post) bool) ([]Post, error) {
+	posts, _, err := fetchRedditPages(ctx, client, subreddit, limit, skip, forward, "")
+	return posts, err
+}
+
+// maxPageSize is the most posts Reddit returns in one listing page.
+const maxPageSize = 100
+
+// maxListingPages caps the pages fetched per subreddit when the limit is
+// zero or less, meaning every post the listing offers. It is set from
+// --max-pages.
+var maxListingPages = 10
+
+// fetchRedditPages is fetchRedditData paginating in dir from cursor, an
+// after or before value from an earlier fetch ("" to start at the newest
+// post). It also returns the cursors of the last page fetched, to continue
+// from later in either direction. A limit of zero or less fetches full
+// pages until the listing runs out, or maxListingPages have been fetched.
+func fetchRedditPages(ctx context.Context, client *http.Client, subreddit string, limit int, skip func(Post) bool, dir pageDirection, cursor string) ([]Post, listingCursors, error) {
+	param := "after"
+	if dir == backward {
+		param = "before"
+	}
+	all := limit <= 0
+	pageSize := limit
+	if all {
+		pageSize = maxPageSize
+	}
+
 	var allPosts []Post
-	after := ""
-	for {
-		url := fmt.Sprintf("https://www.reddit.com/r/%s/.json?limit=%d&after=%s", subreddit, limit, after)
-		log.Println("Fetching URL:", url)
-		req, _ := http.NewRequest("GET", url, nil)
-		req.Header.Set("User-Agent", "Go-Reddit-Client")
-
-		client := &http.Client{}
-		resp, err := client.Do(req)
+	var cursors listingCursors
+	for pages := 1; ; pages++ {
+		query := neturl.Values{"limit": {strconv.Itoa(pageSize)}}
+		if cursor != "" {
+			query.Set(param, cursor)
+		}
+		url := listingURL(subreddit, ".json", query)
+		redditResponse, err := fetchListingPageRetrying(ctx, client, url, subreddit, listingDumpPath(subreddit, cursor))
 		if err != nil {
-			log.Fatalf("Error making HTTP request: %v", err)
-			return nil, err
+			return allPosts, cursors, err
+		}
+		cursors = listingCursors{After: redditResponse.Data.After, Before: redditResponse.Data.Before}
+
+		allPosts = append(allPosts, pagePosts(redditResponse, skip)...)
+
+		next := cursors.After
+		if dir == backward {
+			next = cursors.Before
+		}
+		// An empty page can still carry a cursor; following it would fetch
+		// empty pages forever.
+		if len(redditResponse.Data.Children) == 0 || next == "" {
+			break
+		}
+		if all && pages >= maxListingPages {
+			log.Printf("Stopping r/%s after %d pages (--max-pages)", subreddit, pages)
+			break
+		}
+		if !all && len(allPosts) >= limit {
+			break
+		}
+
+		cursor = next
+	}
+
+	log.Printf("Fetched %d posts", len(allPosts))
+	if !all && len(allPosts) > limit {
+		allPosts = allPosts[:limit]
+	}
+	return allPosts, cursors, nil
+}
+
+// listingRetries is the number of extra attempts made at a listing page
+// that fails with a retryable status. It is set from --retries.
+var listingRetries = 2
+
+// fetchListingPageRetrying is fetchListingPage, retrying responses with a
+// status in the --retry-status set up to listingRetries times, waiting a
+// little longer before each attempt.
+func fetchListingPageRetrying(ctx context.Context, client *http.Client, url, subreddit, dumpPath string) (*RedditResponse, error) {
+	for attempt := 1; ; attempt++ {
+		page, err := fetchListingPage(ctx, client, url, subreddit, dumpPath)
+		if err == nil || !isRetryableStatusError(err) || attempt > listingRetries {
+			return page, err
 		}
-		defer resp.Body.Close()
+		log.Printf("Retrying %s (attempt %d of %d): %v", url, attempt, listingRetries, err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error making HTTP request: %w", ctx.Err())
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+}
+
+// pagePosts returns the posts of a listing page with their media URLs
+// filled in, dropping those for which skip returns true; skip may be nil.
+func pagePosts(page *RedditResponse, skip func(Post) bool) []Post {
+	var posts []Post
+	for _, child := range page.Data.Children {
+		post := child.Data
+		post.URL = post.mediaURL()
+		if skip != nil && skip(post) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	return posts
+}
+
+// listingDumpDir, if set, is where --save-json-listing writes the raw body
+// of every listing page fetched.
+var listingDumpDir string
+
+// listingDumpPath returns where to save the raw listing page of subreddit
+// fetched from cursor, or "" without --save-json-listing. The first page is
+// saved as r_<subreddit>.json, the name --fixtures-dir reads, and later
+// pages as r_<subreddit>_<cursor>.json.
+func listingDumpPath(subreddit, cursor string) string {
+	if listingDumpDir == "" {
+		return ""
+	}
+	name := "r_" + subreddit
+	if cursor != "" {
+		name += "_" + cursor
+	}
+	return filepath.Join(listingDumpDir, sanitizeFilename(name, ".json"))
+}
+
+// fetchListingPage fetches and decodes one page of a subreddit listing. The
+// body is decoded as it streams in rather than read into memory first,
+// unless dumpPath is set: then a JSON body is read in full and written
+// there verbatim before it is parsed.
+func fetchListingPage(ctx context.Context, client *http.Client, url, subreddit, dumpPath string) (*RedditResponse, error) {
+	log.Println("Fetching URL:", url)
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
 
-		body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 		if err != nil {
-			log.Fatalf("Error reading response body: %v", err)
-			return nil, err
+			return nil, fmt.Errorf("error reading response body: %w", err)
 		}
+		return nil, redditStatusError(subreddit, resp.StatusCode, body)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "json") {
+		return nil, fmt.Errorf("r/%s: %w (got %s; viewing it likely requires a logged-in account)", subreddit, ErrInterstitial, ct)
+	}
 
-		var redditResponse RedditResponse
-		err = json.Unmarshal(body, &redditResponse)
+	// Only JSON is saved, so --fixtures-dir can read every file written.
+	var body io.Reader = resp.Body
+	if dumpPath != "" {
+		raw, err := io.ReadAll(resp.Body)
 		if err != nil {
-			log.Fatalf("Error unmarshalling JSON: %v", err)
-			return nil, err
+			return nil, fmt.Errorf("error reading response body: %w", err)
+		}
+		if err := writeFileAtomic(dumpPath, raw); err != nil {
+			log.Printf("Failed to save listing: %v", err)
+		} else {
+			log.Printf("Saved listing: %s", dumpPath)
 		}
+		body = bytes.NewReader(raw)
+	}
 
-		for _, child := range redditResponse.Data.Children {
-			allPosts = append(allPosts, child.Data)
+	var redditResponse RedditResponse
+	if err := json.NewDecoder(body).Decode(&redditResponse); err != nil {
+		if isJSONError(err) {
+			return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
 		}
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	return &redditResponse, nil
+}
 
-		if len(allPosts) >= limit || redditResponse.Data.After == "" {
-			break
+// isJSONError reports whether err from a json.Decoder is due to malformed
+// or mismatched JSON rather than a failure reading the underlying stream.
+func isJSONError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// normalizeSubreddits trims names, drops any "r/" prefix and blank entries,
+// and removes case-insensitive duplicates while keeping the first spelling.
+func normalizeSubreddits(names []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "/"), "r/")
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, name)
+	}
+	return result
+}
+
+// dedupeByURL drops posts whose image URL, once normalized, repeats that of
+// an earlier post, as happens with crossposts or overlapping pages. Order
+// is kept.
+func dedupeByURL(posts []Post) []Post {
+	seen := make(map[string]bool)
+	var result []Post
+	for _, post := range posts {
+		key := post.URL
+		if u, err := normalizeURL(key); err == nil {
+			key = u
 		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, post)
+	}
+	return result
+}
 
-		after = redditResponse.Data.After
+// sample returns n posts chosen at random from posts, in their original
+// order. All posts are returned if there are no more than n.
+func sample(posts []Post, n int, rng *rand.Rand) []Post {
+	if n >= len(posts) {
+		return posts
+	}
+	picked := rng.Perm(len(posts))[:n]
+	sort.Ints(picked)
+	result := make([]Post, 0, n)
+	for _, i := range picked {
+		result = append(result, posts[i])
 	}
+	return result
+}
 
-	log.Printf("Fetched %d posts", len(allPosts))
-	return allPosts[:limit], nil
+// readSubreddits reads a newline-separated list of subreddit names, as used
+// by --subreddit=- for piping a list on stdin.
+func readSubreddits(r io.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		names = append(names, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read subreddits: %w", err)
+	}
+	return normalizeSubreddits(names), nil
 }
 
+// isValidImageURL reports whether the URL's path ends in a supported image
+// extension. Query strings, as on Reddit preview URLs, are ignored.
 func isValidImageURL(url string) bool {
-	re := regexp.MustCompile(`\.(gif|jpeg|jpg|png)$`)
-	return re.MatchString(strings.ToLower(url))
+	re := regexp.MustCompile(`\.(gif|jpeg|jpg|png|svg)$`)
+	return re.MatchString(strings.ToLower(urlExt(url)))
 }
 
-func downloadImage(url string) (image.Image, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to download image: %w", err)
+// normalizeURL turns the protocol-relative ("//i.imgur.com/x.jpg"),
+// scheme-less ("i.imgur.com/x.jpg") and site-relative ("/r/pics/...") URLs
+// some feeds contain into absolute URLs, site-relative ones under
+// redditBaseURL and the others over https. Absolute http and https URLs
+// are returned unchanged; anything else is an error.
+func normalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("empty URL")
+	}
+	switch {
+	case strings.HasPrefix(raw, "//"):
+		raw = "https:" + raw
+	case strings.HasPrefix(raw, "/"):
+		raw = siteURL(raw)
 	}
-	defer resp.Body.Close()
 
-	img, format, err := image.Decode(resp.Body)
+	u, err := neturl.Parse(raw)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return "", fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	if u.Scheme == "" {
+		host, _, _ := strings.Cut(u.Path, "/")
+		if !strings.Contains(host, ".") {
+			return "", fmt.Errorf("invalid URL %q: no host", raw)
+		}
+		return "https://" + raw, nil
 	}
-	log.Printf("Image format: %s", format)
-	return img, nil
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid URL %q: unsupported scheme %q", raw, u.Scheme)
+	}
+	return raw, nil
+}
+
+// defaultTitleTagPattern matches the bracketed tags and resolution suffixes
+// that commonly clutter titles, e.g. "[OC]", "(1920x1080)" or "3840 x 2160".
+const defaultTitleTagPattern = `\[[^\]]*\]|\([^)]*\)|\{[^}]*\}|\b\d{3,5}\s*[xX×]\s*\d{3,5}\b`
+
+// titleTagRe is the pattern cleanTitle strips from titles. It can be
+// overridden with --title-pattern.
+var titleTagRe = regexp.MustCompile(defaultTitleTagPattern)
+
+// cleanTitle removes tags matched by titleTagRe from a post title and tidies
+// up the whitespace and separators left behind.
+func cleanTitle(s string) string {
+	s = titleTagRe.ReplaceAllString(s, " ")
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.Trim(s, " -–—|,:")
+	return s
 }
 
-func saveImageToFile(img image.Image, fileName string) error {
-	dir := "imgDls"
+// downloadDir is the directory downloaded images and the manifest are
+// written to.
+const downloadDir = "imgDls"
+
+// checkOutputDir makes sure dir exists and that files can be created in it,
+// so an unwritable download directory is reported once up front rather
+// than as a failure for every image.
+func checkOutputDir(dir string) error {
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		return fmt.Errorf("cannot create output directory %s: %w", dir, err)
 	}
-
-	path := filepath.Join(dir, fileName)
-	file, err := os.Create(path)
+	f, err := os.CreateTemp(dir, ".write-check-*")
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return fmt.Errorf("output directory %s is not writable: %w", dir, err)
 	}
-	defer file.Close()
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
 
-	switch filepath.Ext(path) {
+// encodeImage encodes img to w in the format of the file extension ext.
+// When writing a GIF and anim is non-nil, all of its frames, delays and
+// loop count are written instead of img alone. When writing a JPEG and
+// flatten is non-nil, transparent areas are filled with it.
+func encodeImage(w io.Writer, img image.Image, anim *gif.GIF, ext string, flatten color.Color) error {
+	var err error
+	switch ext {
 	case ".jpg", ".jpeg":
-		err = jpeg.Encode(file, img, nil)
+		if flatten != nil {
+			img = flattenAlpha(img, flatten)
+		}
+		err = jpeg.Encode(w, img, nil)
 	case ".png":
-		err = png.Encode(file, img)
+		err = png.Encode(w, img)
 	case ".gif":
-		err = gif.Encode(file, img, nil)
+		if anim != nil {
+			err = gif.EncodeAll(w, anim)
+		} else {
+			err = gif.Encode(w, img, nil)
+		}
 	default:
-		return fmt.Errorf("unsupported file extension: %s", filepath.Ext(path))
+		return fmt.Errorf("unsupported file extension: %s", ext)
 	}
 	if err != nil {
 		return fmt.Errorf("failed to encode image: %w", err)
@@ -133,6 +517,22 @@ func saveImageToFile(img image.Image, fileName string) error {
 	return nil
 }
 
+// scalableSource returns img in a form the scalers interpolate correctly.
+// The common decoder outputs are used as they are; others, such as
+// paletted GIF frames and CMYK JPEGs, are converted to RGBA first, since
+// interpolating them through their generic colour conversion can shift
+// colours.
+func scalableSource(img image.Image) image.Image {
+	switch img.(type) {
+	case *image.RGBA, *image.NRGBA, *image.YCbCr, *image.Gray:
+		return img
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
 func resizeImage(img image.Image, maxWidth int) image.Image {
 	bounds := img.Bounds()
 	width := bounds.Dx()
@@ -143,8 +543,9 @@ func resizeImage(img image.Image, maxWidth int) image.Image {
 		newWidth := int(float64(width) * ratio)
 		newHeight := int(float64(height) * ratio)
 
+		src := scalableSource(img)
 		newImage := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-		draw.CatmullRom.Scale(newImage, newImage.Bounds(), img, bounds, draw.Over, nil)
+		draw.CatmullRom.Scale(newImage, newImage.Bounds(), src, src.Bounds(), draw.Over, nil)
 		return newImage
 	}
 
@@ -152,60 +553,58 @@ func resizeImage(img image.Image, maxWidth int) image.Image {
 }
 
 func main() {
-	subreddit := flag.String("subreddit", "archlinux", "Name of the subreddit to fetch images from")
-	download := flag.Bool("download", false, "Download images to the current directory when true")
-	limit := flag.Int("limit", 25, "Number of posts to fetch")
-	flag.Parse()
-
-	a := app.New()
-	w := a.NewWindow("Reddit Image Feed")
-
-	log.Println("Fetching data from subreddit:", *subreddit)
-	posts, err := fetchRedditData(*subreddit, *limit)
+	cfg, err := parseCommand(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatalf("Error fetching data: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.command == "formats" {
+		writeFormats(os.Stdout)
 		return
 	}
 
-	content := container.NewVBox()
+	// The first Ctrl+C or SIGTERM cancels the run: downloads in flight are
+	// aborted, those already downloaded still saved, and the run ends with
+	// its usual report. A second one kills the process as normal.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if cfg.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.deadline)
+		defer cancel()
+	}
 
-	for _, post := range posts {
-		if isValidImageURL(post.URL) {
-			img, err := downloadImage(post.URL)
-			if err != nil {
-				log.Printf("Skipping post: %s - %s. Error: %v", post.Title, post.URL, err)
-				continue
-			}
-
-			img = resizeImage(img, 400)
-
-			image := canvas.NewImageFromImage(img)
-			image.FillMode = canvas.ImageFillOriginal
-
-			title := canvas.NewText(post.Title, theme.ForegroundColor())
-			title.TextStyle = fyne.TextStyle{Bold: true}
-			title.TextSize = 16
-
-			content.Add(title)
-			content.Add(image)
-
-			if *download {
-				fileName := fmt.Sprintf("%s%s", strings.ReplaceAll(post.Title, " ", "_"), filepath.Ext(post.URL))
-				filePath := filepath.Join(".", fileName)
-				err := saveImageToFile(img, filePath)
-				if err != nil {
-					log.Printf("Failed to save image: %v", err)
-				} else {
-					log.Printf("Saved image: %s", filePath)
-				}
-			}
-		} else {
-			log.Printf("Skipping non-image URL: %s", post.URL)
-		}
+	r, err := newRunner(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
-	scroll := container.NewScroll(content)
-	w.SetContent(scroll)
-	w.Resize(fyne.NewSize(800, 600))
-	w.ShowAndRun()
+	switch cfg.command {
+	case "show":
+		err = runShow(r)
+	case "download":
+		err = runDownload(r)
+	case "list":
+		err = runList(r)
+	}
+	if cerr := r.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if cfg.openAfter {
+		dir := r.outputDir()
+		if err := openInFileManager(dir); err != nil {
+			log.Printf("Failed to open %s: %v", dir, err)
+		}
+	}
 }
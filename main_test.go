@@ -0,0 +1,644 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		title, want string
+	}{
+		{"Sunset over the lake [OC]", "Sunset over the lake"},
+		{"[OC] Sunset over the lake [3840x2160]", "Sunset over the lake"},
+		{"Mountain pass (1920x1080)", "Mountain pass"},
+		{"Old harbour, 4032 x 3024", "Old harbour"},
+		{"My cat {album} - finally asleep", "My cat - finally asleep"},
+		{"Fog [OC] | Iceland (6000×4000)", "Fog | Iceland"},
+		{"Nothing to strip here", "Nothing to strip here"},
+		{"[OC]", ""},
+	}
+	for _, tt := range tests {
+		if got := cleanTitle(tt.title); got != tt.want {
+			t.Errorf("cleanTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestCleanTitleCustomPattern(t *testing.T) {
+	old := titleTagRe
+	titleTagRe = regexp.MustCompile(`(?i)\bNSFW\b`)
+	t.Cleanup(func() { titleTagRe = old })
+
+	if got, want := cleanTitle("NSFW - Beach day [OC]"), "Beach day [OC]"; got != want {
+		t.Errorf("cleanTitle = %q, want %q", got, want)
+	}
+}
+
+func TestReadSubreddits(t *testing.T) {
+	input := "pics\n  r/EarthPorn \n\n/r/aww\nPICS\nwallpapers\r\n"
+	got, err := readSubreddits(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readSubreddits: %v", err)
+	}
+	want := []string{"pics", "EarthPorn", "aww", "wallpapers"}
+	if !slices.Equal(got, want) {
+		t.Errorf("readSubreddits = %q, want %q", got, want)
+	}
+}
+
+func TestReadSubredditsEmpty(t *testing.T) {
+	got, err := readSubreddits(strings.NewReader("\n \n"))
+	if err != nil || len(got) != 0 {
+		t.Errorf("readSubreddits = %q, %v, want none", got, err)
+	}
+}
+
+func TestPagePostsPreferOverriddenURL(t *testing.T) {
+	const listing = `{"kind": "Listing", "data": {"children": [
+		{"kind": "t3", "data": {"id": "a", "title": "Crosspost", "url": "https://www.reddit.com/r/pics/comments/a/crosspost/", "url_overridden_by_dest": "https://i.redd.it/real.jpg"}},
+		{"kind": "t3", "data": {"id": "b", "title": "Direct", "url": "https://i.redd.it/direct.png"}}
+	]}}`
+	var page RedditResponse
+	if err := json.Unmarshal([]byte(listing), &page); err != nil {
+		t.Fatal(err)
+	}
+	posts := pagePosts(&page, nil)
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts, want 2", len(posts))
+	}
+	if want := "https://i.redd.it/real.jpg"; posts[0].URL != want {
+		t.Errorf("overridden post URL = %s, want %s", posts[0].URL, want)
+	}
+	if want := "https://i.redd.it/direct.png"; posts[1].URL != want {
+		t.Errorf("plain post URL = %s, want %s", posts[1].URL, want)
+	}
+}
+
+func TestFetchListingPageStreams(t *testing.T) {
+	m := newMockReddit(t)
+	page, err := os.ReadFile(filepath.Join(testdataDir, "r_pics.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The listing is sent whole but the response never ends, so only a
+	// decoder reading as the body streams in gets to the end of it.
+	url := m.handle("/r/streamed/.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(page)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := fetchListingPage(ctx, m.Client(), url, "streamed", "")
+	if err != nil {
+		t.Fatalf("fetchListingPage: %v", err)
+	}
+	if got := len(resp.Data.Children); got != 2 || resp.Data.After != "t3_p2" {
+		t.Errorf("got %d posts after %q, want 2 after t3_p2", got, resp.Data.After)
+	}
+}
+
+// roundTripFunc lets a function stand in for an http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestFetchListingPageErrors(t *testing.T) {
+	errReset := errors.New("connection reset")
+	tests := []struct {
+		name string
+		body io.Reader
+		want string
+	}{
+		{"malformed", strings.NewReader(`{"kind": "Listing", "data": [}`), "error unmarshalling JSON"},
+		{"mismatched", strings.NewReader(`{"kind": "Listing", "data": {"children": "none"}}`), "error unmarshalling JSON"},
+		{"network", io.MultiReader(strings.NewReader(`{"kind": "List`), iotest.ErrReader(errReset)), "error reading response body"},
+	}
+	for _, tt := range tests {
+		client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(tt.body),
+				Request:    req,
+			}, nil
+		})}
+		_, err := fetchListingPage(context.Background(), client, "https://www.reddit.com/r/x/.json", "x", "")
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCheckOutputDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "new", "dir")
+	if err := checkOutputDir(dir); err != nil {
+		t.Fatalf("checkOutputDir on a new directory: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("the write check left %d files behind", len(entries))
+	}
+}
+
+func TestCheckOutputDirReadOnly(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+	err := checkOutputDir(dir)
+	if err == nil || !strings.Contains(err.Error(), "is not writable") {
+		t.Errorf("checkOutputDir on a read-only directory: %v, want a not writable error", err)
+	}
+}
+
+func TestDownloadFailsFastOnUnusableOutputDir(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json")
+	chdirTemp(t)
+	restoreRunnerGlobals(t)
+	// A file where the download directory should be.
+	if err := os.WriteFile(downloadDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := parseCommand([]string{"download", "--subreddit=pics", "--base-url=" + m.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = newRunner(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "cannot create output directory "+downloadDir) {
+		t.Errorf("newRunner = %v, want an error naming the output directory", err)
+	}
+	if got := m.requestLog(); len(got) != 0 {
+		t.Errorf("fetched %q before checking the output directory", got)
+	}
+}
+
+func TestFetchStopsAtEmptyPageWithCursor(t *testing.T) {
+	// The second page is empty but points back at itself.
+	m := newMockReddit(t, "r_sparse.json", "r_sparse_t3_s1.json")
+
+	for _, limit := range []int{25, 0} {
+		before := len(m.requestLog())
+		posts, err := fetchRedditData(context.Background(), m.Client(), "sparse", limit, nil)
+		if err != nil {
+			t.Fatalf("limit %d: %v", limit, err)
+		}
+		if len(posts) != 1 || posts[0].ID != "s1" {
+			t.Errorf("limit %d: posts = %+v, want just s1", limit, posts)
+		}
+		if got := len(m.requestLog()) - before; got != 2 {
+			t.Errorf("limit %d: fetched %d pages, want 2", limit, got)
+		}
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"https://i.redd.it/abc.jpg", "https://i.redd.it/abc.jpg"},
+		{"http://i.imgur.com/x.png", "http://i.imgur.com/x.png"},
+		{"https://i.redd.it/abc.jpg?s=1&w=2", "https://i.redd.it/abc.jpg?s=1&w=2"},
+		{"//i.imgur.com/x.jpg", "https://i.imgur.com/x.jpg"},
+		{"i.imgur.com/x.jpg", "https://i.imgur.com/x.jpg"},
+		{"  //i.redd.it/abc.png\n", "https://i.redd.it/abc.png"},
+		{"/r/pics/comments/abc/title/", "https://www.reddit.com/r/pics/comments/abc/title/"},
+	}
+	for _, tt := range tests {
+		if got, err := normalizeURL(tt.raw); err != nil || got != tt.want {
+			t.Errorf("normalizeURL(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "   ", "ftp://example.com/x.jpg", "javascript:alert(1)", "notaurl", "http://[::1"} {
+		if got, err := normalizeURL(bad); err == nil {
+			t.Errorf("normalizeURL(%q) = %q, want an error", bad, got)
+		}
+	}
+}
+
+func TestFetchRedditPagesBackward(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+
+	posts, cursors, err := fetchRedditPages(context.Background(), m.Client(), "pics", 1, nil, backward, "t3_p2")
+	if err != nil {
+		t.Fatalf("fetchRedditPages: %v", err)
+	}
+	if len(posts) != 1 || posts[0].ID != "p3" {
+		t.Errorf("posts = %+v, want p3", posts)
+	}
+	if want := (listingCursors{Before: "t3_p3"}); cursors != want {
+		t.Errorf("cursors = %+v, want %+v", cursors, want)
+	}
+	want := []string{"/r/pics/.json?before=t3_p2&limit=1"}
+	if got := m.requestLog(); !slices.Equal(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+
+	// Forward from the start returns the cursors of the last page.
+	_, cursors, err = fetchRedditPages(context.Background(), m.Client(), "pics", 2, nil, forward, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (listingCursors{After: "t3_p2"}); cursors != want {
+		t.Errorf("forward cursors = %+v, want %+v", cursors, want)
+	}
+}
+
+func TestDedupeByURL(t *testing.T) {
+	posts := []Post{
+		{ID: "a", URL: "https://i.redd.it/one.jpg"},
+		{ID: "b", URL: "https://i.redd.it/two.jpg"},
+		{ID: "c", URL: "https://i.redd.it/one.jpg"},
+		// The same URL written protocol-relative, as crossposts can be.
+		{ID: "d", URL: "//i.redd.it/two.jpg"},
+		{ID: "e", URL: "https://i.redd.it/three.jpg"},
+		{ID: "f", URL: "https://i.redd.it/one.jpg"},
+	}
+	got := postIDs(dedupeByURL(posts))
+	if want := []string{"a", "b", "e"}; !slices.Equal(got, want) {
+		t.Errorf("dedupeByURL kept %v, want %v", got, want)
+	}
+	if got := dedupeByURL(nil); len(got) != 0 {
+		t.Errorf("dedupeByURL(nil) = %v", got)
+	}
+}
+
+func TestFetchListingPageDetectsInterstitial(t *testing.T) {
+	const listing = `{"kind": "Listing", "data": {"children": []}}`
+	tests := []struct {
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{"text/html; charset=utf-8", "<!doctype html><title>over18?</title>", true},
+		{"text/plain", "Are you over 18?", true},
+		{"application/json; charset=UTF-8", listing, false},
+		// Some proxies drop the header; the body still decides.
+		{"", listing, false},
+	}
+	for _, tt := range tests {
+		client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			h := http.Header{}
+			if tt.contentType != "" {
+				h.Set("Content-Type", tt.contentType)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     h,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+				Request:    req,
+			}, nil
+		})}
+		_, err := fetchListingPage(context.Background(), client, "https://www.reddit.com/r/x/.json", "x", "")
+		if got := errors.Is(err, ErrInterstitial); got != tt.wantErr {
+			t.Errorf("Content-Type %q: err = %v, want interstitial %v", tt.contentType, err, tt.wantErr)
+		}
+		if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "logged-in")) {
+			t.Errorf("Content-Type %q: err = %v, want a hint to log in", tt.contentType, err)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	var posts []Post
+	for i := range 20 {
+		posts = append(posts, Post{ID: string(rune('a' + i))})
+	}
+	for n := 0; n <= len(posts); n++ {
+		got := sample(posts, n, rand.New(rand.NewSource(int64(n))))
+		if len(got) != n {
+			t.Fatalf("sample(20 posts, %d) returned %d", n, len(got))
+		}
+		// The posts kept are distinct and in their original order.
+		for i := 1; i < len(got); i++ {
+			if got[i-1].ID >= got[i].ID {
+				t.Fatalf("sample(20 posts, %d) = %v, want distinct posts in order", n, postIDs(got))
+			}
+		}
+	}
+	if got := sample(posts[:3], 5, rand.New(rand.NewSource(1))); len(got) != 3 {
+		t.Errorf("sampling 5 of 3 posts returned %d, want all 3", len(got))
+	}
+
+	first := postIDs(sample(posts, 5, rand.New(rand.NewSource(42))))
+	again := postIDs(sample(posts, 5, rand.New(rand.NewSource(42))))
+	if !slices.Equal(first, again) {
+		t.Errorf("the same seed sampled %v then %v", first, again)
+	}
+}
+
+func TestFetchInitialSamplesWithSeed(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	var runs [][]string
+	for range 2 {
+		r := newMockRunner(t, m, "show", "--subreddit=pics", "--limit=3", "--sample=2", "--seed=7")
+		posts, err := r.fetchInitial()
+		r.close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 2 {
+			t.Fatalf("--sample=2 kept %d posts", len(posts))
+		}
+		runs = append(runs, postIDs(posts))
+	}
+	if !slices.Equal(runs[0], runs[1]) {
+		t.Errorf("--seed=7 sampled %v then %v", runs[0], runs[1])
+	}
+}
+
+func TestListingURLEscaping(t *testing.T) {
+	tests := []struct {
+		subreddit string
+		query     neturl.Values
+		want      string
+	}{
+		{"pics", neturl.Values{"limit": {"25"}}, "https://www.reddit.com/r/pics/.json?limit=25"},
+		{"earth porn", nil, "https://www.reddit.com/r/earth%20porn/.json"},
+		// A slash or query character in the name stays in its path segment.
+		{"a/b?c#d", nil, "https://www.reddit.com/r/a%2Fb%3Fc%23d/.json"},
+		{"pics", neturl.Values{"q": {"cats & dogs"}, "after": {"t3_x=y"}},
+			"https://www.reddit.com/r/pics/.json?after=t3_x%3Dy&q=cats+%26+dogs"},
+	}
+	for _, tt := range tests {
+		got := listingURL(tt.subreddit, ".json", tt.query)
+		if got != tt.want {
+			t.Errorf("listingURL(%q, %v) = %s, want %s", tt.subreddit, tt.query, got, tt.want)
+			continue
+		}
+		u, err := neturl.Parse(got)
+		if err != nil {
+			t.Fatalf("listingURL(%q) is not a valid URL: %v", tt.subreddit, err)
+		}
+		if want := "/r/" + tt.subreddit + "/.json"; u.Path != want {
+			t.Errorf("listingURL(%q) path decodes to %q, want %q", tt.subreddit, u.Path, want)
+		}
+		for key, values := range tt.query {
+			if got := u.Query()[key]; !slices.Equal(got, values) {
+				t.Errorf("listingURL query %s decodes to %q, want %q", key, got, values)
+			}
+		}
+	}
+}
+
+func TestLimitZeroFetchesEveryPage(t *testing.T) {
+	tests := []struct {
+		args      []string
+		wantIDs   []string
+		wantPages int
+	}{
+		{[]string{"--limit=0"}, []string{"p1", "p2", "p3"}, 2},
+		{[]string{"--limit=-1"}, []string{"p1", "p2", "p3"}, 2},
+		{[]string{"--limit=0", "--max-pages=1"}, []string{"p1", "p2"}, 1},
+		// A positive limit is still cut to size.
+		{[]string{"--limit=1"}, []string{"p1"}, 1},
+	}
+	for _, tt := range tests {
+		m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+		r := newMockRunner(t, m, append([]string{"show", "--subreddit=pics"}, tt.args...)...)
+		posts, err := r.fetchInitial()
+		r.close()
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if got := postIDs(posts); !slices.Equal(got, tt.wantIDs) {
+			t.Errorf("%v: fetched %v, want %v", tt.args, got, tt.wantIDs)
+		}
+		if got := len(m.requestLog()); got != tt.wantPages {
+			t.Errorf("%v: fetched %d pages, want %d", tt.args, got, tt.wantPages)
+		}
+	}
+}
+
+func TestResizeNonRGBASources(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	paletted := image.NewPaletted(image.Rect(0, 0, 800, 600), color.Palette{color.Black, red})
+	for i := range paletted.Pix {
+		paletted.Pix[i] = 1
+	}
+	cyan := color.CMYK{C: 0xff}
+	cmyk := image.NewCMYK(image.Rect(0, 0, 800, 400))
+	for y := range 400 {
+		for x := range 800 {
+			cmyk.Set(x, y, cyan)
+		}
+	}
+	// A frame whose bounds do not start at the origin, as GIF frames may.
+	offset := image.NewPaletted(image.Rect(100, 50, 900, 250), color.Palette{red})
+
+	tests := []struct {
+		name  string
+		img   image.Image
+		want  image.Point
+		color color.Color
+	}{
+		{"paletted", paletted, image.Pt(cardWidth, 300), red},
+		{"cmyk", cmyk, image.Pt(cardWidth, 200), cyan},
+		{"offset paletted", offset, image.Pt(cardWidth, 100), red},
+	}
+	for _, tt := range tests {
+		got := resizeImage(tt.img, cardWidth)
+		if size := got.Bounds().Size(); size != tt.want {
+			t.Errorf("%s: resized to %v, want %v", tt.name, size, tt.want)
+			continue
+		}
+		c := got.At(got.Bounds().Dx()/2, got.Bounds().Dy()/2)
+		if !colorsClose(c, tt.color) {
+			t.Errorf("%s: centre pixel %v, want %v", tt.name, c, tt.color)
+		}
+	}
+}
+
+func TestScalableSource(t *testing.T) {
+	for _, img := range []image.Image{
+		image.NewRGBA(image.Rect(0, 0, 2, 2)),
+		image.NewNRGBA(image.Rect(0, 0, 2, 2)),
+		image.NewYCbCr(image.Rect(0, 0, 2, 2), image.YCbCrSubsampleRatio420),
+		image.NewGray(image.Rect(0, 0, 2, 2)),
+	} {
+		if scalableSource(img) != img {
+			t.Errorf("%T was converted", img)
+		}
+	}
+	src := scalableSource(image.NewCMYK(image.Rect(3, 3, 5, 6)))
+	if _, ok := src.(*image.RGBA); !ok || src.Bounds() != image.Rect(0, 0, 2, 3) {
+		t.Errorf("CMYK source became %T with bounds %v, want 2x3 RGBA", src, src.Bounds())
+	}
+}
+
+// colorsClose reports whether a and b differ by at most 2 in each 8-bit
+// channel.
+func colorsClose(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	near := func(x, y uint32) bool {
+		d := int(x>>8) - int(y>>8)
+		return d >= -2 && d <= 2
+	}
+	return near(ar, br) && near(ag, bg) && near(ab, bb) && near(aa, ba)
+}
+
+func TestParseBaseURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "https://www.reddit.com", want: "https://www.reddit.com"},
+		{raw: "https://old.reddit.com/", want: "https://old.reddit.com"},
+		{raw: "http://localhost:8080/teddit", want: "http://localhost:8080/teddit"},
+		{raw: "old.reddit.com", wantErr: true},
+		{raw: "ftp://reddit.com", wantErr: true},
+		{raw: "https://", wantErr: true},
+		{raw: "https://reddit.com?x=1", wantErr: true},
+		{raw: "https://reddit.com#top", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBaseURL(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseBaseURL(%q) = %s, want an error", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("parseBaseURL(%q) = %v, %v; want %s", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestListingURLUnderCustomBase(t *testing.T) {
+	old := redditBaseURL
+	t.Cleanup(func() { redditBaseURL = old })
+
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"https://old.reddit.com", "https://old.reddit.com/r/pics/.json?limit=5"},
+		{"http://localhost:8080/teddit/", "http://localhost:8080/teddit/r/pics/.json?limit=5"},
+		{"https://proxy.example/a%20b", "https://proxy.example/a%20b/r/pics/.json?limit=5"},
+	}
+	for _, tt := range tests {
+		base, err := parseBaseURL(tt.base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		redditBaseURL = base
+		if got := listingURL("pics", ".json", neturl.Values{"limit": {"5"}}); got != tt.want {
+			t.Errorf("base %s: listingURL = %s, want %s", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestBaseURLFlag(t *testing.T) {
+	chdirTemp(t)
+	restoreRunnerGlobals(t)
+	for _, arg := range []string{"--base-url=old.reddit.com", "--base-url=https://old.reddit.com?x=1"} {
+		cfg, err := parseCommand([]string{"download", "--subreddit=pics", arg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newRunner(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "invalid --base-url") {
+			t.Errorf("%s: newRunner = %v, want an invalid --base-url error", arg, err)
+		}
+	}
+
+	m := newMockReddit(t, "r_pics.json")
+	r := newMockRunner(t, m, "show", "--subreddit=pics", "--limit=1")
+	if redditBaseURL.String() != m.URL {
+		t.Errorf("redditBaseURL = %s, want %s", redditBaseURL, m.URL)
+	}
+	if _, err := r.fetchAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.requestLog(); len(got) == 0 || !strings.HasPrefix(got[0], "/r/pics/.json") {
+		t.Errorf("requests = %q, want the listing fetched from the mock base", got)
+	}
+}
+
+func TestSaveJSONListingWritesBodiesVerbatim(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json", "r_nsfw.html", "r_private.403.json")
+	restoreRunnerGlobals(t)
+	listingDumpDir = t.TempDir()
+
+	if _, err := fetchRedditData(context.Background(), m.Client(), "pics", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"r_pics.json", "r_pics_t3_p2.json"} {
+		got, err := os.ReadFile(filepath.Join(listingDumpDir, name))
+		if err != nil {
+			t.Fatalf("listing not saved: %v", err)
+		}
+		if string(got) != string(m.fixtures[name]) {
+			t.Errorf("%s differs from the body served:\n%s", name, got)
+		}
+	}
+
+	// Interstitials and error pages are not listings, so are not saved.
+	for _, sub := range []string{"nsfw", "private"} {
+		if _, err := fetchRedditData(context.Background(), m.Client(), sub, 0, nil); err == nil {
+			t.Errorf("r/%s fetched without an error", sub)
+		}
+	}
+	entries, err := os.ReadDir(listingDumpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("saved %v, want only the two pics pages", names)
+	}
+	assertNoTempFiles(t, listingDumpDir)
+}
+
+func TestNormalizeURLUnderCustomBase(t *testing.T) {
+	old := redditBaseURL
+	t.Cleanup(func() { redditBaseURL = old })
+
+	tests := []struct {
+		base, raw, want string
+	}{
+		{"https://old.reddit.com", "/r/pics/comments/abc/title/", "https://old.reddit.com/r/pics/comments/abc/title/"},
+		{"http://localhost:8080/teddit", "/gallery/abc", "http://localhost:8080/teddit/gallery/abc"},
+		// Other URLs do not depend on the base.
+		{"https://old.reddit.com", "//i.imgur.com/x.jpg", "https://i.imgur.com/x.jpg"},
+		{"https://old.reddit.com", "https://www.reddit.com/gallery/abc", "https://www.reddit.com/gallery/abc"},
+	}
+	for _, tt := range tests {
+		base, err := parseBaseURL(tt.base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		redditBaseURL = base
+		if got, err := normalizeURL(tt.raw); err != nil || got != tt.want {
+			t.Errorf("base %s: normalizeURL(%q) = %q, %v; want %q", tt.base, tt.raw, got, err, tt.want)
+		}
+	}
+}
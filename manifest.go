@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// manifestFileName is the name of the manifest inside the download directory.
+const manifestFileName = "manifest.json"
+
+// manifestEntry records a single saved image.
+type manifestEntry struct {
+	// URL is the final image URL after redirects.
+	URL string `json:"url"`
+	// Source is the URL as given by the post, before redirects.
+	Source string `json:"source,omitempty"`
+	File   string `json:"file"`
+	Title  string `json:"title"`
+	// Permalink is the post's path on Reddit, for the --save-index links.
+	Permalink string    `json:"permalink,omitempty"`
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+// manifest is the on-disk record of images saved to the download directory,
+// keyed by image URL. Every change is written atomically so that a crash
+// leaves either the previous or the new manifest, never a partial one.
+type manifest struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]manifestEntry
+}
+
+// newManifest returns an empty manifest persisted at path. Call Load to read
+// any existing entries.
+func newManifest(path string) *manifest {
+	return &manifest{path: path, entries: make(map[string]manifestEntry)}
+}
+
+// Load reads the manifest from disk. A missing file is not an error and
+// leaves the manifest empty.
+func (m *manifest) Load() error {
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var entries []manifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = make(map[string]manifestEntry, len(entries))
+	for _, e := range entries {
+		m.entries[e.URL] = e
+	}
+	return nil
+}
+
+// Has reports whether url has been recorded, either as the final image URL
+// or as the post URL it was reached from.
+func (m *manifest) Has(url string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[url]; ok {
+		return true
+	}
+	for _, e := range m.entries {
+		if e.Source == url {
+			return true
+		}
+	}
+	return false
+}
+
+// postSaved reports whether every image of post is already in m. Posts
+// without any image URL are never considered saved.
+func postSaved(m *manifest, post Post) bool {
+	if images := post.galleryImages(); images != nil {
+		for _, img := range images {
+			if !m.Has(img.URL) {
+				return false
+			}
+		}
+		return len(images) > 0
+	}
+	return post.URL != "" && m.Has(post.URL)
+}
+
+// Entries returns every recorded entry, oldest first.
+func (m *manifest) Entries() []manifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]manifestEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sortManifestEntries(entries)
+	return entries
+}
+
+// Record adds or replaces the entry for e.URL and persists the manifest. It
+// should only be called once the image file has been fully written.
+func (m *manifest) Record(e manifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[e.URL] = e
+	return m.saveLocked()
+}
+
+// Flush writes the manifest out. Record already writes every change, so
+// this only matters to make sure the file is current at exit.
+func (m *manifest) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.saveLocked()
+}
+
+// Reconcile drops entries whose file no longer exists in dir, e.g. because
+// a previous run crashed before the file was written or it was deleted by
+// hand. It returns the number of entries removed.
+func (m *manifest) Reconcile(dir string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := 0
+	for url, e := range m.entries {
+		if _, err := os.Stat(filepath.Join(dir, e.File)); errors.Is(err, fs.ErrNotExist) {
+			delete(m.entries, url)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, m.saveLocked()
+}
+
+func (m *manifest) saveLocked() error {
+	entries := make([]manifestEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sortManifestEntries(entries)
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeFileAtomic(m.path, data); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// sortManifestEntries orders entries by save time, then URL, so the file is
+// stable across writes.
+func sortManifestEntries(entries []manifestEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].SavedAt.Equal(entries[j].SavedAt) {
+			return entries[i].SavedAt.Before(entries[j].SavedAt)
+		}
+		return entries[i].URL < entries[j].URL
+	})
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManifestRecordPersists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, manifestFileName)
+	m := newManifest(path)
+	if err := m.Record(manifestEntry{URL: "https://i.redd.it/a.jpg", Source: "https://imgur.com/a", File: "a.jpg", SavedAt: time.Now()}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	reloaded := newManifest(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, url := range []string{"https://i.redd.it/a.jpg", "https://imgur.com/a"} {
+		if !reloaded.Has(url) {
+			t.Errorf("reloaded manifest lacks %s", url)
+		}
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestManifestIgnoresInterruptedWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, manifestFileName)
+	m := newManifest(path)
+	if err := m.Record(manifestEntry{URL: "https://i.redd.it/a.jpg", File: "a.jpg"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	// A crash mid-write leaves a partial temporary file, never a partial
+	// manifest.
+	if err := os.WriteFile(path+".tmp-123", []byte(`[{"url": "https://i.redd.it/b.j`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newManifest(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reloaded.Has("https://i.redd.it/a.jpg") || reloaded.Has("https://i.redd.it/b.jpg") {
+		t.Error("manifest does not hold exactly the entries written before the crash")
+	}
+}
+
+func TestManifestReconcileDropsMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, manifestFileName)
+	if err := os.WriteFile(filepath.Join(dir, "kept.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := newManifest(path)
+	m.Record(manifestEntry{URL: "https://i.redd.it/kept.jpg", File: "kept.jpg"})
+	m.Record(manifestEntry{URL: "https://i.redd.it/lost.jpg", File: "lost.jpg"})
+
+	removed, err := m.Reconcile(dir)
+	if err != nil || removed != 1 {
+		t.Fatalf("Reconcile = %d, %v; want 1 removed", removed, err)
+	}
+
+	reloaded := newManifest(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reloaded.Has("https://i.redd.it/kept.jpg") {
+		t.Error("entry with its file present was dropped")
+	}
+	if reloaded.Has("https://i.redd.it/lost.jpg") {
+		t.Error("entry with its file missing survived a reload")
+	}
+}
+
+func TestManifestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := newManifest(filepath.Join(dir, "missing.json")).Load(); err != nil {
+		t.Errorf("Load of a missing manifest: %v", err)
+	}
+
+	corrupt := filepath.Join(dir, manifestFileName)
+	os.WriteFile(corrupt, []byte("{not json"), 0644)
+	if err := newManifest(corrupt).Load(); err == nil {
+		t.Error("Load of a corrupt manifest succeeded")
+	}
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp-*"))
+	if len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+// TestManifestConcurrentAccess hammers one manifest from many goroutines,
+// as concurrent downloads do. Run it with -race to check the locking; it
+// also checks a reader of the file never sees a partial write.
+func TestManifestConcurrentAccess(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, manifestFileName)
+	m := newManifest(path)
+
+	const writers, perWriter = 8, 10
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				url := fmt.Sprintf("https://i.redd.it/%d-%d.png", w, i)
+				if err := m.Record(manifestEntry{URL: url, File: fmt.Sprintf("%d-%d.png", w, i), SavedAt: time.Now()}); err != nil {
+					t.Errorf("Record: %v", err)
+					return
+				}
+				if !m.Has(url) {
+					t.Errorf("Has(%s) is false right after Record", url)
+				}
+				m.Has("https://i.redd.it/never.png")
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if err := m.Flush(); err != nil {
+					t.Errorf("Flush: %v", err)
+				}
+				data, err := os.ReadFile(path)
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				var entries []manifestEntry
+				if err == nil {
+					err = json.Unmarshal(data, &entries)
+				}
+				if err != nil {
+					t.Errorf("reading the manifest mid-run: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	reloaded := newManifest(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := len(reloaded.entries); got != writers*perWriter {
+		t.Errorf("manifest has %d entries, want %d", got, writers*perWriter)
+	}
+	assertNoTempFiles(t, dir)
+}
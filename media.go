@@ -0,0 +1,145 @@
+package main
+
+import "html"
+
+// Media is the media object of a post; for Reddit-hosted videos it carries
+// the reddit_video details.
+type Media struct {
+	RedditVideo *RedditVideo `json:"reddit_video"`
+}
+
+// RedditVideo describes a v.redd.it video.
+type RedditVideo struct {
+	FallbackURL string `json:"fallback_url"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// Preview holds the preview images Reddit generates for a post.
+type Preview struct {
+	Images []PreviewImage `json:"images"`
+}
+
+// PreviewImage is one preview image at its source resolution, with scaled
+// down copies in Resolutions, smallest first. Variants holds other
+// renditions keyed by kind, e.g. "gif" for the animated version of a GIF.
+type PreviewImage struct {
+	Source      PreviewSource             `json:"source"`
+	Resolutions []PreviewSource           `json:"resolutions"`
+	Variants    map[string]PreviewVariant `json:"variants"`
+}
+
+// PreviewVariant is an alternative rendition of a preview image.
+type PreviewVariant struct {
+	Source      PreviewSource   `json:"source"`
+	Resolutions []PreviewSource `json:"resolutions"`
+}
+
+// imageVariants are the variant kinds that are plain images. "mp4" is a
+// video, and "obfuscated" and "nsfw" are deliberately blurred.
+var imageVariants = []string{"gif"}
+
+// PreviewSource is a preview image URL and its dimensions. Reddit escapes
+// the URL for HTML, so use unescapedURL.
+type PreviewSource struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// unescapedURL returns the URL with Reddit's HTML escaping ("&amp;") undone.
+func (s PreviewSource) unescapedURL() string {
+	return html.UnescapeString(s.URL)
+}
+
+// isVideo reports whether the post is a Reddit-hosted video.
+func (p Post) isVideo() bool {
+	return p.IsVideo || (p.Media != nil && p.Media.RedditVideo != nil)
+}
+
+// videoPosterURL returns a still frame for a v.redd.it video post, taken
+// from its preview image, or "" if the post is not a video or has none.
+func (p Post) videoPosterURL() string {
+	if !p.isVideo() || p.Preview == nil || len(p.Preview.Images) == 0 {
+		return ""
+	}
+	return p.Preview.Images[0].Source.unescapedURL()
+}
+
+// mediaURL returns the URL to load the post's image from: the poster frame
+// for videos, otherwise the post's destination.
+func (p Post) mediaURL() string {
+	if poster := p.videoPosterURL(); poster != "" {
+		return poster
+	}
+	return p.destinationURL()
+}
+
+// closestResolution returns the preview resolution whose width is closest
+// to width, preferring the smaller of two equally close ones. ok is false
+// if there are no resolutions.
+func (img PreviewImage) closestResolution(width int) (best PreviewSource, ok bool) {
+	bestDiff := 0
+	for _, r := range img.Resolutions {
+		diff := r.Width - width
+		if diff < 0 {
+			diff = -diff
+		}
+		if !ok || diff < bestDiff || (diff == bestDiff && r.Width < best.Width) {
+			best, bestDiff, ok = r, diff, true
+		}
+	}
+	return best, ok
+}
+
+// previewURL returns the URL of the post's scaled down preview closest to
+// width, for --preview-only, or "" if it has none.
+func (p Post) previewURL(width int) string {
+	if p.Preview == nil || len(p.Preview.Images) == 0 {
+		return ""
+	}
+	r, ok := p.Preview.Images[0].closestResolution(width)
+	if !ok {
+		return ""
+	}
+	return r.unescapedURL()
+}
+
+// largestRendition returns the highest-resolution rendition of the preview
+// image among its source, resolutions and image variants, preferring a
+// variant over the source at equal size so GIFs stay animated. ok is false
+// if there is none.
+func (img PreviewImage) largestRendition() (best PreviewSource, ok bool) {
+	var candidates []PreviewSource
+	for _, kind := range imageVariants {
+		if v, found := img.Variants[kind]; found {
+			candidates = append(candidates, v.Source)
+			candidates = append(candidates, v.Resolutions...)
+		}
+	}
+	candidates = append(candidates, img.Source)
+	candidates = append(candidates, img.Resolutions...)
+
+	for _, c := range candidates {
+		if c.URL == "" {
+			continue
+		}
+		if !ok || c.Width*c.Height > best.Width*best.Height {
+			best, ok = c, true
+		}
+	}
+	return best, ok
+}
+
+// bestQualityURL returns the URL of the post's largest preview rendition,
+// for --best-quality, or "" if it has none.
+func (p Post) bestQualityURL() string {
+	if p.Preview == nil || len(p.Preview.Images) == 0 {
+		return ""
+	}
+	r, ok := p.Preview.Images[0].largestRendition()
+	if !ok {
+		return ""
+	}
+	return r.unescapedURL()
+}
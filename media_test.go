@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// videoPost is a v.redd.it post as the listing API returns it, trimmed to
+// the fields that matter.
+const videoPost = `{
+	"id": "v1",
+	"title": "Timelapse",
+	"url": "https://v.redd.it/abc123",
+	"is_video": true,
+	"media": {"reddit_video": {"fallback_url": "https://v.redd.it/abc123/DASH_720.mp4?source=fallback", "width": 1280, "height": 720}},
+	"preview": {"images": [{
+		"source": {"url": "https://external-preview.redd.it/frame.png?format=pjpg&amp;auto=webp&amp;s=abc", "width": 1280, "height": 720},
+		"resolutions": [{"url": "https://external-preview.redd.it/frame.png?width=320&amp;s=def", "width": 320, "height": 180}]
+	}]}
+}`
+
+func TestVideoPosterURL(t *testing.T) {
+	var post Post
+	if err := json.Unmarshal([]byte(videoPost), &post); err != nil {
+		t.Fatal(err)
+	}
+	if post.Media == nil || post.Media.RedditVideo == nil || post.Media.RedditVideo.Width != 1280 {
+		t.Fatalf("media = %+v, want the reddit_video details", post.Media)
+	}
+	want := "https://external-preview.redd.it/frame.png?format=pjpg&auto=webp&s=abc"
+	if got := post.videoPosterURL(); got != want {
+		t.Errorf("videoPosterURL = %s, want %s", got, want)
+	}
+	if got := post.mediaURL(); got != want {
+		t.Errorf("mediaURL = %s, want the poster %s", got, want)
+	}
+
+	// Crossposted videos may only carry media.
+	post.IsVideo = false
+	if !post.isVideo() {
+		t.Error("a post with reddit_video media is not a video")
+	}
+	post.Media = nil
+	if got := post.mediaURL(); got != post.URL {
+		t.Errorf("mediaURL of a non-video = %s, want its URL", got)
+	}
+
+	noPreview := Post{URL: "https://v.redd.it/abc123", IsVideo: true}
+	if got := noPreview.videoPosterURL(); got != "" {
+		t.Errorf("videoPosterURL without a preview = %q, want none", got)
+	}
+}
+
+func TestPreviewURLClosestToWidth(t *testing.T) {
+	post := Post{Preview: &Preview{Images: []PreviewImage{{
+		Source: PreviewSource{URL: "https://preview.redd.it/x.jpg?width=4000", Width: 4000},
+		Resolutions: []PreviewSource{
+			{URL: "https://preview.redd.it/x.jpg?width=108&amp;s=a", Width: 108},
+			{URL: "https://preview.redd.it/x.jpg?width=216&amp;s=b", Width: 216},
+			{URL: "https://preview.redd.it/x.jpg?width=320&amp;s=c", Width: 320},
+			{URL: "https://preview.redd.it/x.jpg?width=640&amp;s=d", Width: 640},
+			{URL: "https://preview.redd.it/x.jpg?width=960&amp;s=e", Width: 960},
+		},
+	}}}}
+	tests := []struct {
+		width int
+		want  string
+	}{
+		{400, "https://preview.redd.it/x.jpg?width=320&s=c"},
+		{500, "https://preview.redd.it/x.jpg?width=640&s=d"},
+		// Equally close to 320 and 640: the smaller wins.
+		{480, "https://preview.redd.it/x.jpg?width=320&s=c"},
+		{10, "https://preview.redd.it/x.jpg?width=108&s=a"},
+		// Never the full source, however wide the target.
+		{5000, "https://preview.redd.it/x.jpg?width=960&s=e"},
+	}
+	for _, tt := range tests {
+		if got := post.previewURL(tt.width); got != tt.want {
+			t.Errorf("previewURL(%d) = %s, want %s", tt.width, got, tt.want)
+		}
+	}
+
+	for _, p := range []Post{{}, {Preview: &Preview{}}, {Preview: &Preview{Images: []PreviewImage{{Source: PreviewSource{URL: "https://preview.redd.it/x.jpg"}}}}}} {
+		if got := p.previewURL(400); got != "" {
+			t.Errorf("previewURL without resolutions = %q, want none", got)
+		}
+	}
+}
+
+// variantsPost carries a GIF variant as large as its source, a larger
+// mp4 variant and a larger blurred one, neither of which is a plain image.
+const variantsPost = `{
+	"id": "g1",
+	"title": "Loop",
+	"url": "https://i.redd.it/loop.gif",
+	"preview": {"images": [{
+		"source": {"url": "https://preview.redd.it/loop.gif?format=png&amp;s=src", "width": 800, "height": 600},
+		"resolutions": [{"url": "https://preview.redd.it/loop.gif?width=320&amp;s=r1", "width": 320, "height": 240}],
+		"variants": {
+			"gif": {
+				"source": {"url": "https://preview.redd.it/loop.gif?s=gif", "width": 800, "height": 600},
+				"resolutions": [{"url": "https://preview.redd.it/loop.gif?width=320&amp;s=g1", "width": 320, "height": 240}]
+			},
+			"mp4": {"source": {"url": "https://preview.redd.it/loop.gif?format=mp4&amp;s=mp4", "width": 1600, "height": 1200}},
+			"nsfw": {"source": {"url": "https://preview.redd.it/loop.gif?blur=40&amp;s=nsfw", "width": 1600, "height": 1200}}
+		}
+	}]}
+}`
+
+func TestBestQualityURL(t *testing.T) {
+	var post Post
+	if err := json.Unmarshal([]byte(variantsPost), &post); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(post.Preview.Images[0].Variants); got != 3 {
+		t.Fatalf("parsed %d variants, want 3", got)
+	}
+	// The GIF variant ties with the source and wins, so the image stays
+	// animated; mp4 and nsfw are never picked however large.
+	want := "https://preview.redd.it/loop.gif?s=gif"
+	if got := post.bestQualityURL(); got != want {
+		t.Errorf("bestQualityURL = %s, want %s", got, want)
+	}
+
+	largest := Post{Preview: &Preview{Images: []PreviewImage{{
+		Source: PreviewSource{URL: "https://preview.redd.it/x.jpg?s=src", Width: 640, Height: 480},
+		Resolutions: []PreviewSource{
+			{URL: "https://preview.redd.it/x.jpg?width=1080&amp;s=big", Width: 1080, Height: 810},
+			{URL: "https://preview.redd.it/x.jpg?width=320&amp;s=small", Width: 320, Height: 240},
+		},
+	}}}}
+	if got, want := largest.bestQualityURL(), "https://preview.redd.it/x.jpg?width=1080&s=big"; got != want {
+		t.Errorf("bestQualityURL = %s, want the largest rendition %s", got, want)
+	}
+
+	if got := (Post{URL: "https://i.redd.it/x.jpg"}).bestQualityURL(); got != "" {
+		t.Errorf("bestQualityURL without a preview = %q, want none", got)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Media types --include-types and --exclude-types select between.
+const (
+	mediaGIF    = "gif"
+	mediaStatic = "static"
+	mediaVideo  = "video"
+)
+
+// mediaType classifies a decoded image: the poster frame of a Reddit video,
+// a GIF, or any other still image. format is the decoder's format name.
+func mediaType(post Post, format string) string {
+	switch {
+	case post.isVideo():
+		return mediaVideo
+	case format == "gif":
+		return mediaGIF
+	}
+	return mediaStatic
+}
+
+// mediaTypeFilter decides which media types are shown and saved. An empty
+// include set allows every type not excluded.
+type mediaTypeFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// newMediaTypeFilter parses the comma-separated --include-types and
+// --exclude-types values.
+func newMediaTypeFilter(include, exclude string) (mediaTypeFilter, error) {
+	var f mediaTypeFilter
+	var err error
+	if f.include, err = parseMediaTypes(include); err != nil {
+		return mediaTypeFilter{}, fmt.Errorf("invalid --include-types: %w", err)
+	}
+	if f.exclude, err = parseMediaTypes(exclude); err != nil {
+		return mediaTypeFilter{}, fmt.Errorf("invalid --exclude-types: %w", err)
+	}
+	return f, nil
+}
+
+func parseMediaTypes(s string) (map[string]bool, error) {
+	types := make(map[string]bool)
+	for _, t := range strings.Split(s, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		switch t {
+		case "":
+			continue
+		case mediaGIF, mediaStatic, mediaVideo:
+			types[t] = true
+		default:
+			return nil, fmt.Errorf("unknown media type %q (want gif, static or video)", t)
+		}
+	}
+	return types, nil
+}
+
+// allows reports whether images of media type t pass the filter.
+func (f mediaTypeFilter) allows(t string) bool {
+	if f.exclude[t] {
+		return false
+	}
+	return len(f.include) == 0 || f.include[t]
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestMediaType(t *testing.T) {
+	tests := []struct {
+		post   Post
+		format string
+		want   string
+	}{
+		{post: Post{}, format: "gif", want: mediaGIF},
+		{post: Post{}, format: "jpeg", want: mediaStatic},
+		{post: Post{}, format: "png", want: mediaStatic},
+		{post: Post{IsVideo: true}, format: "jpeg", want: mediaVideo},
+	}
+	for _, tt := range tests {
+		if got := mediaType(tt.post, tt.format); got != tt.want {
+			t.Errorf("mediaType(video=%v, %q) = %q, want %q", tt.post.IsVideo, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestMediaTypeFilterAllows(t *testing.T) {
+	tests := []struct {
+		include, exclude string
+		want             map[string]bool
+	}{
+		{want: map[string]bool{mediaGIF: true, mediaStatic: true, mediaVideo: true}},
+		{include: "gif", want: map[string]bool{mediaGIF: true}},
+		{include: "static, VIDEO", want: map[string]bool{mediaStatic: true, mediaVideo: true}},
+		{exclude: "gif", want: map[string]bool{mediaStatic: true, mediaVideo: true}},
+		{include: "gif,static", exclude: "gif", want: map[string]bool{mediaStatic: true}},
+	}
+	for _, tt := range tests {
+		f, err := newMediaTypeFilter(tt.include, tt.exclude)
+		if err != nil {
+			t.Fatalf("newMediaTypeFilter(%q, %q): %v", tt.include, tt.exclude, err)
+		}
+		for _, typ := range []string{mediaGIF, mediaStatic, mediaVideo} {
+			if got := f.allows(typ); got != tt.want[typ] {
+				t.Errorf("include %q exclude %q: allows(%q) = %v, want %v",
+					tt.include, tt.exclude, typ, got, tt.want[typ])
+			}
+		}
+	}
+}
+
+func TestNewMediaTypeFilterRejectsUnknownType(t *testing.T) {
+	if _, err := newMediaTypeFilter("gif,webm", ""); err == nil {
+		t.Error("unknown --include-types value accepted")
+	}
+	if _, err := newMediaTypeFilter("", "stills"); err == nil {
+		t.Error("unknown --exclude-types value accepted")
+	}
+}
+
+func TestMediaTypeFilterKeepsMatchingImages(t *testing.T) {
+	tests := []struct {
+		flag        string
+		wantFormats map[string]int
+	}{
+		{flag: "--include-types=gif", wantFormats: map[string]int{"gif": 1}},
+		{flag: "--include-types=static", wantFormats: map[string]int{"png": 2, "jpeg": 1}},
+		{flag: "--exclude-types=gif", wantFormats: map[string]int{"png": 2, "jpeg": 1}},
+		{flag: "--exclude-types=static", wantFormats: map[string]int{"gif": 1}},
+	}
+	for _, tt := range tests {
+		m := newMockReddit(t, "r_mixed.json")
+		addMixedImages(t, m)
+
+		r := newMockRunner(t, m, "show", "--subreddit=mixed", tt.flag)
+		posts, err := r.fetchInitial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess := r.newSession(nil)
+		r.process(sess, posts)
+		r.close()
+
+		if !maps.Equal(sess.stats.formats, tt.wantFormats) {
+			t.Errorf("%s: kept formats %v, want %v", tt.flag, sess.stats.formats, tt.wantFormats)
+		}
+		wantFiltered := 4
+		for _, n := range tt.wantFormats {
+			wantFiltered -= n
+		}
+		if got := sess.stats.failures["filtered"]; got != wantFiltered {
+			t.Errorf("%s: filtered %d images, want %d", tt.flag, got, wantFiltered)
+		}
+	}
+}
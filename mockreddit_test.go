@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testdataDir is the absolute path of testdata/reddit, so fixtures can be
+// read after a test has moved to another directory.
+var testdataDir string
+
+// TestMain keeps the run's log lines out of the test output unless -v is
+// given.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	dir, err := filepath.Abs(filepath.Join("testdata", "reddit"))
+	if err != nil {
+		panic(err)
+	}
+	testdataDir = dir
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// mockReddit is an httptest server standing in for Reddit and the image
+// hosts, so fetching can be tested without the network. Listings are
+// served from fixture files in testdata/reddit, named after the request
+// they answer:
+//
+//	r_<sub>.json          the first page of r/<sub>
+//	r_<sub>_<cursor>.json the page after (or before) the cursor <cursor>
+//	r_<sub>.<code>.json   an error response with status <code>
+//	r_<sub>.html          an HTML interstitial, such as the age gate
+//	r_<sub>.rss           the Atom feed of r/<sub>
+//
+// These are the names --save-json-listing writes, so a saved run can be
+// dropped into testdata as it is. "$MOCK" in a fixture is replaced by the
+// server's URL, so image URLs in listings point back at it; the images
+// themselves are served under /img/ once added with addImage.
+type mockReddit struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fixtures map[string][]byte
+	images   map[string][]byte
+	handlers map[string]http.HandlerFunc
+	requests []string
+}
+
+// newMockReddit starts a mock Reddit serving the named fixtures from
+// testdata/reddit and points redditBaseURL at it until the test ends.
+func newMockReddit(t *testing.T, fixtures ...string) *mockReddit {
+	t.Helper()
+	m := &mockReddit{fixtures: make(map[string][]byte), images: make(map[string][]byte), handlers: make(map[string]http.HandlerFunc)}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+
+	for _, name := range fixtures {
+		data, err := os.ReadFile(filepath.Join(testdataDir, name))
+		if err != nil {
+			t.Fatalf("reading fixture: %v", err)
+		}
+		m.fixtures[name] = bytes.ReplaceAll(data, []byte("$MOCK"), []byte(m.URL))
+	}
+
+	base, err := parseBaseURL(m.URL)
+	if err != nil {
+		t.Fatalf("parsing mock URL: %v", err)
+	}
+	old := redditBaseURL
+	redditBaseURL = base
+	t.Cleanup(func() { redditBaseURL = old })
+	return m
+}
+
+// addImage serves data as /img/<name> and returns its URL.
+func (m *mockReddit) addImage(name string, data []byte) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.images[name] = data
+	return m.URL + "/img/" + name
+}
+
+// handle serves requests for path with h instead of the fixtures, for
+// responses no fixture can describe, and returns the path's URL.
+func (m *mockReddit) handle(path string, h http.HandlerFunc) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[path] = h
+	return m.URL + path
+}
+
+// requestLog returns the request URIs served so far, in order.
+func (m *mockReddit) requestLog() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.requests...)
+}
+
+func (m *mockReddit) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.requests = append(m.requests, r.URL.RequestURI())
+	h := m.handlers[r.URL.Path]
+	m.mu.Unlock()
+	if h != nil {
+		// Unlocked, so a handler can block without stalling the others.
+		h(w, r)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if name, ok := strings.CutPrefix(r.URL.Path, "/img/"); ok {
+		data, ok := m.images[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+		w.Write(data)
+		return
+	}
+
+	sub, ok := strings.CutPrefix(r.URL.Path, "/r/")
+	if feed, isFeed := strings.CutSuffix(sub, "/.rss"); ok && isFeed {
+		if data, ok := m.fixtures["r_"+feed+".rss"]; ok {
+			w.Header().Set("Content-Type", "application/atom+xml; charset=UTF-8")
+			w.Write(data)
+			return
+		}
+	}
+	sub, isListing := strings.CutSuffix(sub, "/.json")
+	if !ok || !isListing {
+		http.NotFound(w, r)
+		return
+	}
+	name := "r_" + sub
+	for _, param := range []string{"after", "before"} {
+		if cursor := r.URL.Query().Get(param); cursor != "" {
+			name += "_" + cursor
+		}
+	}
+	if data, ok := m.fixtures[name+".json"]; ok {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Write(data)
+		return
+	}
+	if data, ok := m.fixtures["r_"+sub+".html"]; ok {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(data)
+		return
+	}
+	for fixture, data := range m.fixtures {
+		code, ok := strings.CutPrefix(fixture, "r_"+sub+".")
+		code, ok2 := strings.CutSuffix(code, ".json")
+		status, err := strconv.Atoi(code)
+		if ok && ok2 && err == nil {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(status)
+			w.Write(data)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(`{"message": "Not Found", "error": 404}`))
+}
+
+// fixturePosts returns the posts of the listing fixture name, parsed as a
+// fetched page would be, with image URLs on https://mock.invalid.
+func fixturePosts(t *testing.T, name string) []Post {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(testdataDir, name))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	data = bytes.ReplaceAll(data, []byte("$MOCK"), []byte("https://mock.invalid"))
+	var page RedditResponse
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatalf("parsing fixture: %v", err)
+	}
+	return pagePosts(&page, nil)
+}
+
+// newMockRunner parses args as a command line and builds its runner
+// against m. The download directory is relative, so tests that save
+// should chdirTemp first. The package settings newRunner changes are
+// restored when the test ends. Call close on the runner to finish the run.
+func newMockRunner(t *testing.T, m *mockReddit, args ...string) *runner {
+	t.Helper()
+	return newMockRunnerContext(t, context.Background(), m, args...)
+}
+
+// newMockRunnerContext is newMockRunner for a run bounded by ctx.
+func newMockRunnerContext(t *testing.T, ctx context.Context, m *mockReddit, args ...string) *runner {
+	t.Helper()
+	restoreRunnerGlobals(t)
+
+	cfg, err := parseCommand(append(args, "--base-url="+m.URL))
+	if err != nil {
+		t.Fatalf("parseCommand: %v", err)
+	}
+	r, err := newRunner(ctx, cfg)
+	if err != nil {
+		t.Fatalf("newRunner: %v", err)
+	}
+	return r
+}
+
+// restoreRunnerGlobals puts back the package settings newRunner sets from
+// flags once the test ends.
+func restoreRunnerGlobals(t *testing.T) {
+	titleTag, statuses, retries, pages, base, dump := titleTagRe, retryableStatuses, listingRetries, maxListingPages, redditBaseURL, listingDumpDir
+	t.Cleanup(func() {
+		titleTagRe, retryableStatuses, listingRetries, maxListingPages, redditBaseURL, listingDumpDir = titleTag, statuses, retries, pages, base, dump
+	})
+}
+
+// chdirTemp moves the test to a new temporary directory until it ends.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+	return dir
+}
+
+// pngBytes returns a w by h PNG filled with c.
+func pngBytes(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestMockRedditPaginates(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+
+	posts, err := fetchRedditData(context.Background(), m.Client(), "pics", 0, nil)
+	if err != nil {
+		t.Fatalf("fetchRedditData: %v", err)
+	}
+	var ids []string
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	if got := strings.Join(ids, ","); got != "p1,p2,p3" {
+		t.Errorf("post ids = %s, want p1,p2,p3", got)
+	}
+	if want := m.URL + "/img/cat.png"; posts[0].URL != want {
+		t.Errorf("first URL = %s, want %s", posts[0].URL, want)
+	}
+
+	want := []string{"/r/pics/.json?limit=100", "/r/pics/.json?after=t3_p2&limit=100"}
+	got := m.requestLog()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestMockRedditServesGalleries(t *testing.T) {
+	m := newMockReddit(t, "r_gallery.json")
+
+	posts, err := fetchRedditData(context.Background(), m.Client(), "gallery", 0, nil)
+	if err != nil {
+		t.Fatalf("fetchRedditData: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts, want 2", len(posts))
+	}
+	images := posts[0].galleryImages()
+	if len(images) != 3 {
+		t.Fatalf("got %d gallery images, want 3 (the failed one dropped)", len(images))
+	}
+	if want := m.URL + "/img/m1.png"; images[0].URL != want {
+		t.Errorf("first gallery image = %s, want %s", images[0].URL, want)
+	}
+	if !posts[1].Spoiler {
+		t.Error("spoiler post not marked as a spoiler")
+	}
+}
+
+func TestMockRedditServesErrors(t *testing.T) {
+	old := listingRetries
+	listingRetries = 0
+	t.Cleanup(func() { listingRetries = old })
+
+	m := newMockReddit(t, "r_private.403.json", "r_banned.404.json", "r_quarantined.403.json", "r_nsfw.html", "r_busy.503.json")
+	tests := []struct {
+		subreddit string
+		want      error
+	}{
+		{"private", ErrSubredditPrivate},
+		{"banned", ErrSubredditBanned},
+		{"quarantined", ErrSubredditQuarantined},
+		{"nsfw", ErrInterstitial},
+		{"missing", ErrNotFound},
+	}
+	for _, tt := range tests {
+		_, err := fetchRedditData(context.Background(), m.Client(), tt.subreddit, 10, nil)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("r/%s: err = %v, want %v", tt.subreddit, err, tt.want)
+		}
+	}
+
+	_, err := fetchRedditData(context.Background(), m.Client(), "busy", 10, nil)
+	var se *httpStatusError
+	if !errors.As(err, &se) || se.Code != http.StatusServiceUnavailable {
+		t.Errorf("r/busy: err = %v, want status 503", err)
+	}
+}
+
+func TestMockRedditServesImages(t *testing.T) {
+	m := newMockReddit(t)
+	data := pngBytes(t, 4, 3, color.White)
+	url := m.addImage("cat.png", data)
+
+	dl := newDownloader(m.Client(), 0, 0)
+	raw, err := dl.fetchImageData(context.Background(), url)
+	if err != nil {
+		t.Fatalf("fetchImageData: %v", err)
+	}
+	if !bytes.Equal(raw.Data, data) {
+		t.Error("image body differs from the one added")
+	}
+	if _, err := dl.fetchImageData(context.Background(), m.URL+"/img/missing.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing image: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// startCommand starts a program without waiting for it to exit. It is a
+// variable so the command openInFileManager picks can be checked without
+// running it.
+var startCommand = func(name string, args ...string) error {
+	return exec.Command(name, args...).Start()
+}
+
+// fileManagerCommand returns the program and arguments that open dir in the
+// file manager on goos.
+func fileManagerCommand(goos, dir string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{dir}
+	case "windows":
+		return "explorer", []string{dir}
+	default:
+		return "xdg-open", []string{dir}
+	}
+}
+
+// openInFileManager opens dir in the system file manager, for
+// --open-after.
+func openInFileManager(dir string) error {
+	name, args := fileManagerCommand(runtime.GOOS, dir)
+	return startCommand(name, args...)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestFileManagerCommand(t *testing.T) {
+	tests := []struct {
+		goos string
+		want string
+	}{
+		{"darwin", "open"},
+		{"windows", "explorer"},
+		{"linux", "xdg-open"},
+		{"freebsd", "xdg-open"},
+	}
+	for _, tt := range tests {
+		name, args := fileManagerCommand(tt.goos, "/tmp/imgDls")
+		if name != tt.want || !slices.Equal(args, []string{"/tmp/imgDls"}) {
+			t.Errorf("fileManagerCommand(%q) = %s %v, want %s [/tmp/imgDls]", tt.goos, name, args, tt.want)
+		}
+	}
+}
+
+func TestOpenInFileManagerStartsCommand(t *testing.T) {
+	old := startCommand
+	t.Cleanup(func() { startCommand = old })
+
+	var gotName string
+	var gotArgs []string
+	startCommand = func(name string, args ...string) error {
+		gotName, gotArgs = name, args
+		return errors.New("no display")
+	}
+	if err := openInFileManager("imgDls"); err == nil {
+		t.Error("the command's error was not returned")
+	}
+	wantName, wantArgs := fileManagerCommand(runtime.GOOS, "imgDls")
+	if gotName != wantName || !slices.Equal(gotArgs, wantArgs) {
+		t.Errorf("started %s %v, want %s %v", gotName, gotArgs, wantName, wantArgs)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"math/bits"
+	"os"
+	"strconv"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// hashIndexFileName is the name of the perceptual hash index inside the
+// download directory, kept by --dedupe-across-runs.
+const hashIndexFileName = "hashes.json"
+
+// dHash returns the difference hash of img: it is shrunk to 9x8 grey pixels
+// and each bit records whether a pixel is brighter than its right-hand
+// neighbour. Rescaled or recompressed copies of an image hash to the same
+// or nearly the same value, so reposts are found by a small Hamming
+// distance between hashes.
+func dHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// hashDistance is the number of bits in which a and b differ.
+func hashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// hashStore is the on-disk index of the perceptual hashes of images seen in
+// this and earlier runs, keyed by image URL. Like the manifest, every change
+// is written atomically. A nil hashStore considers nothing a duplicate.
+type hashStore struct {
+	path string
+	// distance is the largest Hamming distance at which two hashes count
+	// as the same image.
+	distance int
+
+	mu     sync.Mutex
+	hashes map[string]uint64
+}
+
+// newHashStore returns an empty store persisted at path. Call Load to read
+// any existing hashes.
+func newHashStore(path string, distance int) *hashStore {
+	return &hashStore{path: path, distance: distance, hashes: make(map[string]uint64)}
+}
+
+// Load reads the index from disk. A missing file is not an error and leaves
+// the store empty.
+func (s *hashStore) Load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read hash index: %w", err)
+	}
+
+	// Hashes are stored as hex strings; JSON numbers cannot hold every
+	// uint64 exactly.
+	var stored map[string]string
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse hash index: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashes = make(map[string]uint64, len(stored))
+	for url, hex := range stored {
+		h, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse hash index: bad hash %q for %s", hex, url)
+		}
+		s.hashes[url] = h
+	}
+	return nil
+}
+
+// Claim records hash h for the image at url, unless an image already in the
+// store is within the distance of it. Then it returns that image's URL and
+// false, and records nothing.
+func (s *hashStore) Claim(url string, h uint64) (string, bool, error) {
+	if s == nil {
+		return "", true, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for seen, other := range s.hashes {
+		if hashDistance(h, other) <= s.distance {
+			return seen, false, nil
+		}
+	}
+	s.hashes[url] = h
+	return "", true, s.saveLocked()
+}
+
+func (s *hashStore) saveLocked() error {
+	stored := make(map[string]string, len(s.hashes))
+	for url, h := range s.hashes {
+		stored[url] = fmt.Sprintf("%016x", h)
+	}
+	// Maps are written with sorted keys, so the file is stable.
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode hash index: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write hash index: %w", err)
+	}
+	return nil
+}
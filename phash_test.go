@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"path/filepath"
+	"testing"
+)
+
+// scene draws a blocky test picture whose brightness varies in both
+// directions, with seed shifting the pattern.
+func scene(w, h, seed int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := uint8((x*8/w*37 + y*8/h*91 + seed*53) % 256)
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 0xff})
+		}
+	}
+	return img
+}
+
+func TestDHashStability(t *testing.T) {
+	img := scene(640, 480, 0)
+	h := dHash(img)
+	if again := dHash(img); again != h {
+		t.Fatalf("dHash changed between calls: %016x then %016x", h, again)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, dup := range map[string]image.Image{
+		"downscaled":   resizeImage(img, 200),
+		"recompressed": recompressed,
+	} {
+		if d := hashDistance(h, dHash(dup)); d > 4 {
+			t.Errorf("%s copy is %d bits away, want at most 4", name, d)
+		}
+	}
+	if d := hashDistance(h, dHash(scene(640, 480, 1))); d <= 10 {
+		t.Errorf("a different picture is only %d bits away", d)
+	}
+}
+
+func TestHashDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xff, 0x0f, 4},
+		{0, ^uint64(0), 64},
+	}
+	for _, tt := range tests {
+		if got := hashDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("hashDistance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestHashStoreClaimsNearDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), hashIndexFileName)
+	s := newHashStore(path, 2)
+	const h = 0xf0f0_f0f0_f0f0_f0f0
+	if _, ok, err := s.Claim("https://i.redd.it/a.jpg", h); !ok || err != nil {
+		t.Fatalf("first Claim = %v, %v", ok, err)
+	}
+	// Two bits away: within the distance.
+	if seen, ok, _ := s.Claim("https://i.redd.it/b.jpg", h^0b11); ok || seen != "https://i.redd.it/a.jpg" {
+		t.Errorf("near duplicate claimed = %v (seen %q), want a duplicate of a.jpg", ok, seen)
+	}
+	// Three bits away: a different image.
+	if _, ok, _ := s.Claim("https://i.redd.it/c.jpg", h^0b111); !ok {
+		t.Error("an image 3 bits away was taken for a duplicate at distance 2")
+	}
+
+	// A later run loads the hashes and still spots the repost.
+	later := newHashStore(path, 2)
+	if err := later.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// One bit from a.jpg and four from c.jpg, so only a.jpg matches.
+	if seen, ok, _ := later.Claim("https://i.redd.it/repost.jpg", h^1<<63); ok || seen != "https://i.redd.it/a.jpg" {
+		t.Errorf("repost after a restart claimed = %v (seen %q), want a duplicate of a.jpg", ok, seen)
+	}
+	if got := len(later.hashes); got != 2 {
+		t.Errorf("reloaded store has %d hashes, want 2", got)
+	}
+}
+
+func TestNilHashStoreAllowsEverything(t *testing.T) {
+	var s *hashStore
+	for range 2 {
+		if _, ok, err := s.Claim("https://i.redd.it/a.jpg", 1); !ok || err != nil {
+			t.Errorf("nil store Claim = %v, %v, want true", ok, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// processPosts runs posts through the session in two stages connected by a
+// channel: downloadWorkers goroutines fetch image bodies, which is I/O
+// bound, and decodeWorkers goroutines decode, resize and save them, which is
+// CPU bound. Results are returned in post order, nil for posts that were
+// skipped or failed, unless a failure gets a placeholder card. Once the
+// download budget runs out no further posts are started; the number of posts
+// skipped for that reason is returned too. Cancelling ctx stops new posts
+// from starting and aborts downloads in flight; posts already downloaded are
+// still decoded, though one whose decode fails is then not fetched again.
+// With s.buffer set, downloads wait while the images downloaded but not yet
+// handed to s.onResult would overflow it. Progress is reported on s.events
+// when set, finishing with a Done event, and each result is also handed to
+// s.onResult when set.
+func (s *session) processPosts(ctx context.Context, posts []Post, downloadWorkers, decodeWorkers int) ([]*postResult, int) {
+	downloadWorkers = max(downloadWorkers, 1)
+	decodeWorkers = max(decodeWorkers, 1)
+
+	type job struct {
+		index   int
+		fetched *fetchedPost
+		// size is what the job holds of s.buffer until its result is
+		// delivered.
+		size int64
+	}
+
+	results := make([]*postResult, len(posts))
+	finish := func(i int, result *postResult) {
+		results[i] = result
+		if result != nil && s.onResult != nil {
+			s.onResult(i, result)
+		}
+	}
+	indexes := make(chan int)
+	fetched := make(chan job, decodeWorkers)
+
+	var budgetHit atomic.Bool
+	var budgetSkipped atomic.Int64
+
+	go func() {
+		defer close(indexes)
+		for i := range posts {
+			if budgetHit.Load() {
+				budgetSkipped.Add(int64(len(posts) - i))
+				return
+			}
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var downloads sync.WaitGroup
+	for range downloadWorkers {
+		downloads.Add(1)
+		go func() {
+			defer downloads.Done()
+			for i := range indexes {
+				s.emit(Event{Kind: PostFetched, Post: posts[i]})
+				f, err := s.fetchPost(ctx, posts[i])
+				if errors.Is(err, errDownloadBudget) {
+					budgetHit.Store(true)
+					budgetSkipped.Add(1)
+				}
+				if err != nil {
+					finish(i, s.failedResult(posts[i], err))
+					s.emit(Event{Kind: ImageFailed, Post: posts[i], Err: err})
+					continue
+				}
+				var size int64
+				if f.raw != nil && s.buffer != nil {
+					size = decodedSize(f.raw.Data)
+					if err := s.buffer.acquire(ctx, size); err != nil {
+						s.emit(Event{Kind: ImageFailed, Post: posts[i], Err: err})
+						continue
+					}
+				}
+				fetched <- job{index: i, fetched: f, size: size}
+			}
+		}()
+	}
+	go func() {
+		downloads.Wait()
+		close(fetched)
+	}()
+
+	var decodes sync.WaitGroup
+	for range decodeWorkers {
+		decodes.Add(1)
+		go func() {
+			defer decodes.Done()
+			for j := range fetched {
+				result, err := s.finishPost(ctx, j.fetched)
+				if err != nil {
+					finish(j.index, s.failedResult(j.fetched.post, err))
+					s.buffer.release(j.size)
+					s.emit(Event{Kind: ImageFailed, Post: j.fetched.post, Err: err})
+					continue
+				}
+				// Released only once s.onResult has taken the card, so
+				// the buffer covers decoded images not yet in the feed.
+				finish(j.index, result)
+				s.buffer.release(j.size)
+				s.emit(Event{Kind: ImageDownloaded, Post: j.fetched.post})
+			}
+		}()
+	}
+	decodes.Wait()
+	s.emit(Event{Kind: Done})
+
+	return results, int(budgetSkipped.Load())
+}
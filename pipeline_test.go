@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newMockSession returns a headless session downloading through m with
+// everything optional left off.
+func newMockSession(m *mockReddit) *session {
+	return &session{dl: newDownloader(m.Client(), 0, 0), stats: newRunStats()}
+}
+
+// mockPosts serves n small PNGs from m and returns a post for each.
+func mockPosts(t *testing.T, m *mockReddit, n int) []Post {
+	t.Helper()
+	data := pngBytes(t, 8, 8, color.White)
+	posts := make([]Post, n)
+	for i := range posts {
+		name := fmt.Sprintf("post%d.png", i)
+		posts[i] = Post{ID: fmt.Sprint(i), Title: name, URL: m.addImage(name, data)}
+	}
+	return posts
+}
+
+// concurrencyProbe records how many callers are inside enter at once. Each
+// caller waits for up to a second for want callers to arrive, so work that
+// can run want-way parallel does.
+type concurrencyProbe struct {
+	want int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	current int
+	peak    int
+}
+
+func newConcurrencyProbe(want int) *concurrencyProbe {
+	p := &concurrencyProbe{want: want}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *concurrencyProbe) enter() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current++
+	p.peak = max(p.peak, p.current)
+	p.cond.Broadcast()
+
+	timer := time.AfterFunc(time.Second, p.cond.Broadcast)
+	defer timer.Stop()
+	deadline := time.Now().Add(time.Second)
+	for p.peak < p.want && time.Now().Before(deadline) {
+		p.cond.Wait()
+	}
+	p.current--
+}
+
+func TestProcessPostsStageParallelism(t *testing.T) {
+	const downloadWorkers, decodeWorkers = 4, 2
+
+	m := newMockReddit(t)
+	data := pngBytes(t, 8, 8, color.White)
+	downloads := newConcurrencyProbe(downloadWorkers)
+	posts := make([]Post, 12)
+	for i := range posts {
+		posts[i] = Post{ID: fmt.Sprint(i), URL: m.handle(fmt.Sprintf("/slow/%d.png", i), func(w http.ResponseWriter, r *http.Request) {
+			downloads.enter()
+			w.Write(data)
+		})}
+	}
+
+	s := newMockSession(m)
+	decodes := newConcurrencyProbe(decodeWorkers)
+	s.onResult = func(int, *postResult) { decodes.enter() }
+	results, _ := s.processPosts(context.Background(), posts, downloadWorkers, decodeWorkers)
+
+	for i, result := range results {
+		if result == nil {
+			t.Fatalf("post %d failed", i)
+		}
+	}
+	if downloads.peak != downloadWorkers {
+		t.Errorf("%d downloads ran at once, want %d", downloads.peak, downloadWorkers)
+	}
+	if decodes.peak != decodeWorkers {
+		t.Errorf("%d decodes ran at once, want %d", decodes.peak, decodeWorkers)
+	}
+}
+
+func TestProcessPostsEvents(t *testing.T) {
+	m := newMockReddit(t)
+	posts := mockPosts(t, m, 2)
+	posts = append(posts, Post{ID: "gone", URL: m.URL + "/img/gone.png"}, Post{ID: "page", URL: m.URL + "/article"})
+
+	s := newMockSession(m)
+	events := make(chan Event)
+	s.events = events
+	go s.processPosts(context.Background(), posts, 2, 2)
+
+	perPost := make(map[string][]EventKind)
+	var errs []error
+	for e := range events {
+		if e.Kind == Done {
+			break
+		}
+		perPost[e.Post.ID] = append(perPost[e.Post.ID], e.Kind)
+		if e.Kind == ImageFailed {
+			errs = append(errs, e.Err)
+		}
+	}
+
+	want := map[string][]EventKind{
+		"0":    {PostFetched, ImageDownloaded},
+		"1":    {PostFetched, ImageDownloaded},
+		"gone": {PostFetched, ImageFailed},
+		"page": {PostFetched, ImageFailed},
+	}
+	if !reflect.DeepEqual(perPost, want) {
+		t.Errorf("events by post = %v, want %v", perPost, want)
+	}
+	for _, err := range errs {
+		if err == nil {
+			t.Error("an ImageFailed event has no error")
+		}
+	}
+	select {
+	case e, ok := <-events:
+		if ok {
+			t.Errorf("event after Done: %v", e.Kind)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
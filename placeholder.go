@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"os"
+)
+
+// builtinPlaceholder is the --placeholder value selecting the generated
+// placeholder rather than an image file.
+const builtinPlaceholder = "builtin"
+
+// loadPlaceholder returns the image shown for posts that fail to load, per
+// --placeholder: nil when spec is empty, the generated placeholder for
+// "builtin", and otherwise the image file at spec, scaled to the card width.
+// A file that cannot be read falls back to the generated placeholder.
+func loadPlaceholder(spec string) image.Image {
+	switch spec {
+	case "":
+		return nil
+	case builtinPlaceholder:
+		return newBrokenImagePlaceholder()
+	}
+	img, err := readPlaceholder(spec)
+	if err != nil {
+		log.Printf("Failed to load placeholder, using the built-in one: %v", err)
+		return newBrokenImagePlaceholder()
+	}
+	return resizeImage(img, cardWidth)
+}
+
+func readPlaceholder(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// newBrokenImagePlaceholder draws a card-sized "broken image": a grey panel
+// with a border and a cross through it.
+func newBrokenImagePlaceholder() image.Image {
+	const w, h, border = cardWidth, cardWidth * 3 / 4, 4
+	fg := color.RGBA{0x80, 0x80, 0x80, 0xff}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{fg}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(border, border, w-border, h-border), &image.Uniform{color.RGBA{0xd0, 0xd0, 0xd0, 0xff}}, image.Point{}, draw.Src)
+	for x := 0; x < w; x++ {
+		y := x * h / w
+		for t := -1; t <= 1; t++ {
+			img.Set(x, y+t, fg)
+			img.Set(x, h-1-y+t, fg)
+		}
+	}
+	return img
+}
+
+// isDeliberateSkip reports whether err means a post was left out on
+// purpose, or because the run is stopping, rather than failing to load.
+func isDeliberateSkip(err error) bool {
+	for _, skip := range []error{errNotImage, errAlreadySaved, errFiltered, errDuplicate, ErrUnsupportedHost, errDownloadBudget, context.Canceled, context.DeadlineExceeded} {
+		if errors.Is(err, skip) {
+			return true
+		}
+	}
+	return false
+}
+
+// failedResult returns a placeholder card for post, which failed to load
+// with err. It returns nil when no placeholder is configured, the session
+// builds no cards, or the post was skipped on purpose. The result has no
+// thumbnail, so the placeholder stays out of the contact sheet.
+func (s *session) failedResult(post Post, err error) *postResult {
+	if s.opts.placeholder == nil || s.win == nil || isDeliberateSkip(err) {
+		return nil
+	}
+	postTitle := post.Title
+	if s.opts.cleanTitles {
+		postTitle = cleanTitle(postTitle)
+	}
+	// There is nothing to hide behind a placeholder.
+	post.Spoiler = false
+	card, _ := newCard(post, postTitle, s.opts.placeholder, "failed: "+failureReason(err), s.opts.subredditBadges, false, s.opts.fit, s.opts.title)
+	return &postResult{card: card, title: postTitle}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fyne.io/fyne/v2/canvas"
+)
+
+func TestLoadPlaceholder(t *testing.T) {
+	if img := loadPlaceholder(""); img != nil {
+		t.Error("a placeholder was loaded without --placeholder")
+	}
+	builtin := loadPlaceholder(builtinPlaceholder)
+	if builtin == nil || builtin.Bounds().Dx() != cardWidth {
+		t.Fatalf("built-in placeholder = %v, want one card wide", builtin)
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.png")
+	if err := os.WriteFile(path, pngBytes(t, 800, 200, color.RGBA{R: 0xff, A: 0xff}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fromFile := loadPlaceholder(path)
+	if got := fromFile.Bounds().Size(); got != image.Pt(cardWidth, 100) {
+		t.Errorf("placeholder file is shown at %v, want scaled to %dx100", got, cardWidth)
+	}
+	if r, _, _, _ := fromFile.At(10, 10).RGBA(); r>>8 != 0xff {
+		t.Error("placeholder file was not used")
+	}
+
+	// A missing file falls back to the built-in placeholder.
+	missing := loadPlaceholder(filepath.Join(t.TempDir(), "missing.png"))
+	if missing == nil || missing.Bounds() != builtin.Bounds() {
+		t.Errorf("missing placeholder file = %v, want the built-in one", missing)
+	}
+}
+
+func TestPlaceholderShownForFailedPosts(t *testing.T) {
+	m := newMockReddit(t)
+	posts := []Post{
+		{ID: "a", Title: "Gone", URL: m.URL + "/img/gone.png"},
+		{ID: "b", Title: "Article", URL: m.URL + "/article"},
+		{ID: "c", Title: "Corrupt", URL: m.addImage("corrupt.png", []byte("\x89PNG not really"))},
+	}
+
+	placeholder := newBrokenImagePlaceholder()
+	s := newMockWindowSession(t, m)
+	s.opts.placeholder = placeholder
+	results, _ := s.processPosts(context.Background(), posts, 1, 1)
+
+	for i, want := range []string{"failed: not-found", "", "failed: decode"} {
+		if want == "" {
+			// A link that is not an image is skipped on purpose.
+			if results[i] != nil {
+				t.Errorf("%s: got a card for a deliberate skip", posts[i].Title)
+			}
+			continue
+		}
+		if results[i] == nil || results[i].card == nil {
+			t.Errorf("%s: no placeholder card", posts[i].Title)
+			continue
+		}
+		if results[i].thumb != nil {
+			t.Errorf("%s: placeholder card has a thumbnail for the contact sheet", posts[i].Title)
+		}
+		images := findObjects[*canvas.Image](results[i].card)
+		if len(images) != 1 || images[0].Image != placeholder {
+			t.Errorf("%s: card does not show the placeholder", posts[i].Title)
+		}
+		var reason bool
+		for _, text := range findObjects[*canvas.Text](results[i].card) {
+			reason = reason || text.Text == want
+		}
+		if !reason {
+			t.Errorf("%s: card does not say %q", posts[i].Title, want)
+		}
+	}
+}
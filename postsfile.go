@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// exportPosts writes posts as a JSON array to path, for loading again later
+// with --from-file. Posts are written as fetched, before gallery expansion,
+// using the same field names as the Reddit API.
+func exportPosts(path string, posts []Post) error {
+	data, err := json.MarshalIndent(posts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode posts: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadPostsFile reads posts written by exportPosts.
+func loadPostsFile(path string) ([]Post, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var posts []Post
+	if err := json.NewDecoder(f).Decode(&posts); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return posts, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportPostsRoundTrip(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json", "r_gallery.json")
+	path := filepath.Join(t.TempDir(), "posts.json")
+
+	export := newMockRunner(t, m, "list", "--subreddit=pics,gallery", "--export-posts="+path)
+	defer export.close()
+	fetched, err := export.fetchInitial()
+	if err != nil {
+		t.Fatalf("fetching: %v", err)
+	}
+	requests := len(m.requestLog())
+
+	load := newMockRunner(t, m, "list", "--from-file="+path)
+	defer load.close()
+	loaded, err := load.fetchInitial()
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if len(fetched) != 5 {
+		t.Fatalf("fetched %d posts, want 5", len(fetched))
+	}
+	if !reflect.DeepEqual(loaded, fetched) {
+		t.Errorf("loaded posts differ from those exported:\n got %+v\nwant %+v", loaded, fetched)
+	}
+	// Galleries survive the trip, so they expand the same way.
+	if got, want := len(load.expand(loaded)), len(export.expand(fetched)); got != want {
+		t.Errorf("loaded posts expand to %d, want %d", got, want)
+	}
+	if got := len(m.requestLog()); got != requests {
+		t.Errorf("--from-file made %d requests", got-requests)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// redgifsAPIBase is the RedGifs v2 API endpoint.
+const redgifsAPIBase = "https://api.redgifs.com"
+
+// redgifsResolver resolves RedGifs watch pages to the poster image of the
+// clip through the RedGifs API, which needs a temporary bearer token.
+type redgifsResolver struct {
+	client  *http.Client
+	apiBase string
+
+	mu    sync.Mutex
+	token string
+}
+
+func newRedgifsResolver(client *http.Client) *redgifsResolver {
+	return &redgifsResolver{client: client, apiBase: redgifsAPIBase}
+}
+
+func (r *redgifsResolver) Name() string { return "redgifs" }
+
+func (r *redgifsResolver) Match(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == "redgifs.com" || strings.HasSuffix(host, ".redgifs.com")
+}
+
+// redgifsID extracts the clip id from URLs such as
+// https://www.redgifs.com/watch/somename or https://i.redgifs.com/i/somename.jpg.
+func redgifsID(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	base := path.Base(u.Path)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	base = strings.SplitN(base, "-", 2)[0]
+	if base == "" || base == "." || base == "/" {
+		return "", fmt.Errorf("no clip id in %s", rawURL)
+	}
+	return strings.ToLower(base), nil
+}
+
+func (r *redgifsResolver) Resolve(ctx context.Context, rawURL string) (string, error) {
+	id, err := redgifsID(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	poster, err := r.lookup(ctx, id)
+	if errors.Is(err, errRedgifsUnauthorized) {
+		// Temporary tokens expire; fetch a new one and try once more.
+		r.mu.Lock()
+		r.token = ""
+		r.mu.Unlock()
+		poster, err = r.lookup(ctx, id)
+	}
+	return poster, err
+}
+
+var errRedgifsUnauthorized = errors.New("unauthorized")
+
+// lookup fetches the clip's metadata and returns its poster image URL.
+func (r *redgifsResolver) lookup(ctx context.Context, id string) (string, error) {
+	token, err := r.authToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", r.apiBase+"/v2/gifs/"+url.PathEscape(id), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var body struct {
+		GIF struct {
+			URLs struct {
+				Poster    string `json:"poster"`
+				Thumbnail string `json:"thumbnail"`
+				HD        string `json:"hd"`
+				SD        string `json:"sd"`
+			} `json:"urls"`
+		} `json:"gif"`
+	}
+	if err := r.getJSON(req, &body); err != nil {
+		return "", err
+	}
+
+	urls := body.GIF.URLs
+	for _, u := range []string{urls.Poster, urls.Thumbnail} {
+		if u != "" {
+			return u, nil
+		}
+	}
+	return "", fmt.Errorf("no poster image for clip %s", id)
+}
+
+// authToken returns the cached temporary token, requesting one if needed.
+func (r *redgifsResolver) authToken(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token != "" {
+		return r.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", r.apiBase+"/v2/auth/temporary", nil)
+	if err != nil {
+		return "", err
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := r.getJSON(req, &body); err != nil {
+		return "", fmt.Errorf("failed to get temporary token: %w", err)
+	}
+	if body.Token == "" {
+		return "", errors.New("empty temporary token")
+	}
+	r.token = body.Token
+	return r.token, nil
+}
+
+func (r *redgifsResolver) getJSON(req *http.Request, v any) error {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return errRedgifsUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return statusError(resp.StatusCode, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
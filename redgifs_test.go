@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRedgifsIDs(t *testing.T) {
+	tests := map[string]string{
+		"https://www.redgifs.com/watch/SomeName":          "somename",
+		"https://redgifs.com/watch/somename-with-tags":    "somename",
+		"https://i.redgifs.com/i/somename.jpg":            "somename",
+		"https://thumbs2.redgifs.com/SomeName-mobile.mp4": "somename",
+	}
+	for raw, want := range tests {
+		if got, err := redgifsID(raw); err != nil || got != want {
+			t.Errorf("redgifsID(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := redgifsID("https://www.redgifs.com/"); err == nil {
+		t.Error("redgifsID found an id in a URL without one")
+	}
+}
+
+func TestRedgifsResolverMatch(t *testing.T) {
+	r := newRedgifsResolver(nil)
+	for raw, want := range map[string]bool{
+		"https://www.redgifs.com/watch/x": true,
+		"https://redgifs.com/watch/x":     true,
+		"https://notredgifs.com/watch/x":  false,
+		"https://i.redd.it/x.png":         false,
+	} {
+		u, _ := url.Parse(raw)
+		if got := r.Match(u); got != want {
+			t.Errorf("Match(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestRedgifsResolverResolvesPoster(t *testing.T) {
+	m := newMockReddit(t)
+	var tokens atomic.Int32
+	m.handle("/v2/auth/temporary", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"token": "token%d"}`, tokens.Add(1))
+	})
+	m.handle("/v2/gifs/somename", func(w http.ResponseWriter, r *http.Request) {
+		// The first token has expired by the time it is used.
+		if r.Header.Get("Authorization") != "Bearer token2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"gif": {"urls": {"poster": "https://media.redgifs.com/SomeName-poster.jpg", "thumbnail": "https://media.redgifs.com/SomeName-mobile.jpg", "hd": "https://media.redgifs.com/SomeName.mp4"}}}`)
+	})
+
+	r := newRedgifsResolver(m.Client())
+	r.apiBase = m.URL
+	got, err := r.Resolve(context.Background(), "https://www.redgifs.com/watch/SomeName")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if want := "https://media.redgifs.com/SomeName-poster.jpg"; got != want {
+		t.Errorf("Resolve = %s, want %s", got, want)
+	}
+	if n := tokens.Load(); n != 2 {
+		t.Errorf("requested %d tokens, want 2 (one refresh after the 401)", n)
+	}
+
+	// The refreshed token is cached for later clips.
+	if _, err := r.Resolve(context.Background(), "https://www.redgifs.com/watch/somename"); err != nil {
+		t.Fatalf("second Resolve: %v", err)
+	}
+	if n := tokens.Load(); n != 2 {
+		t.Errorf("requested %d tokens after a second clip, want 2", n)
+	}
+}
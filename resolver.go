@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// urlResolver turns a post URL that points at a hosting page rather than an
+// image (e.g. a RedGifs watch page) into a direct image URL.
+type urlResolver interface {
+	// Name identifies the resolver in logs and errors.
+	Name() string
+	// Match reports whether the resolver handles u.
+	Match(u *url.URL) bool
+	// Resolve returns the direct image URL for rawURL.
+	Resolve(ctx context.Context, rawURL string) (string, error)
+}
+
+// resolverRegistry tries each of its resolvers in the order given to
+// newResolverRegistry.
+type resolverRegistry struct {
+	resolvers []urlResolver
+}
+
+func newResolverRegistry(resolvers ...urlResolver) *resolverRegistry {
+	return &resolverRegistry{resolvers: resolvers}
+}
+
+// resolve returns the direct image URL for rawURL using the first matching
+// resolver. URLs no resolver matches are returned unchanged.
+func (reg *resolverRegistry) resolve(ctx context.Context, rawURL string) (string, error) {
+	if reg == nil {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, nil
+	}
+	for _, r := range reg.resolvers {
+		if !r.Match(u) {
+			continue
+		}
+		resolved, err := r.Resolve(ctx, rawURL)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", r.Name(), err)
+		}
+		return resolved, nil
+	}
+	return rawURL, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultRetryStatuses is the default of --retry-status: rate limiting and
+// the server errors that usually clear up on their own.
+const defaultRetryStatuses = "429,500,502,503,504"
+
+// retryableStatuses is the set of HTTP status codes worth retrying, for both
+// listing fetches and image downloads. It is replaced by --retry-status.
+var retryableStatuses = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// isRetryableStatus reports whether a response with status code is worth
+// another attempt.
+func isRetryableStatus(code int) bool {
+	return retryableStatuses[code]
+}
+
+// isRetryableStatusError reports whether err is an unexpected HTTP status
+// that isRetryableStatus accepts.
+func isRetryableStatusError(err error) bool {
+	var se *httpStatusError
+	return errors.As(err, &se) && isRetryableStatus(se.Code)
+}
+
+// parseRetryStatuses parses a comma-separated list of HTTP status codes,
+// as given to --retry-status. An empty list retries no status.
+func parseRetryStatuses(s string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		code, err := strconv.Atoi(field)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("%q is not an HTTP status code", field)
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"testing"
+)
+
+func TestIsRetryableStatusDefaults(t *testing.T) {
+	for code, want := range map[int]bool{
+		429: true, 500: true, 502: true, 503: true, 504: true,
+		200: false, 400: false, 403: false, 404: false, 501: false,
+	} {
+		if got := isRetryableStatus(code); got != want {
+			t.Errorf("isRetryableStatus(%d) = %v, want %v", code, got, want)
+		}
+	}
+
+	parsed, err := parseRetryStatuses(defaultRetryStatuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(parsed, retryableStatuses) {
+		t.Errorf("defaultRetryStatuses parses to %v, want the built-in set %v", parsed, retryableStatuses)
+	}
+}
+
+func TestParseRetryStatuses(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[int]bool
+		wantErr bool
+	}{
+		{in: "", want: map[int]bool{}},
+		{in: "404", want: map[int]bool{404: true}},
+		{in: " 408, 429 ,,", want: map[int]bool{408: true, 429: true}},
+		{in: "5xx", wantErr: true},
+		{in: "99", wantErr: true},
+		{in: "600", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRetryStatuses(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRetryStatuses(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !maps.Equal(got, tt.want) {
+			t.Errorf("parseRetryStatuses(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRetryStatusFlagOverridesSet(t *testing.T) {
+	m := newMockReddit(t)
+	r := newMockRunner(t, m, "show", "--subreddit=pics", "--retry-status=404,408")
+	defer r.close()
+
+	for code, want := range map[int]bool{404: true, 408: true, 429: false, 503: false} {
+		if got := isRetryableStatus(code); got != want {
+			t.Errorf("with --retry-status=404,408: isRetryableStatus(%d) = %v, want %v", code, got, want)
+		}
+	}
+	err := fmt.Errorf("failed to download image: %w", statusError(http.StatusNotFound, "404 Not Found"))
+	if !isRetryableStatusError(err) || !isRetryableDownload(err) {
+		t.Error("a wrapped 404 is not retried with --retry-status=404")
+	}
+}
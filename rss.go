@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// atomFeed is the subset of Reddit's Atom feed (served at /.rss) the image
+// scroller needs.
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Link  struct {
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	// Content is HTML; for link posts it contains a "[link]" anchor to
+	// the post URL.
+	Content string `xml:"content"`
+}
+
+// linkAnchor is the text of the anchor Reddit uses for the post URL.
+const linkAnchor = "[link]"
+
+var (
+	hrefRe   = regexp.MustCompile(`<a\s+href="([^"]+)"\s*>([^<]*)</a>`)
+	imgSrcRe = regexp.MustCompile(`<img\s+src="([^"]+)"`)
+)
+
+// parseRSSFeed reads a Reddit Atom feed and returns its entries as posts.
+// The post URL is the entry's "[link]" target, falling back to any direct
+// image link in its content.
+func parseRSSFeed(r io.Reader) ([]Post, error) {
+	var feed atomFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("error parsing feed: %w", err)
+	}
+
+	posts := make([]Post, 0, len(feed.Entries))
+	for _, e := range feed.Entries {
+		post := Post{
+			ID:    strings.TrimPrefix(e.ID, "t3_"),
+			Title: e.Title,
+			URL:   entryImageURL(e.Content),
+		}
+		if u, err := url.Parse(e.Link.Href); err == nil {
+			post.Permalink = u.Path
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// entryImageURL picks the post URL out of an entry's HTML content.
+func entryImageURL(content string) string {
+	var fallback string
+	for _, m := range hrefRe.FindAllStringSubmatch(content, -1) {
+		href := html.UnescapeString(m[1])
+		if strings.TrimSpace(m[2]) == linkAnchor {
+			return href
+		}
+		if fallback == "" && isValidImageURL(href) {
+			fallback = href
+		}
+	}
+	if fallback == "" {
+		if m := imgSrcRe.FindStringSubmatch(content); m != nil {
+			fallback = html.UnescapeString(m[1])
+		}
+	}
+	return fallback
+}
+
+// fetchRedditRSS fetches up to limit posts from the subreddit's Atom feed,
+// an alternative to the JSON listing for clients that cannot use it. Posts
+// for which skip returns true are dropped; skip may be nil.
+func fetchRedditRSS(ctx context.Context, client *http.Client, subreddit string, limit int, skip func(Post) bool) ([]Post, error) {
+	pageSize := limit
+	if limit <= 0 {
+		// The feed is not paginated; one full page is all it offers.
+		pageSize = maxPageSize
+	}
+	feedURL := listingURL(subreddit, ".rss", url.Values{"limit": {strconv.Itoa(pageSize)}})
+	log.Println("Fetching URL:", feedURL)
+	req, _ := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, redditStatusError(subreddit, resp.StatusCode, body)
+	}
+
+	feed, err := parseRSSFeed(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []Post
+	for _, p := range feed {
+		if skip != nil && skip(p) {
+			continue
+		}
+		posts = append(posts, p)
+	}
+	log.Printf("Fetched %d posts", len(posts))
+	if limit > 0 && len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRSSFeed(t *testing.T) {
+	f, err := os.Open(filepath.Join(testdataDir, "r_pics.rss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	posts, err := parseRSSFeed(f)
+	if err != nil {
+		t.Fatalf("parseRSSFeed: %v", err)
+	}
+	want := []Post{
+		// The "[link]" anchor, not the thumbnail or the comments link.
+		{ID: "p1", Title: "First cat [OC]", URL: "$MOCK/img/cat.png", Permalink: "/r/pics/comments/p1/first_cat/"},
+		// A self post linking an image in its text, entities decoded.
+		{ID: "p2", Title: "A dog", URL: "$MOCK/img/dog.png?width=640&format=png", Permalink: "/r/pics/comments/p2/a_dog/"},
+	}
+	if len(posts) != len(want) {
+		t.Fatalf("got %d posts, want %d", len(posts), len(want))
+	}
+	for i, p := range posts {
+		w := want[i]
+		if p.ID != w.ID || p.Title != w.Title || p.URL != w.URL || p.Permalink != w.Permalink {
+			t.Errorf("post %d = %+v, want %+v", i, p, w)
+		}
+	}
+}
+
+func TestFetchRedditRSS(t *testing.T) {
+	m := newMockReddit(t, "r_pics.rss")
+
+	posts, err := fetchRedditRSS(context.Background(), m.Client(), "pics", 1, nil)
+	if err != nil {
+		t.Fatalf("fetchRedditRSS: %v", err)
+	}
+	if len(posts) != 1 || posts[0].URL != m.URL+"/img/cat.png" {
+		t.Errorf("posts = %+v, want the first post linking %s/img/cat.png", posts, m.URL)
+	}
+	if got := m.requestLog(); len(got) != 1 || got[0] != "/r/pics/.rss?limit=1" {
+		t.Errorf("requests = %q, want one for /r/pics/.rss?limit=1", got)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+)
+
+// Saver is an output destination for saved images. name is the file name
+// from --save-filename-template, img the image as shown and raw its encoded
+// bytes in the format of name's extension; most savers only need raw.
+// Save may be called from several goroutines at once.
+type Saver interface {
+	Save(name string, img image.Image, raw []byte) error
+}
+
+// metadataSaver is implemented by savers that store each image's manifest
+// entry alongside it, such as the zip archive's sidecars.
+type metadataSaver interface {
+	SaveMetadata(name string, entry manifestEntry) error
+}
+
+// fsSaver saves images as files in a directory, the download directory
+// unless --zip is given.
+type fsSaver struct {
+	dir string
+}
+
+func newFSSaver(dir string) *fsSaver {
+	return &fsSaver{dir: dir}
+}
+
+// Save writes raw to name in the directory, atomically so that an
+// interrupted run never leaves a partial image behind.
+func (f *fsSaver) Save(name string, img image.Image, raw []byte) error {
+	if err := writeFileAtomic(filepath.Join(f.dir, name), raw); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// recordingSaver is a Saver that keeps what it is given, failing the
+// names in fail.
+type recordingSaver struct {
+	fail map[string]bool
+
+	mu    sync.Mutex
+	saved map[string][]byte
+	sizes map[string]image.Point
+}
+
+func newRecordingSaver() *recordingSaver {
+	return &recordingSaver{fail: make(map[string]bool), saved: make(map[string][]byte), sizes: make(map[string]image.Point)}
+}
+
+func (s *recordingSaver) Save(name string, img image.Image, raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail[name] {
+		return errors.New("disk full")
+	}
+	s.saved[name] = raw
+	s.sizes[name] = img.Bounds().Size()
+	return nil
+}
+
+func TestSessionSavesThroughSaver(t *testing.T) {
+	m := newMockReddit(t)
+	cat := pngBytes(t, 8, 6, color.White)
+	posts := []Post{
+		{ID: "a", Title: "A cat", URL: m.addImage("cat.png", cat)},
+		{ID: "b", Title: "Gone", URL: m.URL + "/img/gone.png"},
+		{ID: "c", Title: "No room", URL: m.addImage("dog.png", pngBytes(t, 8, 8, color.Black))},
+	}
+
+	saver := newRecordingSaver()
+	saver.fail["No_room.png"] = true
+	s := newMockSession(m)
+	s.opts.download = true
+	s.opts.filenames, _ = parseFilenameTemplate(defaultFilenameTemplate)
+	s.saver = saver
+	results, _ := s.processPosts(context.Background(), posts, 1, 1)
+
+	var names []string
+	for name := range saver.saved {
+		names = append(names, name)
+	}
+	if !slices.Equal(names, []string{"A_cat.png"}) {
+		t.Fatalf("saved %v, want [A_cat.png]", names)
+	}
+	if !bytes.Equal(saver.saved["A_cat.png"], cat) {
+		t.Error("saver got re-encoded bytes for an unchanged image")
+	}
+	if got := saver.sizes["A_cat.png"]; got != image.Pt(8, 6) {
+		t.Errorf("saver got a %v image, want 8x6", got)
+	}
+	// A failed save still shows the image, but it is not listed as saved.
+	if results[2] == nil {
+		t.Error("the image that failed to save was dropped from the feed")
+	}
+	if len(s.savedPosts) != 1 || s.savedPosts[0].File != "A_cat.png" {
+		t.Errorf("saved posts = %+v, want only A_cat.png", s.savedPosts)
+	}
+}
+
+func TestFSSaverWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("image bytes")
+	if err := newFSSaver(dir).Save("x.png", nil, data); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "x.png"))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("x.png = %q, %v; want %q", got, err, data)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func jpegBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSaveReencodesOnlyChangedImages(t *testing.T) {
+	m := newMockReddit(t)
+	small := jpegBytes(t, 200, 100)
+	wide := jpegBytes(t, 800, 400)
+	posts := []Post{
+		{ID: "a", Title: "Small", URL: m.addImage("small.jpg", small)},
+		{ID: "b", Title: "Wide", URL: m.addImage("wide.jpg", wide)},
+	}
+
+	run := func(filter imageFilter) *recordingSaver {
+		saver := newRecordingSaver()
+		s := newMockSession(m)
+		s.opts.download = true
+		s.opts.filenames, _ = parseFilenameTemplate(defaultFilenameTemplate)
+		s.opts.filter = filter
+		s.saver = saver
+		s.processPosts(context.Background(), posts, 1, 1)
+		return saver
+	}
+
+	saver := run(imageFilter{})
+	if !bytes.Equal(saver.saved["Small.jpg"], small) {
+		t.Error("an unmodified JPEG was not saved byte-identical")
+	}
+	resized := saver.saved["Wide.jpg"]
+	if bytes.Equal(resized, wide) {
+		t.Fatal("a resized JPEG was saved as downloaded")
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(resized))
+	if err != nil {
+		t.Fatalf("resized JPEG: %v", err)
+	}
+	if cfg.Width != cardWidth {
+		t.Errorf("resized JPEG is %dpx wide, want %d", cfg.Width, cardWidth)
+	}
+
+	gray, err := newImageFilter(true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saver = run(gray)
+	if bytes.Equal(saver.saved["Small.jpg"], small) {
+		t.Error("a grayscaled JPEG was saved as downloaded")
+	}
+}
+
+func TestNeedsReencode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	tests := []struct {
+		name       string
+		downloaded downloadedImage
+		saved      image.Image
+		ext        string
+		want       bool
+	}{
+		{"unchanged", downloadedImage{Image: img, Format: "jpeg"}, img, ".jpg", false},
+		{"resized", downloadedImage{Image: img, Format: "jpeg"}, image.NewRGBA(image.Rect(0, 0, 2, 2)), ".jpg", true},
+		{"filtered", downloadedImage{Image: img, Format: "png", Modified: true}, img, ".png", true},
+		{"converted", downloadedImage{Image: img, Format: "png"}, img, ".jpg", true},
+		{"unknown extension", downloadedImage{Image: img, Format: "webp"}, img, ".webp", true},
+		{"animated GIF", downloadedImage{Image: img, Format: "gif", GIF: &gif.GIF{}}, image.NewRGBA(image.Rect(0, 0, 2, 2)), ".gif", false},
+	}
+	for _, tt := range tests {
+		if got := needsReencode(&tt.downloaded, tt.saved, tt.ext); got != tt.want {
+			t.Errorf("%s: needsReencode = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// seenIDsFileName is the name of the --only-new store inside the download
+// directory.
+const seenIDsFileName = "seen.json"
+
+// seenIDStore is the on-disk set of posts surfaced by earlier runs, keyed
+// by postKey, so --only-new can drop them whatever sort or time range
+// brought them back. Posts marked during a run are only written out by
+// Flush, at the end of it. A nil seenIDStore has seen nothing.
+type seenIDStore struct {
+	path string
+
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+// newSeenIDStore returns an empty store persisted at path. Call Load to read
+// the posts seen by earlier runs.
+func newSeenIDStore(path string) *seenIDStore {
+	return &seenIDStore{path: path, ids: make(map[string]bool)}
+}
+
+// Load reads the store from disk. A missing file is not an error and leaves
+// the store empty.
+func (s *seenIDStore) Load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read seen posts: %w", err)
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return fmt.Errorf("failed to parse seen posts: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		s.ids[id] = true
+	}
+	return nil
+}
+
+// Has reports whether p has been seen.
+func (s *seenIDStore) Has(p Post) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids[postKey(p)]
+}
+
+// Mark records posts as seen.
+func (s *seenIDStore) Mark(posts []Post) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range posts {
+		s.ids[postKey(p)] = true
+	}
+}
+
+// Flush writes the store out atomically.
+func (s *seenIDStore) Flush() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	data, err := json.MarshalIndent(ids, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode seen posts: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write seen posts: %w", err)
+	}
+	return nil
+}
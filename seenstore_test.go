@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSeenIDStorePersists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, seenIDsFileName)
+	s := newSeenIDStore(path)
+	s.Mark([]Post{{ID: "a"}, {URL: "https://i.redd.it/b.jpg"}})
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	reloaded := newSeenIDStore(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, p := range []Post{{ID: "a"}, {URL: "https://i.redd.it/b.jpg"}} {
+		if !reloaded.Has(p) {
+			t.Errorf("reloaded store has not seen %+v", p)
+		}
+	}
+	if reloaded.Has(Post{ID: "c"}) {
+		t.Error("reloaded store has seen a new post")
+	}
+	assertNoTempFiles(t, dir)
+
+	var none *seenIDStore
+	none.Mark([]Post{{ID: "a"}})
+	if none.Has(Post{ID: "a"}) || none.Flush() != nil {
+		t.Error("a nil store remembered a post or failed to flush")
+	}
+}
+
+func TestOnlyNewFiltersPostsSeenByEarlierRuns(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	chdirTemp(t)
+
+	first := newMockRunner(t, m, "show", "--subreddit=pics", "--only-new", "--limit=2")
+	posts, err := first.fetchInitial()
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if err := first.close(); err != nil {
+		t.Fatalf("first run close: %v", err)
+	}
+	if got := postIDs(posts); !slices.Equal(got, []string{"p1", "p2"}) {
+		t.Fatalf("first run surfaced %v, want [p1 p2]", got)
+	}
+
+	second := newMockRunner(t, m, "show", "--subreddit=pics", "--only-new", "--limit=2")
+	defer second.close()
+	posts, err = second.fetchInitial()
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if got := postIDs(posts); !slices.Equal(got, []string{"p3"}) {
+		t.Errorf("second run surfaced %v, want only the new [p3]", got)
+	}
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// sessionOptions are the flag values that affect how each post is handled.
+type sessionOptions struct {
+	download    bool
+	resume      bool
+	cleanTitles bool
+	// subredditBadges labels each card with its subreddit, for feeds
+	// merged from several subreddits.
+	subredditBadges bool
+	// filenames names saved images.
+	filenames *filenameTemplate
+	// includeText shows self posts as text cards instead of skipping them.
+	includeText bool
+	// markdown renders the body of text cards as markdown.
+	markdown bool
+	// noResize shows cards at native resolution rather than downscaled to
+	// cardWidth. Every card then keeps its full decoded image in memory,
+	// which for a long feed of large photos can run to gigabytes.
+	noResize bool
+	// filter is applied to images before they are shown or saved.
+	filter imageFilter
+	// maxPixels rejects images larger than this before decoding them.
+	maxPixels int64
+	// flatten, if set, is the colour transparent areas are filled with
+	// when saving as JPEG.
+	flatten color.Color
+	// types selects the media types shown and saved.
+	types mediaTypeFilter
+	// fit is how card images fill the space they are given.
+	fit canvas.ImageFill
+	// title is how post titles are drawn on cards.
+	title titleStyle
+	// stripMetadata removes EXIF and similar metadata from images saved
+	// as downloaded.
+	stripMetadata bool
+	// placeholder, if set, is shown in place of images that fail to load.
+	placeholder image.Image
+}
+
+// session turns posts into feed cards, downloading, saving and tallying
+// them along the way. It keeps the state shared across posts of a run and
+// is safe for use by several workers at once.
+type session struct {
+	opts  sessionOptions
+	dl    *downloader
+	saved *manifest
+	stats *runStats
+	// saver is where saved images are written.
+	saver Saver
+	// timings, if set, accumulates the time spent in each phase.
+	timings *phaseTimings
+	// hashes, if set, skips images seen in this or earlier runs.
+	hashes *hashStore
+	// gate, if set, holds back new downloads while paused from the UI.
+	gate *downloadGate
+	// buffer, if set, bounds the memory of images not yet decoded and
+	// added to the feed.
+	buffer *decodeBuffer
+	// decodes, if set, bounds the number of images decoded at once.
+	decodes *decodeLimiter
+	// resolvers map hosting pages to direct image URLs.
+	resolvers *resolverRegistry
+
+	win       fyne.Window
+	selection *cardSelection
+
+	// events, if set, receives progress events from processPosts.
+	events chan<- Event
+	// onResult, if set, is called by processPosts with each post's index
+	// and result as soon as the result is ready, so a window can show
+	// cards before the whole batch is done. Calls come from several
+	// goroutines, in no particular order.
+	onResult func(index int, result *postResult)
+
+	mu         sync.Mutex
+	savedPosts []Post
+}
+
+// postResult is what a successfully processed post contributes to the run.
+type postResult struct {
+	// card is nil for headless sessions.
+	card fyne.CanvasObject
+	// thumb is the resized image shown in the card, or nil for text posts.
+	thumb image.Image
+	// title is the title as displayed, after any cleaning.
+	title string
+}
+
+// fetchedPost is a post whose image has been downloaded but not decoded.
+// raw is nil for text posts, which have no image. url is the resolved
+// image URL raw was fetched from.
+type fetchedPost struct {
+	post Post
+	url  string
+	raw  *rawImage
+}
+
+// processPost downloads and decodes the image of post and returns its card.
+// Posts that cannot be shown are logged and counted, and the error is
+// returned along with any placeholder card; errDownloadBudget means no
+// further posts should be processed.
+func (s *session) processPost(ctx context.Context, post Post) (*postResult, error) {
+	fetched, err := s.fetchPost(ctx, post)
+	if err != nil {
+		return s.failedResult(post, err), err
+	}
+	result, err := s.finishPost(ctx, fetched)
+	if err != nil {
+		return s.failedResult(post, err), err
+	}
+	return result, nil
+}
+
+// fetchPost is the I/O-bound half of processPost: it resolves the post URL
+// and downloads the image body.
+func (s *session) fetchPost(ctx context.Context, post Post) (*fetchedPost, error) {
+	normalized, err := normalizeURL(post.URL)
+	if err != nil {
+		log.Printf("Skipping post: %s - %s. Error: %v", post.Title, post.URL, err)
+		s.stats.RecordFailure("bad-url")
+		return nil, err
+	}
+	post.URL = normalized
+	if s.opts.resume && s.saved.Has(post.URL) {
+		log.Printf("Skipping already downloaded image: %s", post.URL)
+		return nil, errAlreadySaved
+	}
+	if post.IsSelf && s.opts.includeText && s.win != nil {
+		return &fetchedPost{post: post}, nil
+	}
+	start := time.Now()
+	imageURL, err := s.resolvers.resolve(ctx, post.URL)
+	s.timings.Since(post.URL, "resolve", start)
+	if err != nil {
+		log.Printf("Skipping post: %s - %s. Error: %v", post.Title, post.URL, err)
+		if errors.Is(err, ErrUnsupportedHost) {
+			s.stats.RecordFailure("unsupported-host")
+		} else {
+			s.stats.RecordFailure("resolve")
+		}
+		return nil, err
+	}
+	if imageURL != post.URL {
+		log.Printf("Resolved: %s -> %s", post.URL, imageURL)
+	}
+
+	if !isValidImageURL(imageURL) {
+		log.Printf("Skipping non-image URL: %s", imageURL)
+		s.stats.RecordFailure("not-an-image")
+		return nil, errNotImage
+	}
+
+	if err := s.gate.Wait(ctx); err != nil {
+		return nil, err
+	}
+	start = time.Now()
+	raw, err := s.dl.fetchImageData(ctx, imageURL)
+	s.timings.Since(imageURL, "download", start)
+	if errors.Is(err, errDownloadBudget) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Skipping post: %s - %s. Error: %v", post.Title, post.URL, err)
+		s.stats.RecordFailure(failureReason(err))
+		return nil, err
+	}
+	return &fetchedPost{post: post, url: imageURL, raw: raw}, nil
+}
+
+// finishPost is the CPU-bound half of processPost: it decodes and resizes
+// the image, saves it when downloading, and builds the card.
+func (s *session) finishPost(ctx context.Context, f *fetchedPost) (*postResult, error) {
+	post := f.post
+	if f.raw == nil {
+		return s.finishTextPost(post), nil
+	}
+	start := time.Now()
+	downloaded, err := s.decode(ctx, f)
+	s.timings.Since(f.url, "decode", start)
+	if err != nil {
+		log.Printf("Skipping post: %s - %s. Error: %v", post.Title, post.URL, err)
+		s.stats.RecordFailure(failureReason(err))
+		return nil, err
+	}
+	if t := mediaType(post, downloaded.Format); !s.opts.types.allows(t) {
+		log.Printf("Skipping %s image: %s", t, f.url)
+		s.stats.RecordFailure("filtered")
+		return nil, errFiltered
+	}
+	if seen, ok, err := s.hashes.Claim(downloaded.FinalURL, dHash(downloaded.Image)); err != nil {
+		log.Printf("Failed to update hash index: %v", err)
+	} else if !ok {
+		log.Printf("Skipping duplicate of %s: %s", seen, f.url)
+		s.stats.RecordFailure("duplicate")
+		return nil, errDuplicate
+	}
+	s.stats.RecordFormat(downloaded.Format)
+	if downloaded.ICCProfile {
+		log.Printf("Warning: %s embeds an ICC colour profile, which is ignored; its colours may look wrong", f.url)
+	}
+
+	if s.opts.filter.active() {
+		downloaded.Image = s.opts.filter.apply(downloaded.Image)
+		if downloaded.GIF != nil {
+			s.opts.filter.applyGIF(downloaded.GIF)
+		}
+		downloaded.Modified = true
+	}
+
+	start = time.Now()
+	img := resizeImage(downloaded.Image, cardWidth)
+	s.timings.Since(f.url, "resize", start)
+
+	postTitle := post.Title
+	if s.opts.cleanTitles {
+		postTitle = cleanTitle(postTitle)
+	}
+
+	if s.opts.download {
+		start = time.Now()
+		s.save(post, downloaded, img, postTitle)
+		s.timings.Since(f.url, "save", start)
+	}
+
+	result := &postResult{thumb: img, title: postTitle}
+	if s.win != nil {
+		cardImg := img
+		if s.opts.noResize {
+			cardImg = downloaded.Image
+		}
+		b := downloaded.Image.Bounds()
+		info := imageInfoText(b.Dx(), b.Dy(), downloaded.Size)
+		if downloaded.ICCProfile {
+			info += " · ICC"
+		}
+		card, cover := newCard(post, postTitle, cardImg, info, s.opts.subredditBadges, s.opts.noResize, s.opts.fit, s.opts.title)
+		result.card = selectableCard(s.win, s.selection, card, cover, downloaded.FinalURL, postTitle, downloaded.Image)
+	}
+	return result, nil
+}
+
+// decode decodes the image of f. A body that downloads in full but fails to
+// decode is often a corrupt transfer, so on ErrDecode the image is fetched
+// and decoded once more before giving up. This is separate from the
+// download retries, which only cover transfers that visibly fail.
+func (s *session) decode(ctx context.Context, f *fetchedPost) (*downloadedImage, error) {
+	downloaded, err := s.decodes.decode(f.raw, s.opts.maxPixels)
+	if !errors.Is(err, ErrDecode) || ctx.Err() != nil {
+		return downloaded, err
+	}
+	log.Printf("Decoding %s failed, fetching it again: %v", f.url, err)
+	raw, err := s.dl.fetchImageData(ctx, f.url)
+	if err != nil {
+		return nil, err
+	}
+	return s.decodes.decode(raw, s.opts.maxPixels)
+}
+
+// finishTextPost builds the card for a self post shown with --include-text.
+func (s *session) finishTextPost(post Post) *postResult {
+	postTitle := post.Title
+	if s.opts.cleanTitles {
+		postTitle = cleanTitle(postTitle)
+	}
+	return &postResult{card: newTextCard(post, postTitle, s.opts.subredditBadges, s.opts.title, s.opts.markdown), title: postTitle}
+}
+
+// errNotImage, errAlreadySaved, errFiltered and errDuplicate report posts
+// that were skipped on purpose rather than because something failed.
+var (
+	errNotImage     = errors.New("not an image URL")
+	errAlreadySaved = errors.New("already downloaded")
+	errFiltered     = errors.New("media type filtered out")
+	errDuplicate    = errors.New("duplicate of an image already seen")
+)
+
+// save writes img through the session's saver and records it in the
+// manifest. When nothing has changed the image, the downloaded bytes are
+// saved as they are rather than re-encoded, which would lose JPEG quality.
+func (s *session) save(post Post, downloaded *downloadedImage, img image.Image, postTitle string) {
+	ext := urlExt(downloaded.FinalURL)
+	if downloaded.Format == "svg" {
+		// Saved as rasterized, since there is no SVG encoder.
+		ext = ".png"
+	}
+	fileName := s.opts.filenames.expand(postFilenameFields(post, postTitle, ext))
+	ext = path.Ext(fileName)
+
+	data := downloaded.Raw
+	if needsReencode(downloaded, img, ext) {
+		var buf bytes.Buffer
+		if err := encodeImage(&buf, img, downloaded.GIF, ext, s.opts.flatten); err != nil {
+			log.Printf("Failed to save image: %v", err)
+			return
+		}
+		data = buf.Bytes()
+	} else if s.opts.stripMetadata {
+		data = stripMetadata(data)
+	}
+	if err := s.saver.Save(fileName, img, data); err != nil {
+		log.Printf("Failed to save image: %v", err)
+		return
+	}
+	log.Printf("Saved image: %s", fileName)
+
+	entry := manifestEntry{URL: downloaded.FinalURL, Source: post.URL, File: fileName, Title: post.Title, Permalink: post.Permalink, SavedAt: time.Now()}
+	if m, ok := s.saver.(metadataSaver); ok {
+		if err := m.SaveMetadata(fileName, entry); err != nil {
+			log.Printf("Failed to save metadata: %v", err)
+		}
+	}
+
+	post.File = fileName
+	s.mu.Lock()
+	s.savedPosts = append(s.savedPosts, post)
+	s.mu.Unlock()
+
+	if s.saved != nil {
+		if err := s.saved.Record(entry); err != nil {
+			log.Printf("Failed to update manifest: %v", err)
+		}
+	}
+}
+
+// needsReencode reports whether img must be encoded afresh to be saved with
+// extension ext, rather than saving the downloaded bytes: because it was
+// filtered or resized, or because ext names a different format from the
+// one downloaded. Animated GIFs are saved from all their frames, which are
+// never resized, so only filtering changes them.
+func needsReencode(downloaded *downloadedImage, img image.Image, ext string) bool {
+	if downloaded.Modified || !extMatchesFormat(ext, downloaded.Format) {
+		return true
+	}
+	if downloaded.GIF != nil {
+		return false
+	}
+	return img != downloaded.Image
+}
+
+// extMatchesFormat reports whether the file extension ext is the usual one
+// for the decoder format name format.
+func extMatchesFormat(ext, format string) bool {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return format == "jpeg"
+	case ".png":
+		return format == "png"
+	case ".gif":
+		return format == "gif"
+	}
+	return false
+}
+
+// cardWidth is the width images are downscaled to for cards and the
+// contact sheet.
+const cardWidth = 400
+
+// maxNativeCardSize bounds cards shown with --no-resize; larger images are
+// scaled down to fit, keeping their aspect ratio.
+var maxNativeCardSize = fyne.NewSize(1920, 1080)
+
+// titleStyle is the size and weight of the titles on cards.
+type titleStyle struct {
+	Size float32
+	Bold bool
+}
+
+// defaultTitleStyle is used unless --title-font-size or --title-bold say
+// otherwise.
+var defaultTitleStyle = titleStyle{Size: 16, Bold: true}
+
+// minTitleFontSize and maxTitleFontSize bound --title-font-size to sizes
+// that are legible and still fit on a card.
+const (
+	minTitleFontSize float64 = 8
+	maxTitleFontSize float64 = 48
+)
+
+// newTitleText returns the title line of a card, drawn in style.
+func newTitleText(postTitle string, style titleStyle) *canvas.Text {
+	title := canvas.NewText(postTitle, theme.ForegroundColor())
+	title.TextStyle = fyne.TextStyle{Bold: style.Bold}
+	title.TextSize = style.Size
+	return title
+}
+
+// newCard builds the feed card for a post: optionally a subreddit badge and,
+// for a collapsed gallery, a count badge, its title in style, the image with
+// info overlaid in its bottom right corner and, for gallery images, the
+// caption. The image is drawn with fit; contain and stretch scale it to the
+// cell, so a downscaled thumbnail can be blown up and blurred in a large
+// grid cell. With native, img is shown at its own resolution up to
+// maxNativeCardSize. A spoiler post's image stays blurred until revealed;
+// the cover returned, nil for other posts, tells whether it has been.
+func newCard(post Post, postTitle string, img image.Image, info string, badge, native bool, fit canvas.ImageFill, style titleStyle) (fyne.CanvasObject, *spoilerCover) {
+	image := canvas.NewImageFromImage(img)
+	image.FillMode = fit
+	if fit != canvas.ImageFillOriginal {
+		// Scaled modes have no minimum size of their own.
+		b := img.Bounds()
+		image.SetMinSize(fyne.NewSize(float32(b.Dx()), float32(b.Dy())))
+	}
+	if native {
+		if fit == canvas.ImageFillOriginal {
+			image.FillMode = canvas.ImageFillContain
+		}
+		image.SetMinSize(nativeCardSize(img.Bounds()))
+	}
+
+	var picture fyne.CanvasObject = image
+	var cover *spoilerCover
+	if post.Spoiler {
+		picture, cover = newSpoilerImage(image, img)
+	}
+
+	title := newTitleText(postTitle, style)
+
+	card := container.NewVBox()
+	var badges []fyne.CanvasObject
+	if badge && post.Subreddit != "" {
+		badges = append(badges, newSubredditBadge(post.Subreddit))
+	}
+	if post.GallerySize > 1 {
+		badges = append(badges, newGalleryBadge(post.GallerySize))
+	}
+	if len(badges) > 0 {
+		card.Add(container.NewHBox(badges...))
+	}
+	card.Add(title)
+	if info != "" {
+		card.Add(container.NewStack(picture, newInfoOverlay(info)))
+	} else {
+		card.Add(picture)
+	}
+	if post.Caption != "" && post.Caption != post.Title {
+		caption := canvas.NewText(post.Caption, theme.ForegroundColor())
+		caption.TextStyle = fyne.TextStyle{Italic: true}
+		card.Add(caption)
+	}
+	return card, cover
+}
+
+// nativeCardSize returns the size of an image with bounds b, scaled down if
+// needed to fit within maxNativeCardSize.
+func nativeCardSize(b image.Rectangle) fyne.Size {
+	size := fyne.NewSize(float32(b.Dx()), float32(b.Dy()))
+	scale := min(1, maxNativeCardSize.Width/size.Width, maxNativeCardSize.Height/size.Height)
+	return fyne.NewSize(size.Width*scale, size.Height*scale)
+}
+
+// maxSelftextRunes is how much of a self post's body a text card shows.
+const maxSelftextRunes = 1000
+
+// newTextCard builds the feed card for a self post: optionally a subreddit
+// badge, its title in style and the start of its body, wrapped to the card
+// width. With markdown the body is rendered as Reddit markdown rather than
+// shown as plain text.
+func newTextCard(post Post, postTitle string, badge bool, style titleStyle, markdown bool) fyne.CanvasObject {
+	title := newTitleText(postTitle, style)
+
+	card := container.NewVBox()
+	if badge && post.Subreddit != "" {
+		card.Add(container.NewHBox(newSubredditBadge(post.Subreddit)))
+	}
+	card.Add(title)
+
+	body := []rune(strings.TrimSpace(post.Selftext))
+	if len(body) > 0 {
+		text := string(body)
+		if len(body) > maxSelftextRunes {
+			text = string(body[:maxSelftextRunes]) + "…"
+		}
+		if markdown {
+			// The markdown parser accepts any input, at worst showing
+			// malformed syntax literally, so there is no error to fall
+			// back from.
+			rich := widget.NewRichTextFromMarkdown(text)
+			rich.Wrapping = fyne.TextWrapWord
+			card.Add(rich)
+		} else {
+			label := widget.NewLabel(text)
+			label.Wrapping = fyne.TextWrapWord
+			card.Add(label)
+		}
+	}
+	return card
+}
+
+// imageInfoText describes an image for its card overlay, e.g.
+// "1920x1080 · 2.3 MB".
+func imageInfoText(width, height int, size int64) string {
+	return fmt.Sprintf("%dx%d · %s", width, height, formatBytes(size))
+}
+
+// formatBytes formats n in decimal units with one decimal place above a
+// kilobyte, e.g. "512 B", "48.1 KB" or "2.3 MB".
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, next := range []string{"MB", "GB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
+
+// newInfoOverlay returns text on a translucent dark label, placed in the
+// bottom right corner of the space it is given.
+func newInfoOverlay(text string) fyne.CanvasObject {
+	bg := canvas.NewRectangle(color.NRGBA{A: 0xa0})
+	bg.CornerRadius = 4
+
+	label := canvas.NewText(text, color.White)
+	label.TextSize = 11
+
+	tag := container.NewStack(bg, container.NewPadded(label))
+	return container.NewVBox(layout.NewSpacer(), container.NewHBox(layout.NewSpacer(), tag))
+}
+
+// newSubredditBadge returns a small "r/name" label on a rounded rectangle in
+// the subreddit's colour.
+func newSubredditBadge(name string) fyne.CanvasObject {
+	bg := canvas.NewRectangle(colorForSubreddit(name))
+	bg.CornerRadius = 4
+
+	label := canvas.NewText("r/"+name, color.White)
+	label.TextSize = 11
+	label.TextStyle = fyne.TextStyle{Bold: true}
+
+	return container.NewStack(bg, container.NewPadded(label))
+}
+
+// newGalleryBadge returns a small "n images" label for a gallery collapsed
+// to its first image.
+func newGalleryBadge(n int) fyne.CanvasObject {
+	bg := canvas.NewRectangle(color.NRGBA{0x40, 0x40, 0x40, 0xff})
+	bg.CornerRadius = 4
+
+	label := canvas.NewText(fmt.Sprintf("%d images", n), color.White)
+	label.TextSize = 11
+	label.TextStyle = fyne.TextStyle{Bold: true}
+
+	return container.NewStack(bg, container.NewPadded(label))
+}
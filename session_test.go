@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newMockWindowSession is newMockSession building cards for a window of
+// a test app, styled as the flag defaults would.
+func newMockWindowSession(t *testing.T, m *mockReddit) *session {
+	app := test.NewApp()
+	t.Cleanup(app.Quit)
+	s := newMockSession(m)
+	s.opts.fit = canvas.ImageFillOriginal
+	s.opts.title = defaultTitleStyle
+	s.win = app.NewWindow("feed")
+	s.selection = &cardSelection{}
+	return s
+}
+
+// findObjects returns the objects of type T in the tree below o, walking
+// containers and widget renderers.
+func findObjects[T fyne.CanvasObject](o fyne.CanvasObject) []T {
+	var found []T
+	if t, ok := o.(T); ok {
+		found = append(found, t)
+	}
+	var children []fyne.CanvasObject
+	switch o := o.(type) {
+	case *fyne.Container:
+		children = o.Objects
+	case fyne.Widget:
+		children = test.WidgetRenderer(o).Objects()
+	}
+	for _, child := range children {
+		found = append(found, findObjects[T](child)...)
+	}
+	return found
+}
+
+func TestIncludeTextMakesTextCards(t *testing.T) {
+	m := newMockReddit(t)
+	posts := []Post{
+		{ID: "t1", Title: "A question", IsSelf: true, Selftext: "What lens was this shot with?", URL: m.URL + "/r/pics/comments/t1/a_question/"},
+		{ID: "i1", Title: "A photo", URL: m.addImage("photo.png", pngBytes(t, 8, 8, color.White))},
+	}
+
+	s := newMockWindowSession(t, m)
+	s.opts.includeText = true
+	results, _ := s.processPosts(context.Background(), posts, 1, 1)
+
+	text, image := results[0], results[1]
+	if text == nil || text.thumb != nil {
+		t.Fatalf("text post result = %+v, want a card without an image", text)
+	}
+	labels := findObjects[*widget.Label](text.card)
+	if len(labels) != 1 || labels[0].Text != posts[0].Selftext {
+		t.Errorf("text card labels = %v, want the post body", labels)
+	}
+	if got := findObjects[*canvas.Image](text.card); len(got) != 0 {
+		t.Errorf("text card has %d images", len(got))
+	}
+	if image == nil || image.thumb == nil || len(findObjects[*canvas.Image](image.card)) != 1 {
+		t.Errorf("image post result = %+v, want an image card", image)
+	}
+
+	// Without --include-text the text post is skipped as before.
+	s = newMockWindowSession(t, m)
+	results, _ = s.processPosts(context.Background(), posts, 1, 1)
+	if results[0] != nil || results[1] == nil {
+		t.Errorf("without --include-text: results = %v, want only the image", results)
+	}
+}
+
+func TestNoResizeShowsNativeImage(t *testing.T) {
+	m := newMockReddit(t)
+	posts := []Post{{ID: "big", Title: "Big", URL: m.addImage("big.png", pngBytes(t, 1000, 800, color.White))}}
+
+	for _, noResize := range []bool{false, true} {
+		s := newMockWindowSession(t, m)
+		s.opts.noResize = noResize
+		results, _ := s.processPosts(context.Background(), posts, 1, 1)
+		if results[0] == nil {
+			t.Fatalf("noResize %v: post failed", noResize)
+		}
+		images := findObjects[*canvas.Image](results[0].card)
+		if len(images) != 1 {
+			t.Fatalf("noResize %v: card has %d images, want 1", noResize, len(images))
+		}
+		shown := images[0].Image.Bounds().Dx()
+		want := cardWidth
+		if noResize {
+			want = 1000
+			if images[0].FillMode != canvas.ImageFillContain {
+				t.Errorf("native image fill mode = %v, want contain", images[0].FillMode)
+			}
+		}
+		if shown != want {
+			t.Errorf("noResize %v: card image is %dpx wide, want %d", noResize, shown, want)
+		}
+		// The thumbnail, used for the contact sheet, is resized either way.
+		if got := results[0].thumb.Bounds().Dx(); got != cardWidth {
+			t.Errorf("noResize %v: thumbnail is %dpx wide, want %d", noResize, got, cardWidth)
+		}
+	}
+}
+
+func TestDecodeFailureRefetchesOnce(t *testing.T) {
+	m := newMockReddit(t)
+	good := pngBytes(t, 8, 8, color.White)
+	var fetches atomic.Int32
+	flaky := m.handle("/img/flaky.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		if fetches.Add(1) == 1 {
+			// A complete response whose body got mangled on the way.
+			w.Write(append(good[:16:16], bytes.Repeat([]byte{0}, len(good)-16)...))
+			return
+		}
+		w.Write(good)
+	})
+	var brokenFetches atomic.Int32
+	broken := m.handle("/img/broken.png", func(w http.ResponseWriter, r *http.Request) {
+		brokenFetches.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n not an image"))
+	})
+
+	s := newMockSession(m)
+	results, _ := s.processPosts(context.Background(), []Post{{ID: "a", URL: flaky}, {ID: "b", URL: broken}}, 1, 1)
+	if results[0] == nil {
+		t.Error("the image that decoded on the second fetch failed")
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("flaky image fetched %d times, want 2", n)
+	}
+	if results[1] != nil {
+		t.Error("an image that never decodes succeeded")
+	}
+	if n := brokenFetches.Load(); n != 2 {
+		t.Errorf("broken image fetched %d times, want 2 (one refetch only)", n)
+	}
+	if got := s.stats.failures["decode"]; got != 1 {
+		t.Errorf("decode failures = %d, want 1", got)
+	}
+}
+
+func TestCardImageUsesFitMode(t *testing.T) {
+	m := newMockReddit(t)
+	posts := []Post{{ID: "cat", Title: "Cat", URL: m.addImage("cat.png", pngBytes(t, 64, 48, color.White))}}
+
+	for _, fit := range []canvas.ImageFill{canvas.ImageFillOriginal, canvas.ImageFillContain, canvas.ImageFillStretch} {
+		s := newMockWindowSession(t, m)
+		s.opts.fit = fit
+		results, _ := s.processPosts(context.Background(), posts, 1, 1)
+		if results[0] == nil {
+			t.Fatalf("fit %v: post failed", fit)
+		}
+		images := findObjects[*canvas.Image](results[0].card)
+		if len(images) != 1 {
+			t.Fatalf("fit %v: card has %d images, want 1", fit, len(images))
+		}
+		if images[0].FillMode != fit {
+			t.Errorf("card image fill mode = %v, want %v", images[0].FillMode, fit)
+		}
+	}
+}
+
+func TestImageInfoText(t *testing.T) {
+	tests := []struct {
+		width, height int
+		size          int64
+		want          string
+	}{
+		{1920, 1080, 2_300_000, "1920x1080 · 2.3 MB"},
+		{640, 480, 48_123, "640x480 · 48.1 KB"},
+		{16, 16, 512, "16x16 · 512 B"},
+		{16, 16, 999, "16x16 · 999 B"},
+		{100, 100, 1000, "100x100 · 1.0 KB"},
+		{8000, 6000, 1_260_000_000, "8000x6000 · 1.3 GB"},
+	}
+	for _, tt := range tests {
+		if got := imageInfoText(tt.width, tt.height, tt.size); got != tt.want {
+			t.Errorf("imageInfoText(%d, %d, %d) = %q, want %q", tt.width, tt.height, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestCardShowsImageInfo(t *testing.T) {
+	m := newMockReddit(t)
+	data := pngBytes(t, 640, 480, color.White)
+	posts := []Post{{ID: "cat", Title: "Cat", URL: m.addImage("cat.png", data)}}
+
+	s := newMockWindowSession(t, m)
+	results, _ := s.processPosts(context.Background(), posts, 1, 1)
+	if results[0] == nil {
+		t.Fatal("post failed")
+	}
+	want := imageInfoText(640, 480, int64(len(data)))
+	for _, text := range findObjects[*canvas.Text](results[0].card) {
+		if text.Text == want {
+			return
+		}
+	}
+	t.Errorf("card has no %q overlay", want)
+}
+
+func TestTitleStyleReachesCards(t *testing.T) {
+	t.Cleanup(test.NewApp().Quit)
+	style := titleStyle{Size: 24, Bold: false}
+	post := Post{ID: "a", Title: "A cat", Selftext: "Just text"}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	imageCard, _ := newCard(post, "A cat", img, "", false, false, canvas.ImageFillOriginal, style)
+	textCard := newTextCard(post, "A cat", false, style, false)
+	for name, card := range map[string]fyne.CanvasObject{"image card": imageCard, "text card": textCard} {
+		var title *canvas.Text
+		for _, text := range findObjects[*canvas.Text](card) {
+			if text.Text == "A cat" {
+				title = text
+			}
+		}
+		if title == nil {
+			t.Errorf("%s has no title", name)
+			continue
+		}
+		if title.TextSize != 24 || title.TextStyle.Bold {
+			t.Errorf("%s title is size %g bold %v, want size 24 not bold", name, title.TextSize, title.TextStyle.Bold)
+		}
+	}
+}
+
+func TestTitleFontSizeIsValidated(t *testing.T) {
+	m := newMockReddit(t)
+	r := newMockRunner(t, m, "show", "--subreddit=pics", "--title-font-size=20", "--title-bold=false")
+	defer r.close()
+	if want := (titleStyle{Size: 20, Bold: false}); r.title != want {
+		t.Errorf("title style = %+v, want %+v", r.title, want)
+	}
+
+	for _, size := range []string{"4", "100"} {
+		cfg, err := parseCommand([]string{"show", "--subreddit=pics", "--title-font-size=" + size})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newRunner(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "--title-font-size") {
+			t.Errorf("--title-font-size=%s: err = %v, want it rejected", size, err)
+		}
+	}
+}
+
+func TestTextCardRendersMarkdown(t *testing.T) {
+	t.Cleanup(test.NewApp().Quit)
+	post := Post{ID: "s", Title: "Notes", IsSelf: true, Selftext: "# Heading\n\nSome **bold** and *italic*, a [link](https://example.com/x).\n\n- one\n- two"}
+
+	card := newTextCard(post, "Notes", false, defaultTitleStyle, true)
+	// The first rich text found is the body; any others are inside it.
+	rich := findObjects[*widget.RichText](card)
+	if len(rich) == 0 || len(findObjects[*widget.Label](card)) != 0 {
+		t.Fatal("markdown card does not show its body as rich text")
+	}
+	var heading, bold, italic bool
+	var link string
+	var items int
+	for _, seg := range rich[0].Segments {
+		switch seg := seg.(type) {
+		case *widget.TextSegment:
+			switch {
+			case seg.Text == "Heading" && seg.Style == widget.RichTextStyleHeading:
+				heading = true
+			case seg.Text == "bold" && seg.Style.TextStyle.Bold:
+				bold = true
+			case seg.Text == "italic" && seg.Style.TextStyle.Italic:
+				italic = true
+			}
+		case *widget.HyperlinkSegment:
+			if seg.Text == "link" && seg.URL != nil {
+				link = seg.URL.String()
+			}
+		case *widget.ListSegment:
+			items = len(seg.Items)
+		}
+	}
+	if !heading || !bold || !italic {
+		t.Errorf("segments heading %v, bold %v, italic %v, want all", heading, bold, italic)
+	}
+	if link != "https://example.com/x" {
+		t.Errorf("link segment points at %q, want https://example.com/x", link)
+	}
+	if items != 2 {
+		t.Errorf("list has %d items, want 2", items)
+	}
+
+	// Without --markdown the body is shown as it is.
+	plain := newTextCard(post, "Notes", false, defaultTitleStyle, false)
+	labels := findObjects[*widget.Label](plain)
+	if len(labels) != 1 || labels[0].Text != post.Selftext {
+		t.Errorf("plain card does not show the markdown source in a label")
+	}
+}
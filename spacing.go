@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// requestSpacer enforces a minimum delay between the starts of successive
+// downloads, shared by all workers. A nil requestSpacer never waits.
+type requestSpacer struct {
+	delay time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newRequestSpacer returns a spacer for --delay, or nil when delay is zero
+// or less.
+func newRequestSpacer(delay time.Duration) *requestSpacer {
+	if delay <= 0 {
+		return nil
+	}
+	return &requestSpacer{delay: delay}
+}
+
+// wait blocks until the caller may start a download, at least delay after
+// the previous one started. Each caller reserves its slot before sleeping,
+// so concurrent callers are spaced out rather than released together. It
+// fails only if ctx is done first.
+func (s *requestSpacer) wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	start := time.Now()
+	if s.next.After(start) {
+		start = s.next
+	}
+	s.next = start.Add(s.delay)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDelaySpacesDownloadsAcrossWorkers(t *testing.T) {
+	const delay = 30 * time.Millisecond
+
+	m := newMockReddit(t)
+	data := pngBytes(t, 8, 8, color.White)
+	var mu sync.Mutex
+	var starts []time.Time
+	m.handle("/img/timed.png", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		w.Write(data)
+	})
+	var posts []Post
+	for i := range 5 {
+		posts = append(posts, Post{ID: fmt.Sprint(i), URL: fmt.Sprintf("%s/img/timed.png?n=%d", m.URL, i)})
+	}
+
+	s := newMockSession(m)
+	s.dl.spacer = newRequestSpacer(delay)
+	begin := time.Now()
+	s.processPosts(context.Background(), posts, 4, 4)
+
+	if len(starts) != len(posts) {
+		t.Fatalf("%d downloads, want %d", len(starts), len(posts))
+	}
+	// Requests reach the server some unknown time after their slot, so
+	// only the earliest each can arrive is checked: the nth no sooner
+	// than n delays after the first could start.
+	slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+	for i, start := range starts {
+		if since := start.Sub(begin); since < time.Duration(i)*delay {
+			t.Errorf("download %d started %s in, want at least %s", i, since, time.Duration(i)*delay)
+		}
+	}
+}
+
+func TestRequestSpacerSpacesConcurrentWaits(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	s := newRequestSpacer(delay)
+	begin := time.Now()
+	var mu sync.Mutex
+	var returned []time.Time
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			returned = append(returned, time.Now())
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(returned, func(a, b time.Time) int { return a.Compare(b) })
+	for i, at := range returned {
+		if since := at.Sub(begin); since < time.Duration(i)*delay {
+			t.Errorf("wait %d returned %s in, want at least %s", i, since, time.Duration(i)*delay)
+		}
+	}
+}
+
+func TestRequestSpacerCancelled(t *testing.T) {
+	s := newRequestSpacer(time.Hour)
+	if err := s.wait(context.Background()); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait after cancel = %v, want context.Canceled", err)
+	}
+	if err := newRequestSpacer(0).wait(context.Background()); err != nil {
+		t.Errorf("nil spacer: %v", err)
+	}
+}
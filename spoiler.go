@@ -0,0 +1,116 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// spoilerBlurFraction makes the blur radius of a spoiler image this
+// fraction (1/n) of its longer side, so a card shown at native resolution
+// is hidden as well as a downscaled one.
+const spoilerBlurFraction = 40
+
+// spoilerBlurRadius returns the blur radius for a spoiler image with
+// bounds b.
+func spoilerBlurRadius(b image.Rectangle) int {
+	return max(1, max(b.Dx(), b.Dy())/spoilerBlurFraction)
+}
+
+// blurImage returns img blurred with a box blur of the given radius, run
+// horizontally and then vertically over premultiplied pixels so
+// transparent edges do not bleed dark. Pixels past the border repeat the
+// edge. A radius below 1 returns img unchanged.
+func blurImage(img image.Image, radius int) image.Image {
+	if radius < 1 {
+		return img
+	}
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	tmp := image.NewRGBA(src.Bounds())
+	w, h := b.Dx(), b.Dy()
+	boxBlurLine(src.Pix, tmp.Pix, w, h, 4, src.Stride, radius)
+	boxBlurLine(tmp.Pix, src.Pix, h, w, src.Stride, 4, radius)
+	return src
+}
+
+// boxBlurLine blurs n lines of length pixels each from src into dst. step
+// is the byte distance between neighbouring pixels of a line and next the
+// distance between the starts of neighbouring lines, so one function
+// covers both the horizontal and the vertical pass.
+func boxBlurLine(src, dst []uint8, length, n, step, next, radius int) {
+	window := 2*radius + 1
+	for line := range n {
+		base := line * next
+		at := func(i int) int {
+			return base + min(max(i, 0), length-1)*step
+		}
+		var sum [4]int
+		for i := -radius; i <= radius; i++ {
+			p := at(i)
+			for c := range sum {
+				sum[c] += int(src[p+c])
+			}
+		}
+		for i := range length {
+			p := base + i*step
+			for c := range sum {
+				dst[p+c] = uint8(sum[c] / window)
+			}
+			out, in := at(i-radius), at(i+radius+1)
+			for c := range sum {
+				sum[c] += int(src[in+c]) - int(src[out+c])
+			}
+		}
+	}
+}
+
+// spoilerCover tracks whether a spoiler card has been revealed. It starts
+// covered, showing the blurred image, and reveal swaps in the sharp one
+// once; there is no way to cover the card again.
+type spoilerCover struct {
+	image    *canvas.Image
+	sharp    image.Image
+	revealed bool
+	// onReveal is called after the first reveal, to hide the cover.
+	onReveal func()
+}
+
+// covered reports whether c still hides its image. A nil cover, that of a
+// card that is not a spoiler, hides nothing.
+func (c *spoilerCover) covered() bool {
+	return c != nil && !c.revealed
+}
+
+// reveal shows the sharp image, reporting whether the card was still
+// covered.
+func (c *spoilerCover) reveal() bool {
+	if c.revealed {
+		return false
+	}
+	c.revealed = true
+	c.image.Image = c.sharp
+	c.image.Refresh()
+	if c.onReveal != nil {
+		c.onReveal()
+	}
+	return true
+}
+
+// newSpoilerImage shows sharp in pic blurred, with a "Show spoiler" button
+// over it that reveals sharp when clicked. The cover returned tells whether
+// it has been.
+func newSpoilerImage(pic *canvas.Image, sharp image.Image) (fyne.CanvasObject, *spoilerCover) {
+	pic.Image = blurImage(sharp, spoilerBlurRadius(sharp.Bounds()))
+	cover := &spoilerCover{image: pic, sharp: sharp}
+
+	overlay := container.NewCenter(widget.NewButton("Show spoiler", func() { cover.reveal() }))
+	cover.onReveal = overlay.Hide
+	return container.NewStack(pic, overlay), cover
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+func TestBlurImage(t *testing.T) {
+	// Black on the left half, white on the right.
+	src := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(src, image.Rect(10, 0, 20, 10), image.White, image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(0, 0, 10, 10), image.Black, image.Point{}, draw.Src)
+
+	blurred := blurImage(src, 3)
+	if blurred.Bounds() != src.Bounds() {
+		t.Fatalf("blurred bounds %v, want %v", blurred.Bounds(), src.Bounds())
+	}
+	// Pixels by the edge are mixed; those far from it are not.
+	for _, x := range []int{8, 9, 10, 11} {
+		if r, _, _, _ := blurred.At(x, 5).RGBA(); r == 0 || r == 0xffff {
+			t.Errorf("pixel %d by the edge = %d, want grey", x, r>>8)
+		}
+	}
+	if r, _, _, _ := blurred.At(0, 5).RGBA(); r != 0 {
+		t.Errorf("left edge = %d, want black", r>>8)
+	}
+	if r, _, _, _ := blurred.At(19, 5).RGBA(); r != 0xffff {
+		t.Errorf("right edge = %d, want white", r>>8)
+	}
+
+	if blurImage(src, 0) != image.Image(src) {
+		t.Error("radius 0 did not return the image as is")
+	}
+}
+
+func TestBlurImageKeepsTransparentEdgesLight(t *testing.T) {
+	// White on the left half, transparent on the right.
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(src, image.Rect(0, 0, 10, 10), image.White, image.Point{}, draw.Src)
+
+	c := color.NRGBAModel.Convert(blurImage(src, 3).At(10, 5)).(color.NRGBA)
+	if c.A == 0 || c.A == 0xff {
+		t.Fatalf("pixel by the edge has alpha %d, want partial", c.A)
+	}
+	if c.R < 0xfe || c.G < 0xfe || c.B < 0xfe {
+		t.Errorf("pixel by the edge = %v, want white", c)
+	}
+}
+
+func TestSpoilerCoverReveal(t *testing.T) {
+	sharp := solidImage(40, 20, color.White)
+	pic := canvas.NewImageFromImage(nil)
+	obj, cover := newSpoilerImage(pic, sharp)
+
+	if pic.Image == image.Image(sharp) {
+		t.Fatal("spoiler shown sharp before it was revealed")
+	}
+	if !cover.covered() {
+		t.Fatal("new spoiler is not covered")
+	}
+	buttons := findObjects[*widget.Button](obj)
+	if len(buttons) != 1 || buttons[0].Text != "Show spoiler" {
+		t.Fatalf("buttons = %v, want one Show spoiler", buttons)
+	}
+
+	test.Tap(buttons[0])
+	if cover.covered() {
+		t.Error("spoiler still covered after Show spoiler")
+	}
+	if pic.Image != image.Image(sharp) {
+		t.Error("revealed spoiler is not shown sharp")
+	}
+	if overlay := obj.(*fyne.Container).Objects[1]; overlay.Visible() {
+		t.Error("Show spoiler still shown after revealing")
+	}
+	if cover.reveal() {
+		t.Error("second reveal reported the spoiler as covered")
+	}
+
+	var none *spoilerCover
+	if none.covered() {
+		t.Error("a card without a spoiler cover is covered")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// runStats tallies what happened to each post over a run for the final
+// report.
+type runStats struct {
+	mu       sync.Mutex
+	formats  map[string]int
+	failures map[string]int
+}
+
+func newRunStats() *runStats {
+	return &runStats{formats: make(map[string]int), failures: make(map[string]int)}
+}
+
+// RecordFormat counts a successfully decoded image of the given format.
+func (s *runStats) RecordFormat(format string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.formats[format]++
+}
+
+// RecordFailure counts a post that could not be shown under reason. Use
+// failureReason to derive a reason from an error.
+func (s *runStats) RecordFailure(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[reason]++
+}
+
+// Decoded returns the number of images decoded successfully.
+func (s *runStats) Decoded() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, c := range s.formats {
+		n += c
+	}
+	return n
+}
+
+// Failed returns the number of posts that failed, not counting those
+// skipped for not linking to an image, for being on an unsupported host, by
+// the media type filter or as duplicates.
+func (s *runStats) Failed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for reason, c := range s.failures {
+		if reason != "not-an-image" && reason != "unsupported-host" && reason != "filtered" && reason != "duplicate" {
+			n += c
+		}
+	}
+	return n
+}
+
+// failureReason classifies a download error for the report.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, errNotImage):
+		return "not-an-image"
+	case errors.Is(err, ErrTruncated):
+		return "truncated"
+	case errors.Is(err, ErrEmptyResponse):
+		return "empty"
+	case errors.Is(err, ErrDecode):
+		return "decode"
+	case errors.Is(err, ErrTooLarge):
+		return "too-large"
+	case errors.Is(err, ErrNotFound):
+		return "not-found"
+	case errors.Is(err, ErrRateLimited):
+		return "rate-limited"
+	case errors.Is(err, ErrHostNotAllowed):
+		return "host-not-allowed"
+	default:
+		return "download"
+	}
+}
+
+// WriteReport prints the format and failure tallies to w, most common first.
+func (s *runStats) WriteReport(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintln(w, "Decoded formats:")
+	writeTally(w, s.formats)
+	fmt.Fprintln(w, "Failures:")
+	writeTally(w, s.failures)
+}
+
+func writeTally(w io.Writer, tally map[string]int) {
+	if len(tally) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+
+	keys := make([]string, 0, len(tally))
+	for k := range tally {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if tally[keys[i]] != tally[keys[j]] {
+			return tally[keys[i]] > tally[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %-12s %d\n", k, tally[k])
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"maps"
+	"testing"
+)
+
+// addMixedImages serves the images the r_mixed fixture links to: two
+// PNGs, a JPEG and a GIF that decode, and a JPEG that does not.
+func addMixedImages(t *testing.T, m *mockReddit) {
+	m.addImage("a.png", pngBytes(t, 8, 8, color.White))
+	m.addImage("b.png", pngBytes(t, 8, 8, color.Black))
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, solidImage(8, 8, color.Gray{0x80}), nil); err != nil {
+		t.Fatal(err)
+	}
+	m.addImage("c.jpg", jpg.Bytes())
+	var anim bytes.Buffer
+	if err := gif.Encode(&anim, image.NewPaletted(image.Rect(0, 0, 8, 8), palette.Plan9), nil); err != nil {
+		t.Fatal(err)
+	}
+	m.addImage("d.gif", anim.Bytes())
+	m.addImage("e.jpg", []byte("\xff\xd8 not really a JPEG"))
+}
+
+func TestRunStatsTalliesMixedInputs(t *testing.T) {
+	m := newMockReddit(t, "r_mixed.json")
+	addMixedImages(t, m)
+
+	r := newMockRunner(t, m, "show", "--subreddit=mixed")
+	defer r.close()
+	posts, err := r.fetchInitial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := r.newSession(nil)
+	r.process(sess, posts)
+
+	wantFormats := map[string]int{"png": 2, "jpeg": 1, "gif": 1}
+	if !maps.Equal(sess.stats.formats, wantFormats) {
+		t.Errorf("formats = %v, want %v", sess.stats.formats, wantFormats)
+	}
+	wantFailures := map[string]int{"decode": 1, "not-found": 1, "not-an-image": 1}
+	if !maps.Equal(sess.stats.failures, wantFailures) {
+		t.Errorf("failures = %v, want %v", sess.stats.failures, wantFailures)
+	}
+	if got := sess.stats.Decoded(); got != 4 {
+		t.Errorf("Decoded() = %d, want 4", got)
+	}
+	// A link that is not an image is skipped, not failed.
+	if got := sess.stats.Failed(); got != 2 {
+		t.Errorf("Failed() = %d, want 2", got)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// feedStatus is what the status bar reports about a run.
+type feedStatus struct {
+	// Subreddit is the subreddit of the post that last entered the
+	// pipeline.
+	Subreddit string
+	// Total counts the posts that entered the pipeline, Loaded those
+	// shown and Failed those skipped or failed.
+	Total, Loaded, Failed int
+	// Active reports whether downloads are in progress.
+	Active bool
+}
+
+// statusText formats s for the status bar, e.g.
+// "r/pics · 12/25 loaded · 2 skipped · downloading".
+func statusText(s feedStatus) string {
+	text := fmt.Sprintf("%d/%d loaded", s.Loaded, s.Total)
+	if s.Subreddit != "" {
+		text = "r/" + s.Subreddit + " · " + text
+	}
+	if s.Failed > 0 {
+		text += fmt.Sprintf(" · %d skipped", s.Failed)
+	}
+	if s.Active {
+		text += " · downloading"
+	}
+	return text
+}
+
+// statusBar is the line along the bottom of the window showing the run's
+// progress, with a spinner while downloads are active.
+type statusBar struct {
+	label   *widget.Label
+	spinner *widget.ProgressBarInfinite
+	status  feedStatus
+}
+
+func newStatusBar() *statusBar {
+	b := &statusBar{label: widget.NewLabel(""), spinner: widget.NewProgressBarInfinite()}
+	b.update()
+	return b
+}
+
+// Object returns the bar's widget tree, for laying out in the window.
+func (b *statusBar) Object() fyne.CanvasObject {
+	return container.NewBorder(nil, nil, nil, b.spinner, b.label)
+}
+
+// run updates the bar from session events. It never returns, so the
+// session's sends never block; start it on its own goroutine.
+func (b *statusBar) run(events <-chan Event) {
+	for e := range events {
+		switch e.Kind {
+		case PostFetched:
+			b.status.Total++
+			if e.Post.Subreddit != "" {
+				b.status.Subreddit = e.Post.Subreddit
+			}
+		case ImageDownloaded:
+			b.status.Loaded++
+		case ImageFailed:
+			b.status.Failed++
+		}
+		b.status.Active = e.Kind != Done && b.status.Loaded+b.status.Failed < b.status.Total
+		b.update()
+	}
+}
+
+func (b *statusBar) update() {
+	b.label.SetText(statusText(b.status))
+	if b.status.Active {
+		b.spinner.Show()
+		b.spinner.Start()
+	} else {
+		b.spinner.Stop()
+		b.spinner.Hide()
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestStatusText(t *testing.T) {
+	tests := []struct {
+		status feedStatus
+		want   string
+	}{
+		{feedStatus{}, "0/0 loaded"},
+		{feedStatus{Subreddit: "pics", Total: 25, Loaded: 12, Active: true}, "r/pics · 12/25 loaded · downloading"},
+		{feedStatus{Subreddit: "pics", Total: 25, Loaded: 12, Failed: 2, Active: true}, "r/pics · 12/25 loaded · 2 skipped · downloading"},
+		{feedStatus{Subreddit: "earthporn", Total: 10, Loaded: 7, Failed: 3}, "r/earthporn · 7/10 loaded · 3 skipped"},
+		{feedStatus{Total: 3, Loaded: 3}, "3/3 loaded"},
+	}
+	for _, tt := range tests {
+		if got := statusText(tt.status); got != tt.want {
+			t.Errorf("statusText(%+v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestStatusBarFollowsEvents(t *testing.T) {
+	t.Cleanup(test.NewApp().Quit)
+	b := newStatusBar()
+	if b.spinner.Visible() {
+		t.Error("spinner shown before any download")
+	}
+
+	events := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.run(events)
+	}()
+	events <- Event{Kind: PostFetched, Post: Post{Subreddit: "pics"}}
+	events <- Event{Kind: PostFetched, Post: Post{Subreddit: "aww"}}
+	events <- Event{Kind: ImageDownloaded}
+	events <- Event{Kind: ImageFailed, Err: errors.New("gone")}
+	events <- Event{Kind: PostFetched, Post: Post{Subreddit: "aww"}}
+	events <- Event{Kind: Done}
+	close(events)
+	<-done
+
+	if got, want := b.label.Text, "r/aww · 1/3 loaded · 1 skipped"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+	if b.spinner.Visible() {
+		t.Error("spinner still shown after Done")
+	}
+}
+
+func TestStatusBarSpinsWhileDownloading(t *testing.T) {
+	t.Cleanup(test.NewApp().Quit)
+	b := newStatusBar()
+	events := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.run(events)
+	}()
+	events <- Event{Kind: PostFetched, Post: Post{Subreddit: "pics"}}
+	events <- Event{Kind: PostFetched, Post: Post{Subreddit: "pics"}}
+	events <- Event{Kind: ImageDownloaded}
+	close(events)
+	<-done
+
+	if got, want := b.label.Text, "r/pics · 1/2 loaded · downloading"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+	if !b.spinner.Visible() {
+		t.Error("spinner hidden while a download is outstanding")
+	}
+	b.spinner.Stop()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// stripMetadata returns data, a downloaded JPEG or PNG, without the
+// metadata that can identify where or by whom a photo was taken: EXIF
+// (including GPS), XMP, IPTC and comments. The image data is copied as it
+// is, so nothing is lost to re-encoding. Colour profiles and the segments
+// that decoders need are kept. Other formats, and files too damaged to
+// walk, are returned unchanged; images re-encoded by the app carry no
+// metadata to begin with.
+func stripMetadata(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		if out, ok := stripJPEGMetadata(data); ok {
+			return out
+		}
+	case bytes.HasPrefix(data, pngSignature):
+		if out, ok := stripPNGMetadata(data); ok {
+			return out
+		}
+	}
+	return data
+}
+
+// jpegMetadataMarkers are the segments stripMetadata drops: APP1 (EXIF and
+// XMP), APP13 (IPTC) and COM.
+var jpegMetadataMarkers = map[byte]bool{0xe1: true, 0xed: true, 0xfe: true}
+
+func stripJPEGMetadata(data []byte) ([]byte, bool) {
+	out := append(make([]byte, 0, len(data)), data[:2]...)
+	rest := data[2:]
+	for len(rest) >= 4 {
+		if rest[0] != 0xff {
+			return nil, false
+		}
+		marker := rest[1]
+		switch {
+		case marker == 0xff:
+			rest = rest[1:]
+			continue
+		case marker == 0xda || marker == 0xd9:
+			// Start of scan or end of image: the rest is image data.
+			return append(out, rest...), true
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			out = append(out, rest[:2]...)
+			rest = rest[2:]
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(rest[2:4]))
+		if n < 2 || 2+n > len(rest) {
+			return nil, false
+		}
+		if !jpegMetadataMarkers[marker] {
+			out = append(out, rest[:2+n]...)
+		}
+		rest = rest[2+n:]
+	}
+	return nil, false
+}
+
+// pngMetadataChunks are the chunks stripMetadata drops.
+var pngMetadataChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+func stripPNGMetadata(data []byte) ([]byte, bool) {
+	out := append(make([]byte, 0, len(data)), pngSignature...)
+	rest := data[len(pngSignature):]
+	for len(rest) >= 12 {
+		n := int(binary.BigEndian.Uint32(rest[:4]))
+		if n < 0 || 12+n > len(rest) {
+			return nil, false
+		}
+		chunk := rest[:12+n]
+		typ := string(chunk[4:8])
+		if !pngMetadataChunks[typ] {
+			out = append(out, chunk...)
+		}
+		rest = rest[12+n:]
+		if typ == "IEND" {
+			return out, true
+		}
+	}
+	return nil, false
+}
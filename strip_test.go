@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// exifPayload is the start of an APP1 EXIF segment with a GPS tag marker.
+var exifPayload = append([]byte("Exif\x00\x00MM\x00\x2a"), []byte("GPSLatitude 51.5")...)
+
+func TestStripJPEGMetadata(t *testing.T) {
+	plain := jpegBytes(t, 16, 16)
+	tagged := withJPEGSegment(plain, 0xe1, exifPayload)
+	tagged = withJPEGSegment(tagged, 0xfe, []byte("shot on a phone"))
+	tagged = withJPEGICC(tagged)
+
+	stripped := stripMetadata(tagged)
+	if bytes.Contains(stripped, []byte("Exif\x00\x00")) || bytes.Contains(stripped, []byte("GPSLatitude")) {
+		t.Error("EXIF survived stripping")
+	}
+	if bytes.Contains(stripped, []byte("shot on a phone")) {
+		t.Error("comment survived stripping")
+	}
+	if !hasICCProfile(stripped) {
+		t.Error("the colour profile was stripped")
+	}
+	if !bytes.Equal(stripMetadata(withJPEGICC(plain)), withJPEGICC(plain)) {
+		t.Error("a JPEG without metadata changed")
+	}
+	assertSamePixels(t, jpegDecode(t, stripped), jpegDecode(t, plain))
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	plain := pngBytes(t, 8, 8, color.White)
+	tagged := withPNGChunk(plain, "tEXt", []byte("Author\x00someone"))
+	tagged = withPNGChunk(tagged, "eXIf", exifPayload[6:])
+	tagged = withPNGICC(tagged)
+
+	stripped := stripMetadata(tagged)
+	for _, typ := range []string{"tEXt", "eXIf"} {
+		if bytes.Contains(stripped, []byte(typ)) {
+			t.Errorf("%s chunk survived stripping", typ)
+		}
+	}
+	if !hasICCProfile(stripped) {
+		t.Error("the colour profile was stripped")
+	}
+	img, err := png.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("stripped PNG no longer decodes: %v", err)
+	}
+	assertSamePixels(t, img, solidImage(8, 8, color.White))
+}
+
+func TestStripMetadataLeavesOtherDataAlone(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"gif":       []byte("GIF89a..."),
+		"truncated": withJPEGSegment(jpegBytes(t, 8, 8), 0xe1, exifPayload)[:8],
+	} {
+		if got := stripMetadata(data); !bytes.Equal(got, data) {
+			t.Errorf("%s: stripMetadata changed the data", name)
+		}
+	}
+}
+
+func TestSessionStripsMetadataFromSavedImages(t *testing.T) {
+	m := newMockReddit(t)
+	tagged := withJPEGSegment(jpegBytes(t, 16, 16), 0xe1, exifPayload)
+	posts := []Post{{ID: "a", Title: "Holiday", URL: m.addImage("holiday.jpg", tagged)}}
+
+	for _, strip := range []bool{false, true} {
+		saver := newRecordingSaver()
+		s := newMockSession(m)
+		s.opts.download = true
+		s.opts.stripMetadata = strip
+		s.opts.filenames, _ = parseFilenameTemplate(defaultFilenameTemplate)
+		s.saver = saver
+		s.processPosts(context.Background(), posts, 1, 1)
+
+		saved, ok := saver.saved["Holiday.jpg"]
+		if !ok {
+			t.Fatalf("strip %v: nothing saved", strip)
+		}
+		if got := bytes.Contains(saved, []byte("Exif\x00\x00")); got == strip {
+			t.Errorf("strip %v: saved file has EXIF %v", strip, got)
+		}
+	}
+}
+
+func jpegDecode(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding JPEG: %v", err)
+	}
+	return img
+}
+
+// assertSamePixels fails unless a and b have the same bounds and colours.
+func assertSamePixels(t *testing.T, a, b image.Image) {
+	t.Helper()
+	if a.Bounds() != b.Bounds() {
+		t.Fatalf("bounds %v and %v differ", a.Bounds(), b.Bounds())
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			ar, ag, ab, aa := a.At(x, y).RGBA()
+			br, bg, bb, ba := b.At(x, y).RGBA()
+			if ar != br || ag != bg || ab != bb || aa != ba {
+				t.Fatalf("pixel (%d,%d) differs", x, y)
+			}
+		}
+	}
+}
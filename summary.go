@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+)
+
+// runSummary is the machine-readable result of a headless run printed by
+// --json-output. Fetched counts images, with galleries expanded; those
+// neither downloaded nor failed were skipped, e.g. as not images, already
+// saved with --resume or cut off by a budget or deadline.
+type runSummary struct {
+	Fetched    int      `json:"fetched"`
+	Downloaded int      `json:"downloaded"`
+	Skipped    int      `json:"skipped"`
+	Failed     int      `json:"failed"`
+	Saved      []string `json:"saved"`
+}
+
+// summarize builds the summary of the run so far from sess.
+func (r *runner) summarize(sess *session) runSummary {
+	sum := runSummary{
+		Fetched:    int(r.expanded.Load()),
+		Downloaded: sess.stats.Decoded(),
+		Failed:     sess.stats.Failed(),
+		Saved:      []string{},
+	}
+	sum.Skipped = max(sum.Fetched-sum.Downloaded-sum.Failed, 0)
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	for _, p := range sess.savedPosts {
+		if r.cfg.zipPath != "" {
+			sum.Saved = append(sum.Saved, p.File)
+		} else {
+			sum.Saved = append(sum.Saved, filepath.Join(downloadDir, p.File))
+		}
+	}
+	return sum
+}
+
+// writeJSON writes the summary to w as a single JSON object.
+func (s runSummary) writeJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = old }()
+
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	f()
+	w.Close()
+	return <-out
+}
+
+func TestJSONOutputSummarizesRun(t *testing.T) {
+	m := newMockReddit(t, "r_mixed.json")
+	addMixedImages(t, m)
+	chdirTemp(t)
+
+	r := newMockRunner(t, m, "download", "--subreddit=mixed", "--json-output")
+	var err error
+	out := captureStdout(t, func() { err = runDownload(r) })
+	if err != nil {
+		t.Fatalf("runDownload: %v", err)
+	}
+	if err := r.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Fetched, Downloaded, Skipped, Failed *int
+		Saved                                []string
+	}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("parsing %q: %v", out, err)
+	}
+	if dec.More() {
+		t.Errorf("more than one JSON value in %q", out)
+	}
+	for name, field := range map[string]struct {
+		got  *int
+		want int
+	}{
+		"fetched":    {got.Fetched, 7},
+		"downloaded": {got.Downloaded, 4},
+		"skipped":    {got.Skipped, 1},
+		"failed":     {got.Failed, 2},
+	} {
+		if field.got == nil || *field.got != field.want {
+			t.Errorf("%s = %v, want %d", name, field.got, field.want)
+		}
+	}
+
+	slices.Sort(got.Saved)
+	var want []string
+	for _, name := range []string{"A_GIF.gif", "A_JPEG.jpg", "A_PNG.png", "Another_PNG.png"} {
+		want = append(want, filepath.Join(downloadDir, name))
+	}
+	if !slices.Equal(got.Saved, want) {
+		t.Errorf("saved = %v, want %v", got.Saved, want)
+	}
+	for _, path := range got.Saved {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("listed file: %v", err)
+		}
+	}
+}
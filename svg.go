@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+func init() {
+	registerDecoderFormat("svg")
+}
+
+// isSVG reports whether data looks like an SVG document. SVGs have no
+// fixed magic number, so this looks for an <svg element near the start,
+// after any XML declaration, doctype or comments.
+func isSVG(data []byte) bool {
+	head := data[:min(len(data), 1024)]
+	trimmed := bytes.TrimSpace(head)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return false
+	}
+	return bytes.Contains(head, []byte("<svg"))
+}
+
+// rasterizeSVG draws the SVG document in data at width pixels wide, keeping
+// the aspect ratio of its viewBox. The app otherwise only handles raster
+// images, so an SVG is shown and saved (as PNG) in this form.
+func rasterizeSVG(data []byte, width int) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, err
+	}
+	vw, vh := icon.ViewBox.W, icon.ViewBox.H
+	if vw <= 0 || vh <= 0 {
+		return nil, errors.New("SVG has no size")
+	}
+	height := int(math.Round(float64(width) * vh / vw))
+	if height <= 0 {
+		return nil, fmt.Errorf("SVG of %gx%g is too flat to draw", vw, vh)
+	}
+
+	icon.SetTarget(0, 0, float64(width), float64(height))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+	return img, nil
+}
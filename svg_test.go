@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// redSquareSVG is a 10x5 viewBox with a red rectangle over its left half.
+const redSquareSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 5">
+  <rect x="0" y="0" width="5" height="5" fill="#ff0000"/>
+</svg>`
+
+func TestRasterizeSVG(t *testing.T) {
+	img, err := rasterizeSVG([]byte(redSquareSVG), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(100, 50) {
+		t.Fatalf("rasterized to %v, want 100x50", got)
+	}
+	if r, g, b, a := img.At(25, 25).RGBA(); r>>8 != 0xff || g != 0 || b != 0 || a>>8 != 0xff {
+		t.Errorf("inside the rectangle = %d,%d,%d,%d; want opaque red", r>>8, g>>8, b>>8, a>>8)
+	}
+	if _, _, _, a := img.At(75, 25).RGBA(); a != 0 {
+		t.Errorf("outside the rectangle has alpha %d, want transparent", a>>8)
+	}
+
+	if _, err := rasterizeSVG([]byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), 100); err == nil {
+		t.Error("SVG without a viewBox rasterized")
+	}
+}
+
+func TestIsSVG(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{redSquareSVG, true},
+		{`  <svg xmlns="http://www.w3.org/2000/svg"/>`, true},
+		{`<!-- logo --><svg/>`, true},
+		{`<html><body>no</body></html>`, false},
+		{"\x89PNG\r\n\x1a\n<svg", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isSVG([]byte(tt.data)); got != tt.want {
+			t.Errorf("isSVG(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestSVGSavedAsPNG(t *testing.T) {
+	m := newMockReddit(t)
+	posts := []Post{{ID: "s", Title: "Logo", URL: m.addImage("logo.svg", []byte(redSquareSVG))}}
+
+	saver := newRecordingSaver()
+	s := newMockSession(m)
+	s.opts.download = true
+	s.opts.filenames, _ = parseFilenameTemplate(defaultFilenameTemplate)
+	s.saver = saver
+	s.processPosts(context.Background(), posts, 1, 1)
+
+	data, ok := saver.saved["Logo.png"]
+	if !ok {
+		t.Fatalf("saved %v, want Logo.png", saver.saved)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Logo.png is not a PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != cardWidth || size.Y != cardWidth/2 {
+		t.Errorf("Logo.png is %v, want %dx%d", size, cardWidth, cardWidth/2)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+// variantTheme wraps a theme and always renders it in a fixed variant,
+// ignoring the variant requested by the OS.
+type variantTheme struct {
+	fyne.Theme
+	variant fyne.ThemeVariant
+}
+
+func (t variantTheme) Color(name fyne.ThemeColorName, _ fyne.ThemeVariant) color.Color {
+	return t.Theme.Color(name, t.variant)
+}
+
+// themeForName maps a --theme value to a Fyne theme. It returns nil for
+// "auto", meaning the OS preference should be kept.
+func themeForName(name string) (fyne.Theme, error) {
+	switch name {
+	case "auto", "":
+		return nil, nil
+	case "light":
+		return variantTheme{Theme: theme.DefaultTheme(), variant: theme.VariantLight}, nil
+	case "dark":
+		return variantTheme{Theme: theme.DefaultTheme(), variant: theme.VariantDark}, nil
+	default:
+		return nil, fmt.Errorf("unknown theme %q (want auto, light or dark)", name)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+func TestThemeForName(t *testing.T) {
+	for _, name := range []string{"auto", ""} {
+		th, err := themeForName(name)
+		if th != nil || err != nil {
+			t.Errorf("themeForName(%q) = %v, %v; want nil, nil", name, th, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		variant fyne.ThemeVariant
+	}{
+		{"light", theme.VariantLight},
+		{"dark", theme.VariantDark},
+	}
+	for _, tt := range tests {
+		th, err := themeForName(tt.name)
+		if err != nil {
+			t.Fatalf("themeForName(%q): %v", tt.name, err)
+		}
+		// The requested variant is ignored in favour of the forced one.
+		for _, requested := range []fyne.ThemeVariant{theme.VariantLight, theme.VariantDark} {
+			got := th.Color(theme.ColorNameBackground, requested)
+			want := theme.DefaultTheme().Color(theme.ColorNameBackground, tt.variant)
+			if got != want {
+				t.Errorf("%s theme background for variant %d = %v, want %v", tt.name, requested, got, want)
+			}
+		}
+	}
+
+	if _, err := themeForName("sepia"); err == nil {
+		t.Error("themeForName(\"sepia\") succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// timedPhases are the phases --verbose times, in the order reported.
+var timedPhases = []string{"fetch", "resolve", "download", "decode", "resize", "save"}
+
+// phaseTimings accumulates the time spent in each phase of a run, for
+// --verbose. A nil *phaseTimings records nothing, so callers need not check
+// whether timing is enabled.
+type phaseTimings struct {
+	mu     sync.Mutex
+	totals map[string]time.Duration
+	counts map[string]int
+}
+
+func newPhaseTimings() *phaseTimings {
+	return &phaseTimings{totals: make(map[string]time.Duration), counts: make(map[string]int)}
+}
+
+// Add records that phase took d for subject, a subreddit or image URL, and
+// logs it.
+func (t *phaseTimings) Add(subject, phase string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	log.Printf("Timing: %s %s took %s", phase, subject, d.Round(time.Microsecond))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.totals[phase] += d
+	t.counts[phase]++
+}
+
+// Since is Add with the time elapsed since start.
+func (t *phaseTimings) Since(subject, phase string, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.Add(subject, phase, time.Since(start))
+}
+
+// Total returns the accumulated time of phase.
+func (t *phaseTimings) Total(phase string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.totals[phase]
+}
+
+// WriteReport prints a table of the count, total and mean time of each
+// phase. Phases run concurrently, so totals can exceed the wall time.
+func (t *phaseTimings) WriteReport(w io.Writer) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintln(w, "Time per phase:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  phase\tcount\ttotal\tmean\t")
+	for _, phase := range timedPhases {
+		n := t.counts[phase]
+		if n == 0 {
+			continue
+		}
+		total := t.totals[phase]
+		fmt.Fprintf(tw, "  %s\t%d\t%s\t%s\t\n", phase, n, total.Round(time.Millisecond), (total / time.Duration(n)).Round(time.Millisecond))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimingsSums(t *testing.T) {
+	timings := newPhaseTimings()
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			timings.Add("https://i.redd.it/x.png", "download", time.Duration(i+1)*time.Millisecond)
+			timings.Add("https://i.redd.it/x.png", "decode", 2*time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	timings.Add("pics", "fetch", 300*time.Millisecond)
+
+	want := map[string]time.Duration{
+		"fetch":    300 * time.Millisecond,
+		"download": 55 * time.Millisecond,
+		"decode":   20 * time.Millisecond,
+		"save":     0,
+	}
+	for phase, total := range want {
+		if got := timings.Total(phase); got != total {
+			t.Errorf("Total(%q) = %s, want %s", phase, got, total)
+		}
+	}
+
+	var report strings.Builder
+	timings.WriteReport(&report)
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	// A heading, the column names and one row per phase that ran, in
+	// pipeline order.
+	if len(lines) != 5 {
+		t.Fatalf("report has %d lines, want 5:\n%s", len(lines), report.String())
+	}
+	for i, row := range []string{"fetch 1 300ms 300ms", "download 10 55ms 6ms", "decode 10 20ms 2ms"} {
+		if got := strings.Join(strings.Fields(lines[i+2]), " "); got != row {
+			t.Errorf("report row %d = %q, want %q", i, got, row)
+		}
+	}
+}
+
+func TestPhaseTimingsNil(t *testing.T) {
+	var timings *phaseTimings
+	timings.Add("pics", "fetch", time.Second)
+	timings.Since("pics", "fetch", time.Now())
+	var report strings.Builder
+	timings.WriteReport(&report)
+	if report.Len() != 0 {
+		t.Errorf("nil timings wrote %q", report.String())
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxIdleConnsPerHost is how many keep-alive connections are kept open to a
+// single host. Bulk downloads mostly hit one or two CDN hosts, so this is far
+// above the net/http default of 2.
+const maxIdleConnsPerHost = 16
+
+// Defaults for --dial-timeout and --tls-timeout, matching those of
+// http.DefaultTransport.
+const (
+	defaultDialTimeout = 30 * time.Second
+	defaultTLSTimeout  = 10 * time.Second
+)
+
+// newTransport returns the transport shared by the Reddit and image clients,
+// tuned to reuse connections across many downloads from the same host.
+// dialTimeout and tlsTimeout bound connecting and the TLS handshake only,
+// so a dead host fails fast while a slow body can still finish. Zero means
+// no limit. insecure disables TLS certificate verification, which is only
+// meant for inspecting traffic through a debugging proxy such as mitmproxy.
+func newTransport(insecure bool, dialTimeout, tlsTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = tlsTimeout
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.DisableKeepAlives = false
+	// A custom TLSClientConfig would otherwise turn off HTTP/2.
+	transport.ForceAttemptHTTP2 = true
+	if insecure {
+		log.Println("WARNING: TLS certificate verification is disabled (--insecure); do not use this outside of debugging")
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return transport
+}
+
+// defaultUserAgent is sent on every request unless --user-agent is given.
+const defaultUserAgent = "Go-Reddit-Client"
+
+// userAgentTransport sets the User-Agent header on every request that does
+// not already carry one, so the Reddit, image and resolver clients all
+// identify themselves without each request doing it.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func newUserAgentTransport(base http.RoundTripper, userAgent string) *userAgentTransport {
+	return &userAgentTransport{base: base, userAgent: userAgent}
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		// A RoundTripper must not modify the caller's request.
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newRedditClient returns the client used for Reddit API requests.
+func newRedditClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{Transport: transport}
+}
+
+// newImageClient returns the client used for image downloads. When
+// followRedirects is false the first response is returned as-is instead of
+// chasing the Location header. Redirects to hosts outside allow are refused.
+func newImageClient(transport http.RoundTripper, followRedirects bool, allow hostAllowlist) *http.Client {
+	client := &http.Client{Transport: transport}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !followRedirects {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return allow.check(req.URL.String())
+	}
+	return client
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewTransportInsecure(t *testing.T) {
+	if tr := newTransport(false, defaultDialTimeout, defaultTLSTimeout); tr.TLSClientConfig != nil && tr.TLSClientConfig.InsecureSkipVerify {
+		t.Error("verification is skipped without --insecure")
+	}
+	tr := newTransport(true, defaultDialTimeout, defaultTLSTimeout)
+	if tr.TLSClientConfig == nil || !tr.TLSClientConfig.InsecureSkipVerify {
+		t.Error("verification is not skipped with --insecure")
+	}
+	if !tr.ForceAttemptHTTP2 {
+		t.Error("--insecure turns off HTTP/2")
+	}
+}
+
+func TestNewTransportAgainstSelfSignedServer(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if resp, err := (&http.Client{Transport: newTransport(false, defaultDialTimeout, defaultTLSTimeout)}).Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("a self-signed certificate was accepted without --insecure")
+	}
+	resp, err := (&http.Client{Transport: newTransport(true, defaultDialTimeout, defaultTLSTimeout)}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("with --insecure: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTransportReusesConnections(t *testing.T) {
+	m := newMockReddit(t)
+	url := m.addImage("cat.png", pngBytes(t, 8, 8, color.White))
+
+	transport := newTransport(false, defaultDialTimeout, defaultTLSTimeout)
+	var dials atomic.Int32
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return dial(ctx, network, addr)
+	}
+	dl := newDownloader(newImageClient(transport, true, nil), 0, 0)
+	for range 5 {
+		if _, err := dl.fetchImageData(context.Background(), url); err != nil {
+			t.Fatalf("fetchImageData: %v", err)
+		}
+	}
+	if got := dials.Load(); got != 1 {
+		t.Errorf("5 downloads from one host dialled %d connections, want 1", got)
+	}
+}
+
+func TestUserAgentTransportSetsHeader(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("User-Agent"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newUserAgentTransport(http.DefaultTransport, "test-agent/1.0")}
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Header.Get("User-Agent") != "" {
+		t.Error("the transport modified the caller's request")
+	}
+
+	// A header set on the request is left alone.
+	req.Header.Set("User-Agent", "custom")
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := []string{"test-agent/1.0", "custom"}
+	if !slices.Equal(got, want) {
+		t.Errorf("User-Agent headers = %q, want %q", got, want)
+	}
+}
+
+func TestRunnerSendsUserAgentOnEveryRequest(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	var agents []string
+	var mu sync.Mutex
+	record := func(name string) {
+		data := pngBytes(t, 8, 8, color.White)
+		m.handle("/img/"+name, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			agents = append(agents, r.Header.Get("User-Agent"))
+			mu.Unlock()
+			w.Write(data)
+		})
+	}
+	for _, name := range []string{"cat.png", "dog.png", "bird.png"} {
+		record(name)
+	}
+
+	r := newMockRunner(t, m, "show", "--subreddit=pics", "--limit=3", "--user-agent=scroller-test")
+	defer r.close()
+	posts, err := r.fetchInitial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.process(r.newSession(nil), posts)
+
+	if len(agents) != 3 {
+		t.Fatalf("%d image requests, want 3", len(agents))
+	}
+	for _, ua := range agents {
+		if ua != "scroller-test" {
+			t.Errorf("image request User-Agent = %q, want scroller-test", ua)
+		}
+	}
+}
+
+func TestTimeoutFlagsReachTransport(t *testing.T) {
+	m := newMockReddit(t)
+	r := newMockRunner(t, m, "show", "--subreddit=pics", "--dial-timeout=3s", "--tls-timeout=250ms")
+	defer r.close()
+	if r.cfg.dialTimeout != 3*time.Second {
+		t.Errorf("dial timeout = %v, want 3s", r.cfg.dialTimeout)
+	}
+	ua, ok := r.redditClient.Transport.(*userAgentTransport)
+	if !ok {
+		t.Fatalf("Reddit client transport is %T, want *userAgentTransport", r.redditClient.Transport)
+	}
+	tr, ok := ua.base.(*http.Transport)
+	if !ok {
+		t.Fatalf("base transport is %T, want *http.Transport", ua.base)
+	}
+	if tr.TLSHandshakeTimeout != 250*time.Millisecond {
+		t.Errorf("TLS handshake timeout = %v, want 250ms", tr.TLSHandshakeTimeout)
+	}
+}
+
+func TestTLSTimeoutFailsStalledHandshake(t *testing.T) {
+	// A server that accepts connections but never answers the handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+
+	client := &http.Client{Transport: newTransport(false, defaultDialTimeout, 100*time.Millisecond)}
+	start := time.Now()
+	resp, err := client.Get("https://" + l.Addr().String())
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("a stalled handshake succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stalled handshake failed after %v, want about 100ms", elapsed)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
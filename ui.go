@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"slices"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// columnsForWidth returns how many cells of at least minCell pixels fit in
+// windowWidth, never fewer than one.
+func columnsForWidth(windowWidth, minCell int) int {
+	if minCell <= 0 || windowWidth < minCell {
+		return 1
+	}
+	return windowWidth / minCell
+}
+
+// fitModeForName maps a --fit value to the fill mode card images use.
+func fitModeForName(name string) (canvas.ImageFill, error) {
+	switch name {
+	case "original":
+		return canvas.ImageFillOriginal, nil
+	case "contain":
+		return canvas.ImageFillContain, nil
+	case "stretch":
+		return canvas.ImageFillStretch, nil
+	}
+	return 0, fmt.Errorf("unknown fit mode %q (want original, contain or stretch)", name)
+}
+
+// newFeedContainer returns the container cards are added to: a single
+// vertical list when columns is zero, or a grid with that many columns.
+func newFeedContainer(columns int) *fyne.Container {
+	if columns <= 0 {
+		return container.NewVBox()
+	}
+	return container.NewGridWithColumns(columns)
+}
+
+// withBackground places obj on a rectangle filled with bg, so that the
+// padding around cards of differing aspect ratios in a grid shares one
+// colour.
+func withBackground(obj fyne.CanvasObject, bg color.Color) fyne.CanvasObject {
+	return container.NewStack(canvas.NewRectangle(bg), obj)
+}
+
+// withSpacing adds an empty gap of spacing pixels below card, on top of the
+// feed layout's own padding. A spacing of zero or less returns card as is.
+func withSpacing(card fyne.CanvasObject, spacing float32) fyne.CanvasObject {
+	if spacing <= 0 {
+		return card
+	}
+	gap := canvas.NewRectangle(color.Transparent)
+	gap.SetMinSize(fyne.NewSize(0, spacing))
+	return container.NewBorder(nil, gap, nil, nil, card)
+}
+
+// orderedFeed adds cards to feed in post order while they arrive in any
+// order, each placed after the cards of the posts before it.
+type orderedFeed struct {
+	feed *fyne.Container
+
+	mu sync.Mutex
+	// indexes holds the post index of every card added, sorted.
+	indexes []int
+}
+
+// insert adds card, which belongs to the post at index.
+func (f *orderedFeed) insert(index int, card fyne.CanvasObject) {
+	f.mu.Lock()
+	pos, _ := slices.BinarySearch(f.indexes, index)
+	f.indexes = slices.Insert(f.indexes, pos, index)
+	f.feed.Objects = slices.Insert(f.feed.Objects, pos, card)
+	f.mu.Unlock()
+	f.feed.Refresh()
+}
+
+// prependCard inserts card at the top of the feed.
+func prependCard(feed *fyne.Container, card fyne.CanvasObject) {
+	feed.Objects = append([]fyne.CanvasObject{card}, feed.Objects...)
+	feed.Refresh()
+}
+
+// resizeWatcher is a layout that stretches its objects over the full size it
+// is given and reports every new size to onResize. Wrapping the window
+// content in it gives a callback whenever the window is resized.
+type resizeWatcher struct {
+	onResize func(fyne.Size)
+	last     fyne.Size
+}
+
+func (r *resizeWatcher) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	for _, o := range objects {
+		o.Move(fyne.NewPos(0, 0))
+		o.Resize(size)
+	}
+	if size != r.last {
+		r.last = size
+		r.onResize(size)
+	}
+}
+
+func (r *resizeWatcher) MinSize(objects []fyne.CanvasObject) fyne.Size {
+	var minSize fyne.Size
+	for _, o := range objects {
+		minSize = minSize.Max(o.MinSize())
+	}
+	return minSize
+}
+
+// newResponsiveFeed wraps scroll, whose content is grid, so that the grid's
+// column count follows the window width with cells of at least minCell.
+func newResponsiveFeed(scroll *container.Scroll, grid *fyne.Container, minCell int) fyne.CanvasObject {
+	columns := 0
+	watcher := &resizeWatcher{onResize: func(size fyne.Size) {
+		n := columnsForWidth(int(size.Width), minCell)
+		if n == columns {
+			return
+		}
+		columns = n
+		grid.Layout = layout.NewGridLayoutWithColumns(n)
+		grid.Refresh()
+		scroll.Refresh()
+	}}
+	return container.New(watcher, scroll)
+}
+
+// scrollTopThreshold is how far in pixels the feed must be scrolled before
+// the scroll-to-top button appears; zero or less disables the button. It can
+// be set with --scroll-top-threshold.
+var scrollTopThreshold float32 = 1200
+
+// shouldShowScrollTop reports whether the scroll-to-top button should be
+// visible at vertical scroll offset.
+func shouldShowScrollTop(offset float32) bool {
+	return scrollTopThreshold > 0 && offset > scrollTopThreshold
+}
+
+// withScrollTopButton overlays content, which holds scroll, with a button in
+// the bottom right corner that appears once scroll is scrolled past
+// scrollTopThreshold and jumps back to the top.
+func withScrollTopButton(scroll *container.Scroll, content fyne.CanvasObject) fyne.CanvasObject {
+	button := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+		scroll.ScrollToTop()
+	})
+	button.Hide()
+
+	onScrolled := scroll.OnScrolled
+	scroll.OnScrolled = func(offset fyne.Position) {
+		if onScrolled != nil {
+			onScrolled(offset)
+		}
+		if shouldShowScrollTop(offset.Y) {
+			button.Show()
+		} else {
+			button.Hide()
+		}
+	}
+
+	corner := container.NewVBox(layout.NewSpacer(), container.NewHBox(layout.NewSpacer(), container.NewPadded(button)))
+	return container.NewStack(content, corner)
+}
+
+// newDownloadToolbar returns a toolbar with Pause and Resume buttons for
+// gate. Pausing lets downloads in flight finish but starts no new ones,
+// which matters mostly for --watch, where new posts keep arriving.
+func newDownloadToolbar(gate *downloadGate) *widget.Toolbar {
+	return widget.NewToolbar(
+		widget.NewToolbarAction(theme.MediaPauseIcon(), func() {
+			gate.Pause()
+			log.Println("Downloads paused")
+		}),
+		widget.NewToolbarAction(theme.MediaPlayIcon(), func() {
+			gate.Resume()
+			log.Println("Downloads resumed")
+		}),
+	)
+}
+
+// cardSelection tracks the image URL of the card the user last clicked, for
+// the copy shortcut.
+type cardSelection struct {
+	mu  sync.Mutex
+	url string
+}
+
+// Select makes url the current selection.
+func (s *cardSelection) Select(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.url = url
+}
+
+// URL returns the selected image URL, or "" if nothing has been selected.
+func (s *cardSelection) URL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.url
+}
+
+// installCopyShortcut copies the selected card's URL to the clipboard on the
+// platform copy shortcut (Ctrl+C / Cmd+C).
+func installCopyShortcut(w fyne.Window, sel *cardSelection) {
+	w.Canvas().AddShortcut(&fyne.ShortcutCopy{}, func(fyne.Shortcut) {
+		if url := sel.URL(); url != "" {
+			w.Clipboard().SetContent(url)
+			log.Printf("Copied URL: %s", url)
+		}
+	})
+}
+
+// tappableCard wraps a feed card so it can be selected and opened with a
+// click and offers a context menu on right click.
+type tappableCard struct {
+	widget.BaseWidget
+	content fyne.CanvasObject
+	menu    *fyne.Menu
+	onTap   func()
+	onOpen  func()
+}
+
+func newTappableCard(content fyne.CanvasObject, menu *fyne.Menu, onTap, onOpen func()) *tappableCard {
+	c := &tappableCard{content: content, menu: menu, onTap: onTap, onOpen: onOpen}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+func (c *tappableCard) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(c.content)
+}
+
+func (c *tappableCard) Tapped(*fyne.PointEvent) {
+	if c.onTap != nil {
+		c.onTap()
+	}
+	if c.onOpen != nil {
+		c.onOpen()
+	}
+}
+
+func (c *tappableCard) TappedSecondary(e *fyne.PointEvent) {
+	if c.onTap != nil {
+		c.onTap()
+	}
+	if c.menu == nil {
+		return
+	}
+	canvas := fyne.CurrentApp().Driver().CanvasForObject(c)
+	widget.ShowPopUpMenuAtPosition(c.menu, canvas, e.AbsolutePosition)
+}
+
+// selectableCard wraps card so that clicking it selects imageURL and opens
+// full, the original full-resolution image, in a viewer, and right clicking
+// offers to copy the URL or the image itself. While cover hides a spoiler,
+// neither the viewer nor copying the image gives it away.
+func selectableCard(w fyne.Window, sel *cardSelection, card fyne.CanvasObject, cover *spoilerCover, imageURL, title string, full image.Image) fyne.CanvasObject {
+	menu := fyne.NewMenu("",
+		fyne.NewMenuItem("Copy URL", func() {
+			w.Clipboard().SetContent(imageURL)
+		}),
+		fyne.NewMenuItem("Copy image", func() {
+			if cover.covered() {
+				log.Printf("Not copying spoiler image %s until it is revealed", imageURL)
+				return
+			}
+			// Encoding a large image and running the clipboard tool
+			// take a moment; keep the UI responsive meanwhile.
+			go func() {
+				if err := copyImage(systemClipboard, full); err != nil {
+					log.Printf("Failed to copy image: %v", err)
+					return
+				}
+				log.Printf("Copied image: %s", imageURL)
+			}()
+		}),
+	)
+	return newTappableCard(card, menu,
+		func() { sel.Select(imageURL) },
+		func() {
+			if !cover.covered() {
+				showImageViewer(title, full)
+			}
+		},
+	)
+}
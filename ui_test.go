@@ -0,0 +1,175 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+func TestColumnsForWidth(t *testing.T) {
+	tests := []struct {
+		width, minCell, want int
+	}{
+		{800, 250, 3},
+		{750, 250, 3},
+		{749, 250, 2},
+		{1920, 300, 6},
+		{200, 250, 1},
+		{0, 250, 1},
+		{800, 0, 1},
+		{800, -10, 1},
+	}
+	for _, tt := range tests {
+		if got := columnsForWidth(tt.width, tt.minCell); got != tt.want {
+			t.Errorf("columnsForWidth(%d, %d) = %d, want %d", tt.width, tt.minCell, got, tt.want)
+		}
+	}
+}
+
+func TestSelectedCardURLIsCopied(t *testing.T) {
+	app := test.NewApp()
+	defer app.Quit()
+	w := app.NewWindow("feed")
+	sel := &cardSelection{}
+	installCopyShortcut(w, sel)
+
+	img := solidImage(4, 4, color.White)
+	urls := []string{"https://i.redd.it/first.png", "https://i.redd.it/second.png"}
+	var cards []fyne.CanvasObject
+	for _, u := range urls {
+		cards = append(cards, selectableCard(w, sel, canvas.NewImageFromImage(img), nil, u, "title", img))
+	}
+	w.SetContent(container.NewVBox(cards...))
+
+	copyShortcut := w.Canvas().(fyne.Shortcutable)
+	copyShortcut.TypedShortcut(&fyne.ShortcutCopy{})
+	if got := w.Clipboard().Content(); got != "" {
+		t.Errorf("copied %q with nothing selected", got)
+	}
+	for _, i := range []int{1, 0} {
+		test.Tap(cards[i].(fyne.Tappable))
+		if got := sel.URL(); got != urls[i] {
+			t.Errorf("after tapping card %d, selection = %q, want %q", i, got, urls[i])
+		}
+		copyShortcut.TypedShortcut(&fyne.ShortcutCopy{})
+		if got := w.Clipboard().Content(); got != urls[i] {
+			t.Errorf("after tapping card %d, copied %q, want %q", i, got, urls[i])
+		}
+	}
+}
+
+func TestShouldShowScrollTop(t *testing.T) {
+	old := scrollTopThreshold
+	t.Cleanup(func() { scrollTopThreshold = old })
+
+	tests := []struct {
+		threshold, offset float32
+		want              bool
+	}{
+		{1200, 0, false},
+		{1200, 1200, false},
+		{1200, 1201, true},
+		{1200, 50000, true},
+		{300, 301, true},
+		{0, 50000, false},
+		{-1, 50000, false},
+	}
+	for _, tt := range tests {
+		scrollTopThreshold = tt.threshold
+		if got := shouldShowScrollTop(tt.offset); got != tt.want {
+			t.Errorf("threshold %g: shouldShowScrollTop(%g) = %v, want %v", tt.threshold, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestScrollTopButtonFollowsOffset(t *testing.T) {
+	old := scrollTopThreshold
+	scrollTopThreshold = 100
+	t.Cleanup(func() { scrollTopThreshold = old })
+	app := test.NewApp()
+	defer app.Quit()
+
+	scroll := container.NewVScroll(canvas.NewRectangle(color.White))
+	var passedOn []float32
+	scroll.OnScrolled = func(offset fyne.Position) { passedOn = append(passedOn, offset.Y) }
+	overlay := withScrollTopButton(scroll, scroll)
+	button := findObjects[*widget.Button](overlay)[0]
+
+	for _, step := range []struct {
+		offset  float32
+		visible bool
+	}{{50, false}, {150, true}, {0, false}} {
+		scroll.OnScrolled(fyne.NewPos(0, step.offset))
+		if button.Visible() != step.visible {
+			t.Errorf("at offset %g the button is visible: %v, want %v", step.offset, button.Visible(), step.visible)
+		}
+	}
+	if len(passedOn) != 3 {
+		t.Errorf("the feed's own OnScrolled ran %d times, want 3", len(passedOn))
+	}
+}
+
+func TestFitModeForName(t *testing.T) {
+	tests := []struct {
+		name string
+		want canvas.ImageFill
+	}{
+		{"original", canvas.ImageFillOriginal},
+		{"contain", canvas.ImageFillContain},
+		{"stretch", canvas.ImageFillStretch},
+	}
+	for _, tt := range tests {
+		got, err := fitModeForName(tt.name)
+		if err != nil {
+			t.Errorf("fitModeForName(%q): %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("fitModeForName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := fitModeForName("cover"); err == nil {
+		t.Error("fitModeForName accepted an unknown mode")
+	}
+}
+
+// spacingGaps returns the heights of the gaps withSpacing placed in feed.
+func spacingGaps(feed *fyne.Container) []float32 {
+	var gaps []float32
+	for _, obj := range feed.Objects {
+		border, ok := obj.(*fyne.Container)
+		if !ok || len(border.Objects) != 2 {
+			continue
+		}
+		if gap, ok := border.Objects[1].(*canvas.Rectangle); ok {
+			gaps = append(gaps, gap.MinSize().Height)
+		}
+	}
+	return gaps
+}
+
+func TestCardSpacingAddsGapPerCard(t *testing.T) {
+	for _, spacing := range []float32{0, 12} {
+		feed := container.NewVBox()
+		ordered := &orderedFeed{feed: feed}
+		for i := range 3 {
+			ordered.insert(i, withSpacing(widget.NewLabel("card"), spacing))
+		}
+		gaps := spacingGaps(feed)
+		want := 3
+		if spacing == 0 {
+			want = 0
+		}
+		if len(gaps) != want {
+			t.Errorf("spacing %g: %d gaps in feed, want %d", spacing, len(gaps), want)
+		}
+		for _, h := range gaps {
+			if h != spacing {
+				t.Errorf("spacing %g: gap of height %g", spacing, h)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"image"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Zoom limits of the image viewer, and the factor one scroll step zooms by.
+const (
+	minViewerZoom  = 0.1
+	maxViewerZoom  = 8
+	viewerZoomStep = 1.1
+)
+
+// clampZoom limits a viewer zoom level to [minViewerZoom, maxViewerZoom].
+func clampZoom(z float32) float32 {
+	if z < minViewerZoom {
+		return minViewerZoom
+	}
+	if z > maxViewerZoom {
+		return maxViewerZoom
+	}
+	return z
+}
+
+// showImageViewer opens a window showing img at full resolution, zoomed
+// with the scroll wheel and panned by dragging.
+func showImageViewer(title string, img image.Image) {
+	w := fyne.CurrentApp().NewWindow(title)
+	w.SetContent(newImageViewer(img))
+	w.Resize(fyne.NewSize(800, 600))
+	w.Show()
+}
+
+// imageViewer draws an image at zoom times its natural size, with its top
+// left corner at offset.
+type imageViewer struct {
+	widget.BaseWidget
+	image   *canvas.Image
+	natural fyne.Size
+	zoom    float32
+	offset  fyne.Position
+}
+
+func newImageViewer(img image.Image) *imageViewer {
+	b := img.Bounds()
+	ci := canvas.NewImageFromImage(img)
+	ci.FillMode = canvas.ImageFillStretch
+	v := &imageViewer{
+		image:   ci,
+		natural: fyne.NewSize(float32(b.Dx()), float32(b.Dy())),
+		zoom:    1,
+	}
+	v.ExtendBaseWidget(v)
+	return v
+}
+
+func (v *imageViewer) CreateRenderer() fyne.WidgetRenderer {
+	return &imageViewerRenderer{viewer: v}
+}
+
+// Scrolled zooms in or out by one step, keeping the point under the cursor
+// in place.
+func (v *imageViewer) Scrolled(e *fyne.ScrollEvent) {
+	factor := float32(viewerZoomStep)
+	if e.Scrolled.DY < 0 {
+		factor = 1 / factor
+	}
+	zoom := clampZoom(v.zoom * factor)
+	ratio := zoom / v.zoom
+	v.offset = fyne.NewPos(
+		e.Position.X-(e.Position.X-v.offset.X)*ratio,
+		e.Position.Y-(e.Position.Y-v.offset.Y)*ratio,
+	)
+	v.zoom = zoom
+	v.Refresh()
+}
+
+// Dragged pans the image with the pointer.
+func (v *imageViewer) Dragged(e *fyne.DragEvent) {
+	v.offset = v.offset.Add(e.Dragged)
+	v.Refresh()
+}
+
+func (v *imageViewer) DragEnd() {}
+
+type imageViewerRenderer struct {
+	viewer *imageViewer
+}
+
+func (r *imageViewerRenderer) Layout(fyne.Size) {
+	v := r.viewer
+	v.image.Move(v.offset)
+	v.image.Resize(fyne.NewSize(v.natural.Width*v.zoom, v.natural.Height*v.zoom))
+}
+
+func (r *imageViewerRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(100, 100)
+}
+
+func (r *imageViewerRenderer) Refresh() {
+	r.Layout(r.viewer.Size())
+	canvas.Refresh(r.viewer.image)
+}
+
+func (r *imageViewerRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.viewer.image}
+}
+
+func (r *imageViewerRenderer) Destroy() {}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestClampZoom(t *testing.T) {
+	tests := []struct{ in, want float32 }{
+		{1, 1},
+		{minViewerZoom, minViewerZoom},
+		{maxViewerZoom, maxViewerZoom},
+		{0.01, minViewerZoom},
+		{0, minViewerZoom},
+		{-2, minViewerZoom},
+		{20, maxViewerZoom},
+		{float32(math.Inf(1)), maxViewerZoom},
+	}
+	for _, tt := range tests {
+		if got := clampZoom(tt.in); got != tt.want {
+			t.Errorf("clampZoom(%g) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestImageViewerScrollZoomsAroundCursor(t *testing.T) {
+	app := test.NewApp()
+	defer app.Quit()
+	v := newImageViewer(solidImage(200, 100, color.White))
+	cursor := fyne.NewPos(50, 40)
+
+	// The image point under the cursor stays there as the zoom changes.
+	under := func() fyne.Position {
+		return fyne.NewPos((cursor.X-v.offset.X)/v.zoom, (cursor.Y-v.offset.Y)/v.zoom)
+	}
+	before := under()
+	v.Scrolled(&fyne.ScrollEvent{PointEvent: fyne.PointEvent{Position: cursor}, Scrolled: fyne.NewDelta(0, 1)})
+	if v.zoom <= 1 {
+		t.Errorf("scrolling up zoomed to %g, want in", v.zoom)
+	}
+	if after := under(); math.Abs(float64(after.X-before.X)) > 1e-3 || math.Abs(float64(after.Y-before.Y)) > 1e-3 {
+		t.Errorf("point under the cursor moved from %v to %v", before, after)
+	}
+
+	for range 100 {
+		v.Scrolled(&fyne.ScrollEvent{PointEvent: fyne.PointEvent{Position: cursor}, Scrolled: fyne.NewDelta(0, 1)})
+	}
+	if v.zoom != maxViewerZoom {
+		t.Errorf("zoom after scrolling far in = %g, want %g", v.zoom, float32(maxViewerZoom))
+	}
+	for range 200 {
+		v.Scrolled(&fyne.ScrollEvent{PointEvent: fyne.PointEvent{Position: cursor}, Scrolled: fyne.NewDelta(0, -1)})
+	}
+	if v.zoom != minViewerZoom {
+		t.Errorf("zoom after scrolling far out = %g, want %g", v.zoom, float32(minViewerZoom))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// seenPosts remembers which posts have already been handled so that a
+// re-fetched listing can be reduced to the posts that are new.
+type seenPosts struct {
+	keys map[string]bool
+}
+
+func newSeenPosts() *seenPosts {
+	return &seenPosts{keys: make(map[string]bool)}
+}
+
+// postKey identifies a post by its Reddit id, or by URL if it has none.
+func postKey(p Post) string {
+	if p.ID != "" {
+		return p.ID
+	}
+	return p.URL
+}
+
+// diff returns the posts not seen before, in their original order, and
+// marks them as seen.
+func (s *seenPosts) diff(posts []Post) []Post {
+	var fresh []Post
+	for _, p := range posts {
+		key := postKey(p)
+		if s.keys[key] {
+			continue
+		}
+		s.keys[key] = true
+		fresh = append(fresh, p)
+	}
+	return fresh
+}
+
+// watchPosts calls fetch every interval and passes the posts not seen
+// before to handle. Fetch errors are logged and any posts fetched alongside
+// them still handled.
+// It returns when ctx is done.
+func watchPosts(ctx context.Context, interval time.Duration, seen *seenPosts, fetch func() ([]Post, error), handle func([]Post)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		posts, err := fetch()
+		if err != nil {
+			log.Printf("Watch: error fetching posts: %v", err)
+		}
+		fresh := seen.diff(posts)
+		if len(fresh) == 0 {
+			continue
+		}
+		log.Printf("Watch: %d new posts", len(fresh))
+		handle(fresh)
+	}
+}
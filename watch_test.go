@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func postIDs(posts []Post) []string {
+	ids := make([]string, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, postKey(p))
+	}
+	return ids
+}
+
+func TestSeenPostsDiff(t *testing.T) {
+	seen := newSeenPosts()
+	fetches := []struct {
+		posts []Post
+		want  []string
+	}{
+		{[]Post{{ID: "a"}, {ID: "b"}}, []string{"a", "b"}},
+		// A new post at the top, the old ones below it.
+		{[]Post{{ID: "c"}, {ID: "a"}, {ID: "b"}}, []string{"c"}},
+		{[]Post{{ID: "c"}, {ID: "a"}}, []string{}},
+		// Posts without an id are told apart by URL.
+		{[]Post{{URL: "https://i.redd.it/x.png"}, {ID: "d"}, {URL: "https://i.redd.it/x.png"}}, []string{"https://i.redd.it/x.png", "d"}},
+		{nil, []string{}},
+	}
+	for i, f := range fetches {
+		if got := postIDs(seen.diff(f.posts)); !slices.Equal(got, f.want) {
+			t.Errorf("fetch %d: new posts = %v, want %v", i, got, f.want)
+		}
+	}
+}
+
+func TestWatchPostsHandlesOnlyNewPosts(t *testing.T) {
+	seen := newSeenPosts()
+	seen.diff([]Post{{ID: "a"}})
+
+	results := [][]Post{
+		{{ID: "a"}},
+		{{ID: "b"}, {ID: "a"}},
+		{{ID: "c"}, {ID: "b"}, {ID: "a"}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var handled [][]string
+	fetch := func() ([]Post, error) {
+		posts := results[0]
+		if len(results) > 1 {
+			results = results[1:]
+		} else {
+			cancel()
+		}
+		return posts, nil
+	}
+	watchPosts(ctx, time.Millisecond, seen, fetch, func(fresh []Post) {
+		handled = append(handled, postIDs(fresh))
+	})
+
+	if len(handled) != 2 || !slices.Equal(handled[0], []string{"b"}) || !slices.Equal(handled[1], []string{"c"}) {
+		t.Errorf("handled %v, want [[b] [c]]", handled)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// zipSaver is the Saver for --zip: it writes saved images into a single zip
+// archive, each followed by a name.json sidecar holding its manifest entry.
+// Entries are streamed into the archive as they are saved rather than
+// collected first.
+type zipSaver struct {
+	file *os.File
+
+	mu sync.Mutex
+	w  *zip.Writer
+}
+
+// newZipSaver creates the archive at path, replacing any existing file.
+func newZipSaver(path string) (*zipSaver, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zip archive: %w", err)
+	}
+	return &zipSaver{file: f, w: zip.NewWriter(f)}, nil
+}
+
+// Save adds raw to the archive as name.
+func (z *zipSaver) Save(name string, img image.Image, raw []byte) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.writeEntry(name, raw)
+}
+
+// SaveMetadata adds the sidecar for name, which has the extension of name
+// replaced by .json.
+func (z *zipSaver) SaveMetadata(name string, entry manifestEntry) error {
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.writeEntry(strings.TrimSuffix(name, path.Ext(name))+".json", meta)
+}
+
+func (z *zipSaver) writeEntry(name string, data []byte) error {
+	w, err := z.w.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to zip archive: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to zip archive: %w", name, err)
+	}
+	return nil
+}
+
+// Close writes the archive's central directory and closes the file. The
+// archive is unreadable until it has been closed.
+func (z *zipSaver) Close() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if err := z.w.Close(); err != nil {
+		z.file.Close()
+		return fmt.Errorf("failed to finish zip archive: %w", err)
+	}
+	return z.file.Close()
+}
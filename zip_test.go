@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestZipSaverArchivesRun(t *testing.T) {
+	m := newMockReddit(t, "r_pics.json", "r_pics_t3_p2.json")
+	served := map[string][]byte{
+		"cat.png":  pngBytes(t, 8, 8, color.White),
+		"dog.png":  pngBytes(t, 8, 8, color.Black),
+		"bird.png": pngBytes(t, 8, 8, color.Gray{0x80}),
+	}
+	for name, data := range served {
+		m.addImage(name, data)
+	}
+	dir := chdirTemp(t)
+	archive := filepath.Join(dir, "run.zip")
+
+	r := newMockRunner(t, m, "download", "--subreddit=pics", "--zip="+archive)
+	if err := runDownload(r); err != nil {
+		t.Fatalf("runDownload: %v", err)
+	}
+	if err := r.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatalf("opening archive: %v", err)
+	}
+	defer zr.Close()
+	entries := make(map[string][]byte)
+	var names []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name] = data
+		names = append(names, f.Name)
+	}
+	slices.Sort(names)
+	want := []string{"A_dog.json", "A_dog.png", "First_cat_[OC].json", "First_cat_[OC].png", "Last_bird.json", "Last_bird.png"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("archive entries = %v, want %v", names, want)
+	}
+
+	for file, image := range map[string]string{"First_cat_[OC]": "cat.png", "A_dog": "dog.png", "Last_bird": "bird.png"} {
+		if !bytes.Equal(entries[file+".png"], served[image]) {
+			t.Errorf("%s.png differs from the image downloaded", file)
+		}
+		var entry manifestEntry
+		if err := json.Unmarshal(entries[file+".json"], &entry); err != nil {
+			t.Errorf("%s.json: %v", file, err)
+			continue
+		}
+		if entry.URL != m.URL+"/img/"+image || entry.File != file+".png" {
+			t.Errorf("%s.json = %+v, want the entry for %s", file, entry, image)
+		}
+	}
+	// No loose files are written alongside the archive.
+	if matches, _ := filepath.Glob(filepath.Join(dir, downloadDir, "*.png")); len(matches) > 0 {
+		t.Errorf("loose files saved: %v", matches)
+	}
+}